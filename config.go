@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"shield/core"
+	"shield/entities"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/utils"
+)
+
+// loadAuthenticationBackends configures the directories PasswordLogin consults before
+// the users collection. LDAP_GROUP_ROLES maps groups to roles as
+// "root:cn=admins,dc=example,dc=com;tenant:cn=staff,dc=example,dc=com".
+func loadAuthenticationBackends() []core.IAuthenticationBackend {
+	var backends []core.IAuthenticationBackend
+	if ldapURL := os.Getenv("LDAP_URL"); ldapURL != "" {
+		groupRoles := map[string]constants.Role{}
+		for _, pair := range strings.Split(os.Getenv("LDAP_GROUP_ROLES"), ";") {
+			if role, group, ok := strings.Cut(pair, ":"); ok {
+				groupRoles[strings.ToLower(strings.TrimSpace(group))] = constants.Role(strings.TrimSpace(role))
+			}
+		}
+		poolSize, _ := strconv.Atoi(os.Getenv("LDAP_POOL_SIZE"))
+		backends = append(backends, core.NewLdapBackend(core.LdapConfig{
+			URL:            ldapURL,
+			BindDN:         os.Getenv("LDAP_BIND_DN"),
+			BindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+			BaseDN:         os.Getenv("LDAP_BASE_DN"),
+			UserFilter:     os.Getenv("LDAP_USER_FILTER"),
+			StartTLS:       os.Getenv("LDAP_START_TLS") == "true",
+			PoolSize:       poolSize,
+			GroupAttribute: os.Getenv("LDAP_GROUP_ATTRIBUTE"),
+			GroupRoles:     groupRoles,
+			Exclusive:      os.Getenv("LDAP_EXCLUSIVE") == "true",
+			Timeout:        loadDuration("LDAP_TIMEOUT", 5*time.Second),
+		}))
+		utils.Logger.Info("LDAP authentication backend configured")
+	}
+	return backends
+}
+
+// loadSamlKeyPair reads the SAML service provider key pair. SAML stays disabled when
+// SAML_SP_CERT and SAML_SP_KEY are not set.
+func loadSamlKeyPair() (*rsa.PrivateKey, *x509.Certificate) {
+	certFile, keyFile := os.Getenv("SAML_SP_CERT"), os.Getenv("SAML_SP_KEY")
+	if certFile == "" || keyFile == "" {
+		utils.Logger.Info("SAML service provider key pair not configured")
+		return nil, nil
+	}
+	keyPair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+	key, ok := keyPair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		utils.Logger.Fatal("SAML service provider key must be an RSA key")
+	}
+	return key, certificate
+}
+
+// loadNotifier sends notifications through SMTP_ADDR when it is set and only logs
+// them otherwise.
+func loadNotifier() core.INotifier {
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		return core.NewSmtpNotifier(core.SmtpConfig{
+			Addr:     addr,
+			From:     os.Getenv("SMTP_FROM"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		})
+	}
+	utils.Logger.Info("SMTP_ADDR is not set, notifications will only be logged")
+	return core.NewLogNotifier()
+}
+
+// loadTrustedProxies reads TRUSTED_PROXIES, a comma separated list of addresses or CIDRs
+// whose X-Forwarded-For headers are trusted for the client address. By default no proxy
+// is trusted and the address of the connection is used.
+func loadTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// loadDuration reads a duration such as "720h" from the environment, falling back to
+// fallback when the variable is not set.
+func loadDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		utils.Logger.Fatal(name + " must be a duration such as 720h")
+	}
+	return duration
+}
+
+// loadEventPublisher selects the message bus domain events are published to with
+// EVENT_PUBLISHER: "nats" (NATS_URL, NATS_SUBJECT_PREFIX), "kafka" (KAFKA_BROKERS as a
+// comma separated list, KAFKA_TOPIC) or, by default, the in-memory publisher.
+func loadEventPublisher() core.IEventPublisher {
+	switch os.Getenv("EVENT_PUBLISHER") {
+	case "nats":
+		publisher, err := core.NewNatsPublisher(core.NatsConfig{
+			URL:           os.Getenv("NATS_URL"),
+			SubjectPrefix: os.Getenv("NATS_SUBJECT_PREFIX"),
+		})
+		if err != nil {
+			utils.Logger.Fatal(err)
+		}
+		utils.Logger.Info("publishing events to NATS")
+		return publisher
+	case "kafka":
+		utils.Logger.Info("publishing events to Kafka")
+		return core.NewKafkaPublisher(core.KafkaConfig{
+			Brokers: strings.Split(os.Getenv("KAFKA_BROKERS"), ","),
+			Topic:   os.Getenv("KAFKA_TOPIC"),
+		})
+	case "", "memory":
+		return core.NewMemoryPublisher()
+	default:
+		utils.Logger.Fatal("EVENT_PUBLISHER must be memory, nats or kafka")
+		return nil
+	}
+}
+
+// loadHookRunner registers an HTTP hook for every hook point whose URL is set:
+// HOOK_PRE_REGISTRATION_URL, HOOK_PRE_TOKEN_URL and HOOK_POST_DELETION_URL. Requests
+// are signed with HOOK_SECRET and bounded by HOOK_TIMEOUT. Pre hooks fail closed
+// unless HOOK_PRE_REGISTRATION_FAIL_OPEN or HOOK_PRE_TOKEN_FAIL_OPEN is "true".
+func loadHookRunner() core.IHookRunner {
+	hookRunner := core.NewHookRunner()
+	timeout := loadDuration("HOOK_TIMEOUT", 2*time.Second)
+	for _, point := range []string{entities.HookPreRegistration, entities.HookPreToken, entities.HookPostDeletion} {
+		prefix := "HOOK_" + strings.ToUpper(strings.ReplaceAll(point, "-", "_"))
+		url := os.Getenv(prefix + "_URL")
+		if url == "" {
+			continue
+		}
+		hookRunner.Register(point, url, core.NewHttpHook(core.HttpHookConfig{
+			URL:    url,
+			Secret: os.Getenv("HOOK_SECRET"),
+		}), core.HookPolicy{
+			Timeout:  timeout,
+			FailOpen: os.Getenv(prefix+"_FAIL_OPEN") == "true",
+		})
+		utils.Logger.Info("HTTP hook configured", "point: ", point)
+	}
+	return hookRunner
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) GetUsers(c *gin.Context) {
+	var query entities.UserQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.userService.GetUsers(c, &query)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) GetUser(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.userService.GetUser(c, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) ChangeUserRole(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.UserRoleInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.userService.ChangeRole(c, id, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) ChangeUserStatus(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.UserStatusInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.userService.ChangeStatus(c, id, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) RestoreUser(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.userService.RestoreUser(c, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+// UpdateUserProfile applies the JSON Merge Patch in the body to the profile of a user,
+// admin-only attributes included.
+func (s *Controllers) UpdateUserProfile(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.userService.UpdateUserProfile(c, id, patch)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
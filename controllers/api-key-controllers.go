@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateApiKey(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.ApiKeyInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.apiKeyService.CreateKey(c, organizationId, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetApiKeys(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.apiKeyService.GetKeys(c, organizationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) RotateApiKey(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.ApiKeyRotateInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.apiKeyService.RotateKey(c, organizationId, c.Param("keyId"), &input)
+		if err != nil {
+			c.JSON(404, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) DeleteApiKey(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.apiKeyService.DeleteKey(c, organizationId, c.Param("keyId"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) GetApiKeyUsages(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.apiKeyService.GetUsages(c, organizationId, c.Param("keyId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
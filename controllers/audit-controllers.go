@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) GetAuditEvents(c *gin.Context) {
+	var query entities.AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.auditService.GetEvents(c, &query)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+// ExportAuditEvents streams the matching audit events as newline delimited JSON.
+func (s *Controllers) ExportAuditEvents(c *gin.Context) {
+	var query entities.AuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", "attachment; filename=\"audit-events.ndjson\"")
+	c.Status(200)
+	err := s.auditService.ExportEvents(c, &query, c.Writer)
+	if err != nil {
+		// the status is already sent, so the export can only be cut short
+		_ = c.Error(err)
+	}
+}
+
+func (s *Controllers) VerifyAuditLog(c *gin.Context) {
+	res, err := s.auditService.Verify(c)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
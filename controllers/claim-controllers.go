@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateClaimTemplate(c *gin.Context) {
+	var template entities.ClaimTemplate
+	if err := c.ShouldBind(&template); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.claimService.CreateTemplate(c, &template)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetClaimTemplates(c *gin.Context) {
+	res, err := s.claimService.GetTemplates(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) UpdateClaimTemplate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var template entities.ClaimTemplate
+	if err := c.ShouldBind(&template); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		template.ID = id
+		res, err := s.claimService.UpdateTemplate(c, &template)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) DeleteClaimTemplate(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.claimService.DeleteTemplate(c, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
@@ -1,25 +1,66 @@
 package controllers
 
 import (
+	"errors"
+
 	"github.com/draco121/horizon/constants"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
 
 	"github.com/draco121/horizon/models"
 	"shield/core"
+	"shield/entities"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Controllers struct {
-	authenticationService core.IAuthenticationService
-	userService           core.IUserService
+	authenticationService      core.IAuthenticationService
+	userService                core.IUserService
+	tokenExchangeService       core.ITokenExchangeService
+	oidcService                core.IOidcService
+	samlService                core.ISamlService
+	identityService            core.IIdentityService
+	personalAccessTokenService core.IPersonalAccessTokenService
+	apiKeyService              core.IApiKeyService
+	roleService                core.IRoleService
+	policyService              core.IPolicyService
+	organizationService        core.IOrganizationService
+	invitationService          core.IInvitationService
+	groupService               core.IGroupService
+	scimService                core.IScimService
+	exportService              core.IExportService
+	auditService               core.IAuditService
+	loginHistoryService        core.ILoginHistoryService
+	webhookService             core.IWebhookService
+	claimService               core.IClaimService
+	profileService             core.IProfileService
+	emailChangeService         core.IEmailChangeService
 }
 
-func NewControllers(authenticationService core.IAuthenticationService, userService core.IUserService) Controllers {
+func NewControllers(authenticationService core.IAuthenticationService, userService core.IUserService, tokenExchangeService core.ITokenExchangeService, oidcService core.IOidcService, samlService core.ISamlService, identityService core.IIdentityService, personalAccessTokenService core.IPersonalAccessTokenService, apiKeyService core.IApiKeyService, roleService core.IRoleService, policyService core.IPolicyService, organizationService core.IOrganizationService, invitationService core.IInvitationService, groupService core.IGroupService, scimService core.IScimService, exportService core.IExportService, auditService core.IAuditService, loginHistoryService core.ILoginHistoryService, webhookService core.IWebhookService, claimService core.IClaimService, profileService core.IProfileService, emailChangeService core.IEmailChangeService) Controllers {
 	c := Controllers{
-		authenticationService: authenticationService,
-		userService:           userService,
+		authenticationService:      authenticationService,
+		userService:                userService,
+		tokenExchangeService:       tokenExchangeService,
+		oidcService:                oidcService,
+		samlService:                samlService,
+		identityService:            identityService,
+		personalAccessTokenService: personalAccessTokenService,
+		apiKeyService:              apiKeyService,
+		roleService:                roleService,
+		policyService:              policyService,
+		organizationService:        organizationService,
+		invitationService:          invitationService,
+		groupService:               groupService,
+		scimService:                scimService,
+		exportService:              exportService,
+		auditService:               auditService,
+		loginHistoryService:        loginHistoryService,
+		webhookService:             webhookService,
+		claimService:               claimService,
+		profileService:             profileService,
+		emailChangeService:         emailChangeService,
 	}
 	return c
 }
@@ -31,8 +72,20 @@ func (s *Controllers) Login(c *gin.Context) {
 			"message": err.Error(),
 		})
 	} else {
+		// claim templates for ?audience= are mapped into the tokens of the session
+		c.Set("Audience", c.Query("audience"))
 		res, err := s.authenticationService.PasswordLogin(c, &loginInput)
-		if err != nil {
+		if code := accountStatusCode(err); code != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": err.Error(),
+				"code":    code,
+			})
+		} else if status, code := hookErrorStatus(err); code != "" {
+			c.JSON(status, gin.H{
+				"message": err.Error(),
+				"code":    code,
+			})
+		} else if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"message": err.Error(),
 			})
@@ -46,7 +99,17 @@ func (s *Controllers) RefreshLogin(c *gin.Context) {
 	refreshToken := c.GetHeader("refreshToken")
 	if refreshToken != "" {
 		result, err := s.authenticationService.RefreshLogin(c, refreshToken)
-		if err != nil {
+		if code := accountStatusCode(err); code != "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": err.Error(),
+				"code":    code,
+			})
+		} else if status, code := hookErrorStatus(err); code != "" {
+			c.JSON(status, gin.H{
+				"message": err.Error(),
+				"code":    code,
+			})
+		} else if err != nil {
 			c.JSON(http.StatusForbidden, gin.H{
 				"message": err.Error(),
 			})
@@ -56,6 +119,36 @@ func (s *Controllers) RefreshLogin(c *gin.Context) {
 	}
 }
 
+// accountStatusCode returns the machine readable code of an account status error, or
+// an empty string when err is not one.
+func accountStatusCode(err error) string {
+	switch {
+	case errors.Is(err, core.ErrAccountSuspended):
+		return "account_suspended"
+	case errors.Is(err, core.ErrAccountDisabled):
+		return "account_disabled"
+	case errors.Is(err, core.ErrAccountPending):
+		return "account_pending"
+	case errors.Is(err, core.ErrAccountDeleted):
+		return "account_deleted"
+	default:
+		return ""
+	}
+}
+
+// hookErrorStatus returns the status and machine readable code of an error returned by
+// a pre hook, or an empty code when err is not one.
+func hookErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, core.ErrHookDenied):
+		return http.StatusForbidden, "hook_denied"
+	case errors.Is(err, core.ErrHookUnavailable):
+		return http.StatusServiceUnavailable, "hook_unavailable"
+	default:
+		return 0, ""
+	}
+}
+
 func (s *Controllers) Logout(c *gin.Context) {
 	token := c.GetHeader("Authentication")
 	err := s.authenticationService.Logout(c, token)
@@ -67,15 +160,29 @@ func (s *Controllers) Logout(c *gin.Context) {
 }
 
 func (s *Controllers) CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBind(&user); err != nil {
+	var input entities.RegistrationInput
+	if err := c.ShouldBind(&input); err != nil {
 		c.JSON(400, gin.H{
 			"message": err.Error(),
 		})
 	} else {
-		user.Role = constants.Tenant
-		res, err := s.userService.CreateUser(c, &user)
-		if err != nil {
+		user := models.User{
+			Email:     input.Email,
+			FirstName: input.FirstName,
+			LastName:  input.LastName,
+			Password:  input.Password,
+			Role:      constants.Tenant,
+		}
+		res, err := s.userService.CreateUser(c, &user, &entities.ProfileDocument{
+			Profile:    input.Profile,
+			Attributes: input.Attributes,
+		})
+		if status, code := hookErrorStatus(err); code != "" {
+			c.JSON(status, gin.H{
+				"message": err.Error(),
+				"code":    code,
+			})
+		} else if err != nil {
 			c.JSON(409, gin.H{
 				"message": err.Error(),
 			})
@@ -96,22 +203,21 @@ func (s *Controllers) GetUserProfile(c *gin.Context) {
 	}
 }
 
+// UpdateUser applies the JSON Merge Patch (RFC 7396) in the body to the profile of the caller.
 func (s *Controllers) UpdateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBind(&user); err != nil {
+	patch, err := c.GetRawData()
+	if err != nil {
 		c.JSON(400, gin.H{
 			"message": err.Error(),
 		})
 	} else {
-		res, err := s.userService.UpdateUser(c, &user)
+		res, err := s.userService.UpdateUser(c, patch)
 		if err != nil {
-			c.JSON(404, gin.H{
+			c.JSON(400, gin.H{
 				"message": err.Error(),
 			})
 		} else {
-			c.JSON(201, gin.H{
-				"result": res,
-			})
+			c.JSON(200, res)
 		}
 	}
 }
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) RequestEmailChange(c *gin.Context) {
+	var input entities.EmailChangeInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.emailChangeService.RequestChange(c, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(202, res)
+		}
+	}
+}
+
+func (s *Controllers) ConfirmEmailChange(c *gin.Context) {
+	var input entities.EmailChangeTokenInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.emailChangeService.ConfirmChange(c, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) RevertEmailChange(c *gin.Context) {
+	var input entities.EmailChangeTokenInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.emailChangeService.RevertChange(c, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) RequestDataExport(c *gin.Context) {
+	res, err := s.exportService.RequestExport(c)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(202, res)
+	}
+}
+
+func (s *Controllers) GetDataExport(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.exportService.GetExport(c, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+// DownloadDataExport serves the archive of a completed export as a JSON attachment.
+func (s *Controllers) DownloadDataExport(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.exportService.GetExport(c, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else if res.Status != entities.DataExportCompleted {
+		c.JSON(409, gin.H{
+			"message": "export is " + res.Status,
+		})
+	} else {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"shield-export-%s.json\"", res.ID.Hex()))
+		c.Data(200, "application/json", res.Archive)
+	}
+}
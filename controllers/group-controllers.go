@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateGroup(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var group entities.Group
+	if err := c.ShouldBind(&group); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.groupService.CreateGroup(c, organizationId, &group)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetGroups(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.groupService.GetGroups(c, organizationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) UpdateGroup(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("groupId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var group entities.Group
+	if err := c.ShouldBind(&group); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		group.ID = id
+		res, err := s.groupService.UpdateGroup(c, organizationId, &group)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) DeleteGroup(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("groupId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.groupService.DeleteGroup(c, organizationId, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) GetGroupMembers(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("groupId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.groupService.GetMembers(c, organizationId, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) AddGroupMember(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("groupId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.GroupMemberInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.groupService.AddMember(c, organizationId, id, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) RemoveGroupMember(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("groupId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	memberId, err := primitive.ObjectIDFromHex(c.Param("memberId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.groupService.RemoveMember(c, organizationId, id, memberId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) GetEffectiveGroups(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	userId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.groupService.GetEffectiveGroups(c, organizationId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
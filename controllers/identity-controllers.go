@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/gin-gonic/gin"
+)
+
+// federationCookie carries the binding of an external login flow from the redirect to the
+// identity provider back to the callback. SAML responses are posted cross site, so the
+// cookie is SameSite=None, which browsers only accept on Secure cookies.
+const federationCookie = "shield_federation"
+
+// federationCookieMaxAge matches the lifetime of the login states, in seconds.
+const federationCookieMaxAge = 10 * 60
+
+func setFederationCookie(c *gin.Context, binding string) {
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(federationCookie, binding, federationCookieMaxAge, "/v1/", "", true, true)
+}
+
+// takeFederationCookie returns the binding of the flow and clears the cookie, since
+// every login state can only be used once.
+func takeFederationCookie(c *gin.Context) string {
+	binding, _ := c.Cookie(federationCookie)
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(federationCookie, "", -1, "/v1/", "", true, true)
+	return binding
+}
+
+func (s *Controllers) GetIdentities(c *gin.Context) {
+	res, err := s.identityService.GetIdentities(c)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) UnlinkIdentity(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.identityService.UnlinkIdentity(c, id)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+// LinkOidcIdentity returns the provider URL instead of redirecting, since the caller
+// authenticates with a header a browser redirect would not carry. The request has to be
+// made by the browser completing the flow, with credentials, so it keeps the cookie.
+func (s *Controllers) LinkOidcIdentity(c *gin.Context) {
+	redirect, err := s.oidcService.LinkURL(c, c.Param("provider"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		setFederationCookie(c, redirect.Binding)
+		c.JSON(200, gin.H{
+			"url": redirect.URL,
+		})
+	}
+}
+
+func (s *Controllers) LinkSamlIdentity(c *gin.Context) {
+	redirect, err := s.samlService.LinkURL(c, c.Param("connection"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		setFederationCookie(c, redirect.Binding)
+		c.JSON(200, gin.H{
+			"url": redirect.URL,
+		})
+	}
+}
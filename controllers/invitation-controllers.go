@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateInvitation(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.InvitationInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.invitationService.CreateInvitation(c, organizationId, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetInvitations(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.invitationService.GetInvitations(c, organizationId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) RevokeInvitation(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("invitationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.invitationService.RevokeInvitation(c, organizationId, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) AcceptInvitation(c *gin.Context) {
+	var input entities.InvitationAcceptInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.invitationService.AcceptInvitation(c, &input)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
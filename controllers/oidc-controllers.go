@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"net/http"
+
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateIdentityProvider(c *gin.Context) {
+	var provider entities.IdentityProvider
+	if err := c.ShouldBind(&provider); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.oidcService.CreateProvider(c, &provider)
+		if err != nil {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetIdentityProviders(c *gin.Context) {
+	res, err := s.oidcService.GetProviders(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) DeleteIdentityProvider(c *gin.Context) {
+	_, err := s.oidcService.DeleteProvider(c, c.Param("provider"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) OidcAuthorize(c *gin.Context) {
+	redirect, err := s.oidcService.AuthorizationURL(c, c.Param("provider"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		setFederationCookie(c, redirect.Binding)
+		c.Redirect(http.StatusFound, redirect.URL)
+	}
+}
+
+func (s *Controllers) OidcCallback(c *gin.Context) {
+	var input entities.OidcCallbackInput
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.oidcService.Callback(c, c.Param("provider"), takeFederationCookie(c), &input)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(http.StatusOK, res)
+		}
+	}
+}
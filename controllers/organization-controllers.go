@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateOrganization(c *gin.Context) {
+	var organization entities.Organization
+	if err := c.ShouldBind(&organization); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.organizationService.CreateOrganization(c, &organization)
+		if err != nil {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetOrganizations(c *gin.Context) {
+	res, err := s.organizationService.GetOrganizations(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) SwitchOrganization(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.authenticationService.SwitchOrganization(c, organizationId)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) GetOrganizationMembers(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.organizationService.GetMembers(c, organizationId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) UpdateOrganizationMember(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	userId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.MembershipInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.organizationService.UpdateMember(c, organizationId, userId, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
+
+func (s *Controllers) RemoveOrganizationMember(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	userId, err := primitive.ObjectIDFromHex(c.Param("userId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.organizationService.RemoveMember(c, organizationId, userId)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) Authorize(c *gin.Context) {
+	var input entities.AuthorizationInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		if input.Token == "" {
+			input.Token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		res, err := s.policyService.Authorize(c, &input)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(http.StatusOK, res)
+		}
+	}
+}
+
+func (s *Controllers) SavePolicy(c *gin.Context) {
+	var policy entities.Policy
+	if err := c.ShouldBind(&policy); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.policyService.SavePolicy(c, &policy)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetPolicies(c *gin.Context) {
+	res, err := s.policyService.GetPolicies(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) GetPolicyVersions(c *gin.Context) {
+	res, err := s.policyService.GetPolicyVersions(c, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) DeletePolicy(c *gin.Context) {
+	err := s.policyService.DeletePolicy(c, c.Param("name"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) DryRunPolicy(c *gin.Context) {
+	var input entities.PolicyDryRunInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.policyService.DryRun(c, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"net/http"
+
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) GetProfileSchema(c *gin.Context) {
+	res, err := s.profileService.GetSchema(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) UpdateProfileSchema(c *gin.Context) {
+	var schema entities.ProfileSchema
+	if err := c.ShouldBind(&schema); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.profileService.UpdateSchema(c, &schema)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(200, res)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateSamlConnection(c *gin.Context) {
+	var connection entities.SamlConnection
+	if err := c.ShouldBind(&connection); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.samlService.CreateConnection(c, &connection)
+		if err != nil {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetSamlConnections(c *gin.Context) {
+	res, err := s.samlService.GetConnections(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) DeleteSamlConnection(c *gin.Context) {
+	_, err := s.samlService.DeleteConnection(c, c.Param("connection"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) SamlMetadata(c *gin.Context) {
+	res, err := s.samlService.Metadata(c, c.Param("connection"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Data(http.StatusOK, "application/samlmetadata+xml", res)
+	}
+}
+
+func (s *Controllers) SamlLogin(c *gin.Context) {
+	redirect, err := s.samlService.LoginURL(c, c.Param("connection"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		setFederationCookie(c, redirect.Binding)
+		c.Redirect(http.StatusFound, redirect.URL)
+	}
+}
+
+func (s *Controllers) SamlAcs(c *gin.Context) {
+	res, err := s.samlService.ConsumeAssertion(c, c.Param("connection"), takeFederationCookie(c), c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(http.StatusOK, res)
+	}
+}
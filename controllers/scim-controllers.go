@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/core"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) CreateScimToken(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	var input entities.ScimTokenInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.scimService.CreateToken(c, organizationId, &input)
+		if err != nil {
+			c.JSON(400, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) GetScimTokens(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	res, err := s.scimService.GetTokens(c, organizationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.JSON(200, res)
+	}
+}
+
+func (s *Controllers) DeleteScimToken(c *gin.Context) {
+	organizationId, err := primitive.ObjectIDFromHex(c.Param("organizationId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(c.Param("tokenId"))
+	if err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	_, err = s.scimService.DeleteToken(c, organizationId, id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
+
+func (s *Controllers) GetScimUsers(c *gin.Context) {
+	var query entities.ScimListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.GetUsers(c, &query)
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) GetScimUser(c *gin.Context) {
+	res, err := s.scimService.GetUser(c, c.Param("id"))
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) CreateScimUser(c *gin.Context) {
+	var user entities.ScimUser
+	if err := c.ShouldBindJSON(&user); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.CreateUser(c, &user)
+	scimRespond(c, http.StatusCreated, res, err)
+}
+
+func (s *Controllers) ReplaceScimUser(c *gin.Context) {
+	var user entities.ScimUser
+	if err := c.ShouldBindJSON(&user); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.ReplaceUser(c, c.Param("id"), &user, c.GetHeader("If-Match"))
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) PatchScimUser(c *gin.Context) {
+	var patch entities.ScimPatchRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.PatchUser(c, c.Param("id"), &patch, c.GetHeader("If-Match"))
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) DeleteScimUser(c *gin.Context) {
+	err := s.scimService.DeleteUser(c, c.Param("id"), c.GetHeader("If-Match"))
+	if err != nil {
+		scimFailure(c, err)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (s *Controllers) GetScimGroups(c *gin.Context) {
+	var query entities.ScimListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.GetGroups(c, &query)
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) GetScimGroup(c *gin.Context) {
+	res, err := s.scimService.GetGroup(c, c.Param("id"))
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) CreateScimGroup(c *gin.Context) {
+	var group entities.ScimGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.CreateGroup(c, &group)
+	scimRespond(c, http.StatusCreated, res, err)
+}
+
+func (s *Controllers) ReplaceScimGroup(c *gin.Context) {
+	var group entities.ScimGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.ReplaceGroup(c, c.Param("id"), &group, c.GetHeader("If-Match"))
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) PatchScimGroup(c *gin.Context) {
+	var patch entities.ScimPatchRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	res, err := s.scimService.PatchGroup(c, c.Param("id"), &patch, c.GetHeader("If-Match"))
+	scimRespond(c, http.StatusOK, res, err)
+}
+
+func (s *Controllers) DeleteScimGroup(c *gin.Context) {
+	err := s.scimService.DeleteGroup(c, c.Param("id"), c.GetHeader("If-Match"))
+	if err != nil {
+		scimFailure(c, err)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// scimRespond writes a SCIM resource with its ETag, answering 304 when the client
+// already holds the current version.
+func scimRespond(c *gin.Context, status int, resource interface{}, err error) {
+	if err != nil {
+		scimFailure(c, err)
+		return
+	}
+	c.Header("Content-Type", "application/scim+json")
+	if etag := core.ScimETag(resource); etag != "" {
+		c.Header("ETag", etag)
+		if c.Request.Method == http.MethodGet && c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.JSON(status, resource)
+}
+
+func scimFailure(c *gin.Context, err error) {
+	status, scimType := http.StatusBadRequest, "invalidSyntax"
+	var scimError *core.ScimError
+	if errors.As(err, &scimError) {
+		status, scimType = scimError.Status, scimError.ScimType
+	}
+	body := gin.H{
+		"schemas": []string{entities.ScimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  err.Error(),
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	c.Header("Content-Type", "application/scim+json")
+	c.JSON(status, body)
+}
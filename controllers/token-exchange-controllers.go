@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"shield/core"
+	"shield/entities"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Controllers) Token(c *gin.Context) {
+	var input entities.TokenExchangeInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             core.ErrInvalidRequest.Error(),
+			"error_description": err.Error(),
+		})
+		return
+	}
+	if clientId, clientSecret, ok := c.Request.BasicAuth(); ok {
+		input.ClientId = clientId
+		input.ClientSecret = clientSecret
+	}
+	res, err := s.tokenExchangeService.Exchange(c, &input)
+	if err != nil {
+		status := http.StatusBadRequest
+		code := err.Error()
+		if errors.Is(err, core.ErrInvalidClient) {
+			status = http.StatusUnauthorized
+		} else if !core.IsOAuthError(err) {
+			status = http.StatusInternalServerError
+			code = "server_error"
+		}
+		c.JSON(status, gin.H{
+			"error": code,
+		})
+	} else {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func (s *Controllers) CreateTokenExchangeClient(c *gin.Context) {
+	var input entities.TokenExchangeClientInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		res, err := s.tokenExchangeService.CreateClient(c, &input)
+		if err != nil {
+			c.JSON(409, gin.H{
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(201, res)
+		}
+	}
+}
+
+func (s *Controllers) DeleteTokenExchangeClient(c *gin.Context) {
+	_, err := s.tokenExchangeService.DeleteClient(c, c.Param("clientId"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+	} else {
+		c.Status(204)
+	}
+}
@@ -0,0 +1,255 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+const apiKeyUsageLimit = 100
+
+type IApiKeyService interface {
+	CreateKey(ctx context.Context, organizationId primitive.ObjectID, input *entities.ApiKeyInput) (*entities.ApiKeyOutput, error)
+	GetKeys(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ApiKey, error)
+	RotateKey(ctx context.Context, organizationId primitive.ObjectID, keyId string, input *entities.ApiKeyRotateInput) (*entities.ApiKeyOutput, error)
+	DeleteKey(ctx context.Context, organizationId primitive.ObjectID, keyId string) (*entities.ApiKey, error)
+	GetUsages(ctx context.Context, organizationId primitive.ObjectID, keyId string) ([]entities.ApiKeyUsage, error)
+	VerifyKey(ctx context.Context, key string) (*entities.Principal, error)
+}
+
+type apiKeyService struct {
+	IApiKeyService
+	repo                   repository.IApiKeyRepository
+	organizationRepository repository.IOrganizationRepository
+	client                 *mongo.Client
+}
+
+func NewApiKeyService(client *mongo.Client, repository repository.IApiKeyRepository, organizationRepository repository.IOrganizationRepository) IApiKeyService {
+	return &apiKeyService{
+		repo:                   repository,
+		organizationRepository: organizationRepository,
+		client:                 client,
+	}
+}
+
+// CreateKey issues a key for the organization. Its scopes have to be covered by the
+// permissions of the caller, since the key holds them on its own.
+func (s *apiKeyService) CreateKey(ctx context.Context, organizationId primitive.ObjectID, input *entities.ApiKeyInput) (*entities.ApiKeyOutput, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.organizationRepository.FindOneById(ctx, organizationId); err != nil {
+		return nil, fmt.Errorf("organization not found")
+	}
+	permissions, _ := ctx.Value("Permissions").([]string)
+	for _, scope := range input.Scopes {
+		if !PermissionGranted(permissions, scope) {
+			return nil, fmt.Errorf("scope %s exceeds the permissions of the caller", scope)
+		}
+	}
+	for _, allowed := range input.AllowedIPs {
+		if _, err := parseIPRange(allowed); err != nil {
+			return nil, err
+		}
+	}
+	keyId, err := tokens.GenerateSecret("", 6)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := tokens.GenerateSecret("", 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate api key", "error: ", err.Error())
+		return nil, err
+	}
+	key := entities.ApiKey{
+		KeyId:          keyId,
+		OrganizationId: organizationId,
+		Name:           input.Name,
+		SecretHash:     tokens.HashSecret(secret),
+		Scopes:         input.Scopes,
+		AllowedIPs:     input.AllowedIPs,
+		ExpiresAt:      input.ExpiresAt,
+		CreatedAt:      time.Now(),
+	}
+	result, err := s.repo.InsertOne(ctx, &key)
+	if err != nil {
+		utils.Logger.Error("failed to insert api key", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created api key")
+	return &entities.ApiKeyOutput{
+		ApiKey: *result,
+		Key:    formatApiKey(keyId, secret),
+	}, nil
+}
+
+func (s *apiKeyService) GetKeys(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ApiKey, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindManyByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find api keys", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+// RotateKey issues a new secret for the key. The previous secret keeps working for
+// the requested overlap so integrations can be redeployed without downtime.
+func (s *apiKeyService) RotateKey(ctx context.Context, organizationId primitive.ObjectID, keyId string, input *entities.ApiKeyRotateInput) (*entities.ApiKeyOutput, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	key, err := s.repo.FindOneByKeyId(ctx, keyId)
+	if err != nil || key.OrganizationId != organizationId {
+		return nil, fmt.Errorf("api key not found")
+	}
+	secret, err := tokens.GenerateSecret("", 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate api key", "error: ", err.Error())
+		return nil, err
+	}
+	now := time.Now()
+	previousExpiresAt := now.Add(time.Duration(input.OverlapSeconds) * time.Second)
+	key.PreviousSecretHash = key.SecretHash
+	key.PreviousExpiresAt = &previousExpiresAt
+	key.SecretHash = tokens.HashSecret(secret)
+	key.RotatedAt = &now
+	result, err := s.repo.UpdateSecret(ctx, key)
+	if err != nil {
+		utils.Logger.Error("failed to rotate api key", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("rotated api key")
+	return &entities.ApiKeyOutput{
+		ApiKey: *result,
+		Key:    formatApiKey(keyId, secret),
+	}, nil
+}
+
+func (s *apiKeyService) DeleteKey(ctx context.Context, organizationId primitive.ObjectID, keyId string) (*entities.ApiKey, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.DeleteOne(ctx, organizationId, keyId)
+	if err != nil {
+		utils.Logger.Error("failed to delete api key", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("deleted api key")
+	return result, nil
+}
+
+func (s *apiKeyService) GetUsages(ctx context.Context, organizationId primitive.ObjectID, keyId string) ([]entities.ApiKeyUsage, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindUsages(ctx, organizationId, keyId, apiKeyUsageLimit)
+	if err != nil {
+		utils.Logger.Error("failed to find api key usages", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+// VerifyKey checks an api key and records the attempt. The returned principal is the
+// key itself, not a user, bound to the organization of the key and holding its scopes.
+func (s *apiKeyService) VerifyKey(ctx context.Context, key string) (*entities.Principal, error) {
+	keyId, secret, ok := strings.Cut(strings.TrimPrefix(key, entities.ApiKeyPrefix), "_")
+	if !ok {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	apiKey, err := s.repo.FindOneByKeyId(ctx, keyId)
+	if err != nil {
+		utils.Logger.Info("unknown api key")
+		return nil, fmt.Errorf("invalid api key")
+	}
+	now := time.Now()
+	usage := entities.ApiKeyUsage{
+		KeyId:          apiKey.KeyId,
+		OrganizationId: apiKey.OrganizationId,
+		IP:             clientIP(ctx),
+		CreatedAt:      now,
+	}
+	validSecret := tokens.CheckSecretHash(secret, apiKey.SecretHash) ||
+		(apiKey.PreviousExpiresAt != nil && now.Before(*apiKey.PreviousExpiresAt) && tokens.CheckSecretHash(secret, apiKey.PreviousSecretHash))
+	if !validSecret {
+		usage.Reason = "invalid secret"
+	} else if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+		usage.Reason = "expired"
+	} else if !ipAllowed(apiKey.AllowedIPs, usage.IP) {
+		usage.Reason = "ip not allowed"
+	} else if _, err := s.organizationRepository.FindOneById(ctx, apiKey.OrganizationId); err != nil {
+		usage.Reason = "organization not found"
+	} else {
+		usage.Allowed = true
+	}
+	err = s.repo.InsertUsage(ctx, &usage)
+	if err != nil {
+		utils.Logger.Error("failed to record api key usage", "error: ", err.Error())
+		return nil, err
+	}
+	if !usage.Allowed {
+		utils.Logger.Info("rejected api key: ", usage.Reason)
+		return nil, fmt.Errorf("invalid api key")
+	}
+	err = s.repo.UpdateLastUsed(ctx, apiKey.ID, now)
+	if err != nil {
+		utils.Logger.Error("failed to update api key", "error: ", err.Error())
+	}
+	return &entities.Principal{
+		JwtCustomClaims: models.JwtCustomClaims{
+			Email:  entities.ApiKeyPrefix + apiKey.KeyId,
+			UserId: apiKey.ID,
+			Role:   constants.Tenant,
+		},
+		OrganizationId: apiKey.OrganizationId,
+		Scopes:         append([]string{}, apiKey.Scopes...),
+	}, nil
+}
+
+func formatApiKey(keyId string, secret string) string {
+	return entities.ApiKeyPrefix + keyId + "_" + secret
+}
+
+// ipAllowed reports whether ip is within the allowlist; an empty allowlist allows any address.
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	for _, entry := range allowed {
+		prefix, err := parseIPRange(entry)
+		if err == nil && prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIPRange accepts either a CIDR range or a single address.
+func parseIPRange(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		return netip.ParsePrefix(entry)
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return addr.Prefix(addr.BitLen())
+}
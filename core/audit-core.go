@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+type IAuditService interface {
+	Record(ctx context.Context, event *entities.AuditEvent, outcome error)
+	GetEvents(ctx context.Context, query *entities.AuditQuery) (*entities.AuditPage, error)
+	ExportEvents(ctx context.Context, query *entities.AuditQuery, w io.Writer) error
+	Verify(ctx context.Context) (*entities.AuditVerification, error)
+}
+
+type auditService struct {
+	IAuditService
+	repo   repository.IAuditRepository
+	client *mongo.Client
+	// mu serializes appends from this instance; the unique sequence index catches
+	// appends racing in from other instances.
+	mu sync.Mutex
+}
+
+func NewAuditService(client *mongo.Client, repository repository.IAuditRepository) IAuditService {
+	return &auditService{
+		repo:   repository,
+		client: client,
+	}
+}
+
+// Record appends an event to the audit log. The outcome is a failure when outcome is
+// not nil. Actor, organization, IP and user agent are taken from ctx when not set.
+// Recording never fails the audited operation; errors are only logged.
+func (s *auditService) Record(ctx context.Context, event *entities.AuditEvent, outcome error) {
+	event.Outcome = entities.AuditSuccess
+	if outcome != nil {
+		event.Outcome = entities.AuditFailure
+		event.Reason = outcome.Error()
+	}
+	if userId, ok := ctx.Value("UserId").(primitive.ObjectID); ok && event.Actor == nil {
+		event.Actor = &userId
+	}
+	if organizationId := activeOrganization(ctx); !organizationId.IsZero() && event.OrganizationId == nil {
+		event.OrganizationId = &organizationId
+	}
+	event.IP = clientIP(ctx)
+	event.UserAgent = userAgent(ctx)
+	// mongo keeps milliseconds, so the hash is computed over what is read back
+	event.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for attempt := 0; attempt < 5; attempt++ {
+		event.Sequence = 1
+		event.PreviousHash = ""
+		last, err := s.repo.FindLast(ctx)
+		if err == nil {
+			event.Sequence = last.Sequence + 1
+			event.PreviousHash = last.Hash
+		} else if !errors.Is(err, mongo.ErrNoDocuments) {
+			utils.Logger.Error("failed to find last audit event", "error: ", err.Error())
+			return
+		}
+		event.ID = primitive.NewObjectID()
+		event.Hash = auditHash(event)
+		_, err = s.repo.InsertOne(ctx, event)
+		if err == nil {
+			return
+		} else if !mongo.IsDuplicateKeyError(err) {
+			utils.Logger.Error("failed to insert audit event", "error: ", err.Error())
+			return
+		}
+	}
+	utils.Logger.Error("failed to insert audit event", "error: ", "sequence kept conflicting", "type: ", event.Type)
+}
+
+// GetEvents lists audit events, newest first. Callers other than root users only see
+// the events of their active organization.
+func (s *auditService) GetEvents(ctx context.Context, query *entities.AuditQuery) (*entities.AuditPage, error) {
+	if query.Limit == 0 {
+		query.Limit = 100
+	}
+	s.scope(ctx, query)
+	limit := query.Limit
+	query.Limit = limit + 1
+	events, err := s.repo.FindManyByQuery(ctx, query)
+	if err != nil {
+		utils.Logger.Error("failed to find audit events", "error: ", err.Error())
+		return nil, err
+	}
+	result := &entities.AuditPage{
+		Events: events,
+	}
+	if len(events) > limit {
+		result.Events = events[:limit]
+		result.NextCursor = strconv.FormatInt(events[limit-1].Sequence, 10)
+	}
+	return result, nil
+}
+
+// ExportEvents writes every event matching the query to w as newline delimited JSON,
+// newest first.
+func (s *auditService) ExportEvents(ctx context.Context, query *entities.AuditQuery, w io.Writer) error {
+	s.scope(ctx, query)
+	query.Limit = 1000
+	encoder := json.NewEncoder(w)
+	for {
+		events, err := s.repo.FindManyByQuery(ctx, query)
+		if err != nil {
+			utils.Logger.Error("failed to find audit events", "error: ", err.Error())
+			return err
+		}
+		for i := range events {
+			if err := encoder.Encode(&events[i]); err != nil {
+				return err
+			}
+		}
+		if len(events) < query.Limit {
+			return nil
+		}
+		query.Cursor = strconv.FormatInt(events[len(events)-1].Sequence, 10)
+	}
+}
+
+// Verify walks the whole audit log and checks that sequence numbers are contiguous and
+// that every event hashes to its stored hash and links to the one before it.
+func (s *auditService) Verify(ctx context.Context) (*entities.AuditVerification, error) {
+	result := &entities.AuditVerification{Valid: true}
+	var sequence int64
+	previousHash := ""
+	for {
+		events, err := s.repo.FindManyAfterSequence(ctx, sequence, 1000)
+		if err != nil {
+			utils.Logger.Error("failed to find audit events", "error: ", err.Error())
+			return nil, err
+		}
+		for i := range events {
+			event := &events[i]
+			reason := ""
+			if event.Sequence != sequence+1 {
+				reason = fmt.Sprintf("expected sequence %d", sequence+1)
+			} else if event.PreviousHash != previousHash {
+				reason = "previous hash does not match"
+			} else if auditHash(event) != event.Hash {
+				reason = "hash does not match"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenAt = &event.Sequence
+				result.Reason = reason
+				return result, nil
+			}
+			result.Checked++
+			sequence = event.Sequence
+			previousHash = event.Hash
+		}
+		if len(events) < 1000 {
+			return result, nil
+		}
+	}
+}
+
+// scope restricts the query of callers other than root users to their active
+// organization.
+func (s *auditService) scope(ctx context.Context, query *entities.AuditQuery) {
+	if claims, ok := ctx.Value("Claims").(*models.JwtCustomClaims); !ok || claims.Role != constants.Root {
+		query.OrganizationId = activeOrganization(ctx).Hex()
+	}
+}
+
+// auditHash hashes the JSON encoding of the event without its own hash.
+func auditHash(event *entities.AuditEvent) string {
+	unhashed := *event
+	unhashed.Hash = ""
+	data, _ := json.Marshal(&unhashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
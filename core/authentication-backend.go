@@ -0,0 +1,26 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"shield/entities"
+)
+
+var (
+	// ErrUnknownUser is returned by a backend that has no such user, letting
+	// PasswordLogin fall through to the next backend and finally the users collection.
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrInvalidCredentials is returned by a backend that knows the user but rejected the password.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrBackendUnavailable is returned by a backend that cannot be reached. PasswordLogin
+	// passes the login on like for ErrUnknownUser, so local users can still sign in.
+	ErrBackendUnavailable = errors.New("authentication backend unavailable")
+)
+
+// IAuthenticationBackend verifies credentials against a user store other than the
+// local users collection.
+type IAuthenticationBackend interface {
+	Name() string
+	Authenticate(ctx context.Context, email string, password string) (*entities.DirectoryUser, error)
+}
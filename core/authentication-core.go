@@ -2,40 +2,95 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
 	"time"
 
 	"github.com/draco121/horizon/jwt"
 	"github.com/draco121/horizon/models"
 	"github.com/draco121/horizon/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
 	"shield/repository"
+	"shield/tokens"
+)
+
+// Errors returned once the credentials of a user check out but the account may not be
+// used. They are never returned for wrong credentials, so they do not reveal accounts.
+var (
+	ErrAccountSuspended = errors.New("account suspended")
+	ErrAccountDisabled  = errors.New("account disabled")
+	ErrAccountPending   = errors.New("account pending activation")
+	ErrAccountDeleted   = errors.New("account pending deletion")
 )
 
 type IAuthenticationService interface {
 	PasswordLogin(ctx context.Context, loginInput *models.LoginInput) (*models.LoginOutput, error)
-	Authenticate(ctx context.Context, token string) (*models.JwtCustomClaims, error)
+	CreateLogin(ctx context.Context, user *models.User) (*models.LoginOutput, error)
+	Authenticate(ctx context.Context, token string) (*entities.Principal, error)
 	RefreshLogin(ctx context.Context, refreshToken string) (*models.LoginOutput, error)
 	Logout(ctx context.Context, token string) error
+	ActiveOrganization(ctx context.Context, principal *entities.Principal) (primitive.ObjectID, error)
+	SwitchOrganization(ctx context.Context, organizationId primitive.ObjectID) (*models.LoginOutput, error)
+	RevokeSessions(ctx context.Context, userId primitive.ObjectID) error
 }
 
 type authenticationService struct {
 	IAuthenticationService
-	authenticationRepository repository.IAuthenticationRepository
-	userRepository           repository.IUserRepository
-	client                   *mongo.Client
+	authenticationRepository      repository.IAuthenticationRepository
+	userRepository                repository.IUserRepository
+	userService                   IUserService
+	identityRepository            repository.IIdentityRepository
+	personalAccessTokenRepository repository.IPersonalAccessTokenRepository
+	organizationRepository        repository.IOrganizationRepository
+	groupRepository               repository.IGroupRepository
+	roleService                   IRoleService
+	apiKeyService                 IApiKeyService
+	auditService                  IAuditService
+	loginHistoryService           ILoginHistoryService
+	outboxRepository              repository.IOutboxRepository
+	hookRunner                    IHookRunner
+	claimService                  IClaimService
+	backends                      []IAuthenticationBackend
+	client                        *mongo.Client
 }
 
-func NewAuthenticationService(client *mongo.Client, authenticationRepository repository.IAuthenticationRepository, userRepository repository.IUserRepository) IAuthenticationService {
+func NewAuthenticationService(client *mongo.Client, authenticationRepository repository.IAuthenticationRepository, userRepository repository.IUserRepository, userService IUserService, identityRepository repository.IIdentityRepository, personalAccessTokenRepository repository.IPersonalAccessTokenRepository, organizationRepository repository.IOrganizationRepository, groupRepository repository.IGroupRepository, roleService IRoleService, apiKeyService IApiKeyService, auditService IAuditService, loginHistoryService ILoginHistoryService, outboxRepository repository.IOutboxRepository, hookRunner IHookRunner, claimService IClaimService, backends ...IAuthenticationBackend) IAuthenticationService {
 	return &authenticationService{
-		authenticationRepository: authenticationRepository,
-		userRepository:           userRepository,
-		client:                   client,
+		authenticationRepository:      authenticationRepository,
+		userRepository:                userRepository,
+		userService:                   userService,
+		identityRepository:            identityRepository,
+		personalAccessTokenRepository: personalAccessTokenRepository,
+		organizationRepository:        organizationRepository,
+		groupRepository:               groupRepository,
+		roleService:                   roleService,
+		apiKeyService:                 apiKeyService,
+		auditService:                  auditService,
+		loginHistoryService:           loginHistoryService,
+		outboxRepository:              outboxRepository,
+		hookRunner:                    hookRunner,
+		claimService:                  claimService,
+		backends:                      backends,
+		client:                        client,
 	}
 }
 
-func (s *authenticationService) PasswordLogin(ctx context.Context, loginInput *models.LoginInput) (*models.LoginOutput, error) {
+func (s *authenticationService) PasswordLogin(ctx context.Context, loginInput *models.LoginInput) (result *models.LoginOutput, err error) {
+	var target *primitive.ObjectID
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:     entities.AuditLogin,
+			Actor:    target,
+			Target:   target,
+			Metadata: map[string]string{"email": loginInput.Email},
+		}, err)
+		if target != nil {
+			s.loginHistoryService.Record(ctx, *target, entities.LoginMethodPassword, err)
+		}
+	}()
 	mongoSession, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo mongoSession", "error: ", err.Error())
@@ -47,48 +102,36 @@ func (s *authenticationService) PasswordLogin(ctx context.Context, loginInput *m
 		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
 		return nil, err
 	}
-	user, err := s.userRepository.FindOneByEmail(ctx, loginInput.Email)
+	user, err := s.backendLogin(ctx, loginInput)
+	if err != nil {
+		_ = mongoSession.AbortTransaction(ctx)
+		return nil, err
+	} else if user != nil {
+		target = &user.ID
+		result, err := s.createLogin(mongo.NewSessionContext(ctx, mongoSession), user)
+		if err != nil {
+			return nil, err
+		} else {
+			_ = mongoSession.CommitTransaction(ctx)
+			utils.Logger.Info("successfully authenticated with authentication backend")
+			return result, nil
+		}
+	}
+	user, err = s.userRepository.FindOneByEmail(ctx, loginInput.Email)
 	if err != nil {
 		utils.Logger.Error("failed to find user by email", "error: ", err.Error())
 		_ = mongoSession.AbortTransaction(ctx)
 		return nil, err
 	} else {
+		target = &user.ID
 		if utils.CheckPasswordHash(loginInput.Password, user.Password) {
-			session := models.Session{
-				UserId:    user.ID,
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-				ID:        primitive.NewObjectID(),
-			}
-			id, err := s.authenticationRepository.InsertOne(ctx, &session)
+			result, err := s.createLogin(mongo.NewSessionContext(ctx, mongoSession), user)
 			if err != nil {
-				utils.Logger.Error("failed to insert mongoSession", "error: ", err.Error())
 				return nil, err
 			} else {
-				claims := models.JwtCustomClaims{
-					Email:     user.Email,
-					UserId:    user.ID,
-					Role:      user.Role,
-					SessionId: id,
-				}
-				token, err := jwt.GenerateJWT(&claims)
-				if err != nil {
-					utils.Logger.Error("failed to generate JWT", "error: ", err.Error())
-					return nil, err
-				} else {
-					refreshToken, err := jwt.GenerateRefreshToken(id)
-					if err != nil {
-						utils.Logger.Error("failed to generate refreshToken", "error: ", err.Error())
-						return nil, err
-					} else {
-						_ = mongoSession.CommitTransaction(ctx)
-						utils.Logger.Info("successfully authenticated")
-						return &models.LoginOutput{
-							Token:        token,
-							RefreshToken: refreshToken,
-						}, nil
-					}
-				}
+				_ = mongoSession.CommitTransaction(ctx)
+				utils.Logger.Info("successfully authenticated")
+				return result, nil
 			}
 		} else {
 			utils.Logger.Info("Invalid email or password")
@@ -97,7 +140,124 @@ func (s *authenticationService) PasswordLogin(ctx context.Context, loginInput *m
 	}
 }
 
-func (s *authenticationService) Authenticate(ctx context.Context, token string) (*models.JwtCustomClaims, error) {
+// backendLogin consults the configured authentication backends in order. It returns
+// a nil user when none of them knows the email or can be reached, so the users
+// collection is checked next.
+func (s *authenticationService) backendLogin(ctx context.Context, loginInput *models.LoginInput) (*models.User, error) {
+	for _, backend := range s.backends {
+		directoryUser, err := backend.Authenticate(ctx, loginInput.Email, loginInput.Password)
+		if errors.Is(err, ErrUnknownUser) {
+			continue
+		} else if errors.Is(err, ErrBackendUnavailable) {
+			utils.Logger.Error("authentication backend unavailable", "backend: ", backend.Name(), "error: ", err.Error())
+			continue
+		} else if errors.Is(err, ErrInvalidCredentials) {
+			utils.Logger.Info("Invalid email or password")
+			return nil, fmt.Errorf("invalid credentials")
+		} else if err != nil {
+			utils.Logger.Error("authentication backend failed", "error: ", err.Error())
+			return nil, err
+		}
+		user, err := resolveExternalUser(ctx, s.identityRepository, s.userRepository, s.userService, directoryUser.Backend, directoryUser.Subject, directoryUser.Email, true, func() *models.User {
+			return &models.User{
+				Email:     directoryUser.Email,
+				FirstName: directoryUser.FirstName,
+				LastName:  directoryUser.LastName,
+				Role:      directoryUser.Role,
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		// the directory is authoritative for the role of its users, so group changes
+		// also reach tokens issued on refresh
+		if user.Role != directoryUser.Role {
+			_, err = s.userRepository.UpdateRole(ctx, user.ID, directoryUser.Role)
+			if err != nil {
+				utils.Logger.Error("failed to update role", "error: ", err.Error())
+				return nil, err
+			}
+			user.Role = directoryUser.Role
+		}
+		return user, nil
+	}
+	return nil, nil
+}
+
+// CreateLogin opens a session for a user who has already been authenticated by
+// other means, such as an external identity provider.
+func (s *authenticationService) CreateLogin(ctx context.Context, user *models.User) (*models.LoginOutput, error) {
+	mongoSession, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer mongoSession.EndSession(ctx)
+	err = mongoSession.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	result, err := s.createLogin(mongo.NewSessionContext(ctx, mongoSession), user)
+	if err != nil {
+		_ = mongoSession.AbortTransaction(ctx)
+		return nil, err
+	} else {
+		_ = mongoSession.CommitTransaction(ctx)
+		utils.Logger.Info("successfully authenticated")
+		return result, nil
+	}
+}
+
+// createLogin opens a session for the user. ctx should carry the mongo session of the
+// caller so the session and its event are written together.
+func (s *authenticationService) createLogin(ctx context.Context, user *models.User) (*models.LoginOutput, error) {
+	if err := s.checkStatus(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	session := entities.Session{
+		Session: models.Session{
+			UserId:    user.ID,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			ID:        primitive.NewObjectID(),
+		},
+		Audience: requestedAudience(ctx),
+	}
+	// new sessions act in the organization the user joined first
+	memberships, err := s.organizationRepository.FindMembershipsByUserId(ctx, user.ID)
+	if err != nil {
+		utils.Logger.Error("failed to find memberships", "error: ", err.Error())
+		return nil, err
+	} else if len(memberships) > 0 {
+		session.OrganizationId = memberships[0].OrganizationId
+	}
+	id, err := s.authenticationRepository.InsertOne(ctx, &session)
+	if err != nil {
+		utils.Logger.Error("failed to insert session", "error: ", err.Error())
+		return nil, err
+	} else if err := recordEvent(ctx, s.outboxRepository, entities.EventSessionCreated, user.ID, &session); err != nil {
+		return nil, err
+	} else {
+		token, err := s.accessToken(ctx, user, &session, entities.HookTriggerLogin)
+		if err != nil {
+			return nil, err
+		} else {
+			refreshToken, err := jwt.GenerateRefreshToken(id)
+			if err != nil {
+				utils.Logger.Error("failed to generate refreshToken", "error: ", err.Error())
+				return nil, err
+			} else {
+				return &models.LoginOutput{
+					Token:        token,
+					RefreshToken: refreshToken,
+				}, nil
+			}
+		}
+	}
+}
+
+func (s *authenticationService) Authenticate(ctx context.Context, token string) (*entities.Principal, error) {
 	session, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -109,6 +269,24 @@ func (s *authenticationService) Authenticate(ctx context.Context, token string)
 		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
 		return nil, err
 	}
+	if strings.HasPrefix(token, entities.ApiKeyPrefix) {
+		principal, err := s.apiKeyService.VerifyKey(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		_ = session.CommitTransaction(ctx)
+		utils.Logger.Info("successfully authenticated")
+		return principal, nil
+	}
+	if strings.HasPrefix(token, entities.PersonalAccessTokenPrefix) {
+		principal, err := s.authenticatePersonalAccessToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		_ = session.CommitTransaction(ctx)
+		utils.Logger.Info("successfully authenticated")
+		return principal, nil
+	}
 	claims, err := jwt.VerifyJwtToken(token)
 	if err != nil {
 		utils.Logger.Error("failed to verify token", "error: ", err.Error())
@@ -119,13 +297,70 @@ func (s *authenticationService) Authenticate(ctx context.Context, token string)
 			utils.Logger.Error("failed to find user by id", "error: ", err.Error())
 			return nil, err
 		}
+		if err := s.checkStatus(ctx, claims.UserId); err != nil {
+			return nil, err
+		}
+		principal := entities.Principal{JwtCustomClaims: claims.JwtCustomClaims}
+		// exchanged tokens only hold the permissions covered by the scope granted on exchange
+		exchanged, err := parseExchangedToken(token)
+		if err != nil {
+			return nil, err
+		} else if exchanged.ClientId != "" {
+			principal.Scopes = append([]string{}, strings.Fields(exchanged.Scope)...)
+		}
 		_ = session.CommitTransaction(ctx)
 		utils.Logger.Info("successfully authenticated")
-		return &claims.JwtCustomClaims, nil
+		return &principal, nil
 	}
 }
 
-func (s *authenticationService) RefreshLogin(ctx context.Context, refreshToken string) (*models.LoginOutput, error) {
+// authenticatePersonalAccessToken resolves a personal access token to its owner, limited
+// to the scopes of the token. Such claims carry no session id.
+func (s *authenticationService) authenticatePersonalAccessToken(ctx context.Context, token string) (*entities.Principal, error) {
+	pat, err := s.personalAccessTokenRepository.FindOneByHash(ctx, tokens.HashSecret(token))
+	if err != nil {
+		utils.Logger.Info("unknown personal access token")
+		return nil, fmt.Errorf("invalid token")
+	}
+	now := time.Now()
+	if pat.ExpiresAt != nil && pat.ExpiresAt.Before(now) {
+		utils.Logger.Info("expired personal access token")
+		return nil, fmt.Errorf("token expired")
+	}
+	user, err := s.userRepository.FindOneById(ctx, pat.UserId)
+	if err != nil {
+		utils.Logger.Error("failed to find user by id", "error: ", err.Error())
+		return nil, err
+	}
+	if err := s.checkStatus(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	err = s.personalAccessTokenRepository.UpdateLastUsed(ctx, pat.ID, now)
+	if err != nil {
+		utils.Logger.Error("failed to update personal access token", "error: ", err.Error())
+	}
+	return &entities.Principal{
+		JwtCustomClaims: models.JwtCustomClaims{
+			Email:  user.Email,
+			UserId: user.ID,
+			Role:   user.Role,
+		},
+		Scopes: append([]string{}, pat.Scopes...),
+	}, nil
+}
+
+func (s *authenticationService) RefreshLogin(ctx context.Context, refreshToken string) (result *models.LoginOutput, err error) {
+	var target *primitive.ObjectID
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:   entities.AuditRefresh,
+			Actor:  target,
+			Target: target,
+		}, err)
+		if target != nil {
+			s.loginHistoryService.Record(ctx, *target, entities.LoginMethodRefresh, err)
+		}
+	}()
 	mongoSession, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -154,6 +389,7 @@ func (s *authenticationService) RefreshLogin(ctx context.Context, refreshToken s
 			utils.Logger.Error("failed to find user by id", "error: ", err.Error())
 			return nil, err
 		} else {
+			target = &session.UserId
 			session, err = s.authenticationRepository.UpdateOne(ctx, session)
 			if err != nil {
 				utils.Logger.Error("failed to update session", "error: ", err.Error())
@@ -163,16 +399,13 @@ func (s *authenticationService) RefreshLogin(ctx context.Context, refreshToken s
 				if err != nil {
 					utils.Logger.Error("failed to find user by id", "error: ", err.Error())
 					return nil, err
+				} else if err := s.checkStatus(ctx, user.ID); err != nil {
+					_, _ = s.authenticationRepository.DeleteOneById(ctx, session.ID)
+					_ = mongoSession.CommitTransaction(ctx)
+					return nil, err
 				} else {
-					claims := models.JwtCustomClaims{
-						Email:     user.Email,
-						Role:      user.Role,
-						UserId:    user.ID,
-						SessionId: session.ID,
-					}
-					newToken, err := jwt.GenerateJWT(&claims)
+					newToken, err := s.accessToken(ctx, user, session, entities.HookTriggerRefresh)
 					if err != nil {
-						utils.Logger.Error("failed to generate JWT token error: ", err.Error())
 						return nil, err
 					} else {
 						_ = mongoSession.CommitTransaction(ctx)
@@ -188,7 +421,15 @@ func (s *authenticationService) RefreshLogin(ctx context.Context, refreshToken s
 	}
 }
 
-func (s *authenticationService) Logout(ctx context.Context, token string) error {
+func (s *authenticationService) Logout(ctx context.Context, token string) (err error) {
+	var target *primitive.ObjectID
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:   entities.AuditLogout,
+			Actor:  target,
+			Target: target,
+		}, err)
+	}()
 	session, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -202,14 +443,146 @@ func (s *authenticationService) Logout(ctx context.Context, token string) error
 	}
 	claims, err := jwt.VerifyJwtToken(token)
 	if claims != nil {
-		_, err = s.authenticationRepository.DeleteOneById(ctx, claims.JwtCustomClaims.SessionId)
+		target = &claims.JwtCustomClaims.UserId
+		sessionCtx := mongo.NewSessionContext(ctx, session)
+		ended, err := s.authenticationRepository.DeleteOneById(sessionCtx, claims.JwtCustomClaims.SessionId)
 		if err != nil {
 			utils.Logger.Error("failed to delete session error", err.Error())
 			return err
 		}
+		if ended != nil {
+			err = recordEvent(sessionCtx, s.outboxRepository, entities.EventSessionEnded, ended.UserId, ended)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	_ = session.CommitTransaction(ctx)
 	utils.Logger.Info("logged out successfully")
 	return nil
 
 }
+
+// ActiveOrganization returns the organization the caller is acting in. Callers bound to
+// an organization act in it, session tokens use the organization of their session and
+// other tokens the first organization of the user. It is zero when the caller is not a
+// member of any organization.
+func (s *authenticationService) ActiveOrganization(ctx context.Context, principal *entities.Principal) (primitive.ObjectID, error) {
+	if !principal.OrganizationId.IsZero() {
+		return principal.OrganizationId, nil
+	}
+	claims := &principal.JwtCustomClaims
+	if !claims.SessionId.IsZero() {
+		session, err := s.authenticationRepository.FindOneById(ctx, claims.SessionId)
+		if err != nil {
+			utils.Logger.Error("failed to find session", "error: ", err.Error())
+			return primitive.NilObjectID, err
+		}
+		if session.OrganizationId.IsZero() {
+			return primitive.NilObjectID, nil
+		}
+		// the membership may have been removed since the session switched to it
+		_, err = s.organizationRepository.FindMembership(ctx, session.OrganizationId, claims.UserId)
+		if err != nil {
+			return primitive.NilObjectID, nil
+		}
+		return session.OrganizationId, nil
+	}
+	memberships, err := s.organizationRepository.FindMembershipsByUserId(ctx, claims.UserId)
+	if err != nil {
+		utils.Logger.Error("failed to find memberships", "error: ", err.Error())
+		return primitive.NilObjectID, err
+	} else if len(memberships) == 0 {
+		return primitive.NilObjectID, nil
+	}
+	return memberships[0].OrganizationId, nil
+}
+
+// SwitchOrganization moves the session of the caller to another organization and
+// re-issues its tokens, so the caller does not have to log in again.
+func (s *authenticationService) SwitchOrganization(ctx context.Context, organizationId primitive.ObjectID) (*models.LoginOutput, error) {
+	mongoSession, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer mongoSession.EndSession(ctx)
+	err = mongoSession.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	claims := ctx.Value("Claims").(*models.JwtCustomClaims)
+	if claims.SessionId.IsZero() {
+		return nil, fmt.Errorf("token is not bound to a session")
+	}
+	_, err = s.organizationRepository.FindMembership(ctx, organizationId, claims.UserId)
+	if err != nil {
+		utils.Logger.Info("user is not a member of the organization")
+		return nil, fmt.Errorf("organization not found")
+	}
+	session, err := s.authenticationRepository.UpdateOrganization(ctx, claims.SessionId, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to update session", "error: ", err.Error())
+		return nil, err
+	}
+	user, err := s.userRepository.FindOneById(ctx, claims.UserId)
+	if err != nil {
+		utils.Logger.Error("failed to find user by id", "error: ", err.Error())
+		return nil, err
+	}
+	token, err := s.accessToken(ctx, user, session, entities.HookTriggerSwitchOrganization)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := jwt.GenerateRefreshToken(session.ID)
+	if err != nil {
+		utils.Logger.Error("failed to generate refreshToken", "error: ", err.Error())
+		return nil, err
+	}
+	_ = mongoSession.CommitTransaction(ctx)
+	utils.Logger.Info("switched organization")
+	return &models.LoginOutput{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// RevokeSessions ends every session of the user. Access tokens of those sessions stop
+// being accepted by Authenticate immediately and their refresh tokens stop working.
+func (s *authenticationService) RevokeSessions(ctx context.Context, userId primitive.ObjectID) error {
+	count, err := s.authenticationRepository.DeleteManyByUserId(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to delete sessions", "error: ", err.Error())
+		return err
+	}
+	if count > 0 {
+		err = recordEvent(ctx, s.outboxRepository, entities.EventSessionsRevoked, userId, map[string]int64{"count": count})
+		if err != nil {
+			return err
+		}
+	}
+	utils.Logger.Info("revoked sessions", "count: ", count)
+	return nil
+}
+
+// checkStatus fails unless the account of the user is active.
+func (s *authenticationService) checkStatus(ctx context.Context, userId primitive.ObjectID) error {
+	record, err := s.userRepository.FindRecordById(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find user by id", "error: ", err.Error())
+		return err
+	}
+	switch record.Status {
+	case entities.UserSuspended:
+		return ErrAccountSuspended
+	case entities.UserDisabled:
+		return ErrAccountDisabled
+	case entities.UserPending:
+		return ErrAccountPending
+	case entities.UserPendingDeletion:
+		return ErrAccountDeleted
+	default:
+		return nil
+	}
+}
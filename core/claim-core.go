@@ -0,0 +1,241 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// reservedClaims are set by shield itself and cannot be mapped. The token exchange
+// claims are among them, since their presence alone turns a token into an exchanged one.
+var reservedClaims = []string{
+	"email", "userId", "role", "sessionId", "organizationId", "organizationRole", "groups",
+	"permissions", "ext", "aud", "exp", "iat", "iss", "jti", "nbf", "sub",
+	"client_id", "scope", "act", "azp",
+}
+
+var claimSources = []string{
+	entities.ClaimSourceUserId,
+	entities.ClaimSourceUserEmail,
+	entities.ClaimSourceUserFirstName,
+	entities.ClaimSourceUserLastName,
+	entities.ClaimSourceUserRole,
+	entities.ClaimSourceOrganizationId,
+	entities.ClaimSourceOrganizationName,
+	entities.ClaimSourceOrganizationRole,
+	entities.ClaimSourceGroups,
+	entities.ClaimSourcePermissions,
+}
+
+// defaultClaimsMaxSize limits rendered claims when no limit is configured.
+const defaultClaimsMaxSize = 2048
+
+type IClaimService interface {
+	CreateTemplate(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error)
+	GetTemplates(ctx context.Context) ([]entities.ClaimTemplate, error)
+	UpdateTemplate(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error)
+	DeleteTemplate(ctx context.Context, id primitive.ObjectID) (*entities.ClaimTemplate, error)
+	// Render fills the templates applying to the audience of claims. groups are the
+	// groups the user effectively belongs to in the organization of claims.
+	Render(ctx context.Context, user *models.User, claims *entities.AccessTokenClaims, groups []entities.Group) (map[string]interface{}, error)
+}
+
+type claimService struct {
+	IClaimService
+	repo                   repository.IClaimRepository
+	organizationRepository repository.IOrganizationRepository
+	maxSize                int
+	client                 *mongo.Client
+}
+
+// NewClaimService creates the claim service. Rendered claims are limited to maxSize
+// bytes of JSON, 2048 when it is not positive; claims beyond the limit are left out of
+// the token.
+func NewClaimService(client *mongo.Client, repository repository.IClaimRepository, organizationRepository repository.IOrganizationRepository, maxSize int) IClaimService {
+	if maxSize <= 0 {
+		maxSize = defaultClaimsMaxSize
+	}
+	return &claimService{
+		repo:                   repository,
+		organizationRepository: organizationRepository,
+		maxSize:                maxSize,
+		client:                 client,
+	}
+}
+
+func (s *claimService) CreateTemplate(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error) {
+	if err := validateClaimTemplate(template); err != nil {
+		return nil, err
+	}
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = template.CreatedAt
+	result, err := s.repo.InsertOne(ctx, template)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("record exists")
+	} else if err != nil {
+		utils.Logger.Error("failed to insert claim template", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created claim template")
+	return result, nil
+}
+
+func (s *claimService) GetTemplates(ctx context.Context) ([]entities.ClaimTemplate, error) {
+	result, err := s.repo.FindMany(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find claim templates", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *claimService) UpdateTemplate(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error) {
+	if err := validateClaimTemplate(template); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.UpdateOne(ctx, template)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("record exists")
+	} else if err != nil {
+		utils.Logger.Error("failed to update claim template", "error: ", err.Error())
+		return nil, fmt.Errorf("claim template not found")
+	}
+	utils.Logger.Info("updated claim template")
+	return result, nil
+}
+
+func (s *claimService) DeleteTemplate(ctx context.Context, id primitive.ObjectID) (*entities.ClaimTemplate, error) {
+	result, err := s.repo.DeleteOneById(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete claim template", "error: ", err.Error())
+		return nil, fmt.Errorf("claim template not found")
+	}
+	utils.Logger.Info("deleted claim template")
+	return result, nil
+}
+
+// validateClaimTemplate checks that a template maps an unreserved claim from exactly one
+// of a known source or a constant value.
+func validateClaimTemplate(template *entities.ClaimTemplate) error {
+	if slices.Contains(reservedClaims, template.Claim) {
+		return fmt.Errorf("claim %s is reserved", template.Claim)
+	}
+	if (template.Source == "") == (template.Value == "") {
+		return fmt.Errorf("either source or value is required")
+	}
+	if template.Source != "" && !slices.Contains(claimSources, template.Source) {
+		key, ok := strings.CutPrefix(template.Source, entities.ClaimSourceOrganizationMetadata)
+		if !ok || key == "" {
+			return fmt.Errorf("unknown claim source %s", template.Source)
+		}
+	}
+	return nil
+}
+
+func (s *claimService) Render(ctx context.Context, user *models.User, claims *entities.AccessTokenClaims, groups []entities.Group) (map[string]interface{}, error) {
+	templates, err := s.repo.FindManyByAudience(ctx, claims.Audience)
+	if err != nil {
+		utils.Logger.Error("failed to find claim templates", "error: ", err.Error())
+		return nil, err
+	}
+	// templates are sorted by claim and audience, so the template for the audience
+	// comes after, and replaces, the one without
+	selected := map[string]entities.ClaimTemplate{}
+	var names []string
+	for _, template := range templates {
+		if _, ok := selected[template.Claim]; !ok {
+			names = append(names, template.Claim)
+		}
+		selected[template.Claim] = template
+	}
+	var organization *entities.Organization
+	if !claims.OrganizationId.IsZero() {
+		organization, err = s.organizationRepository.FindOneById(ctx, claims.OrganizationId)
+		if err != nil {
+			utils.Logger.Error("failed to find organization", "error: ", err.Error())
+			return nil, err
+		}
+	}
+	var result map[string]interface{}
+	size := 0
+	for _, name := range names {
+		value := claimValue(selected[name], user, claims, organization, groups)
+		if value == nil {
+			continue
+		}
+		encoded, err := json.Marshal(map[string]interface{}{name: value})
+		if err != nil {
+			return nil, err
+		}
+		if size+len(encoded) > s.maxSize {
+			utils.Logger.Info("claim left out of token, templates exceed the size limit ", name)
+			continue
+		}
+		size += len(encoded)
+		if result == nil {
+			result = map[string]interface{}{}
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// claimValue returns the value of a template, or nil when its source is empty.
+func claimValue(template entities.ClaimTemplate, user *models.User, claims *entities.AccessTokenClaims, organization *entities.Organization, groups []entities.Group) interface{} {
+	var value string
+	switch template.Source {
+	case "":
+		value = template.Value
+	case entities.ClaimSourceUserId:
+		value = user.ID.Hex()
+	case entities.ClaimSourceUserEmail:
+		value = user.Email
+	case entities.ClaimSourceUserFirstName:
+		value = user.FirstName
+	case entities.ClaimSourceUserLastName:
+		value = user.LastName
+	case entities.ClaimSourceUserRole:
+		value = string(user.Role)
+	case entities.ClaimSourceOrganizationId:
+		if organization != nil {
+			value = organization.ID.Hex()
+		}
+	case entities.ClaimSourceOrganizationName:
+		if organization != nil {
+			value = organization.Name
+		}
+	case entities.ClaimSourceOrganizationRole:
+		value = claims.OrganizationRole
+	case entities.ClaimSourceGroups:
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Name)
+		}
+		if len(names) > 0 {
+			return names
+		}
+	case entities.ClaimSourcePermissions:
+		if len(claims.Permissions) > 0 {
+			return claims.Permissions
+		}
+	default:
+		key := strings.TrimPrefix(template.Source, entities.ClaimSourceOrganizationMetadata)
+		if organization != nil {
+			value = organization.Metadata[key]
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	return value
+}
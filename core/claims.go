@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	jwtgo "github.com/dgrijalva/jwt-go"
+	"github.com/draco121/horizon/jwt"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"shield/entities"
+)
+
+// accessToken signs the access token of a session. It carries the horizon claims plus
+// the active organization of the session, the role of the user in it, the groups the
+// user effectively belongs to there and the permissions resolved from all of them.
+// The claim templates for the audience of the session are mapped into the token, then
+// the pre-token hooks are called with trigger and may deny the token or add claims.
+func (s *authenticationService) accessToken(ctx context.Context, user *models.User, session *entities.Session, trigger string) (string, error) {
+	claims := entities.AccessTokenClaims{
+		JwtCustomClaims: models.JwtCustomClaims{
+			Email:     user.Email,
+			UserId:    user.ID,
+			Role:      user.Role,
+			SessionId: session.ID,
+		},
+		StandardClaims: jwtgo.StandardClaims{
+			Audience:  session.Audience,
+			ExpiresAt: time.Now().Add(time.Hour * 1).Unix(),
+		},
+	}
+	if !session.OrganizationId.IsZero() {
+		membership, err := s.organizationRepository.FindMembership(ctx, session.OrganizationId, user.ID)
+		if err == nil {
+			claims.OrganizationId = membership.OrganizationId
+			claims.OrganizationRole = membership.Role
+		}
+	}
+	groups, err := effectiveGroups(ctx, s.groupRepository, claims.OrganizationId, user.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, group := range groups {
+		claims.Groups = append(claims.Groups, group.ID)
+	}
+	claims.Permissions, err = s.roleService.GetPermissions(ctx, &claims.JwtCustomClaims, claims.OrganizationId)
+	if err != nil {
+		utils.Logger.Error("failed to resolve permissions", "error: ", err.Error())
+		return "", err
+	}
+	claims.Mapped, err = s.claimService.Render(ctx, user, &claims, groups)
+	if err != nil {
+		return "", err
+	}
+	claims.Ext, err = s.hookRunner.Run(ctx, &entities.HookRequest{
+		Point:     entities.HookPreToken,
+		Trigger:   trigger,
+		User:      entities.NewUserView(&entities.UserRecord{User: *user}),
+		ClientIP:  clientIP(ctx),
+		UserAgent: userAgent(ctx),
+	})
+	if err != nil {
+		return "", err
+	}
+	token, err := jwtgo.NewWithClaims(jwtgo.SigningMethodHS256, claims).SignedString(jwt.JWTSecretKey)
+	if err != nil {
+		utils.Logger.Error("failed to generate JWT", "error: ", err.Error())
+		return "", err
+	}
+	return token, nil
+}
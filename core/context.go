@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// clientIP returns the caller address set by middlewares.ClientInfo, if any.
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value("ClientIP").(string)
+	return ip
+}
+
+// userAgent returns the caller user agent set by middlewares.ClientInfo, if any.
+func userAgent(ctx context.Context) string {
+	agent, _ := ctx.Value("UserAgent").(string)
+	return agent
+}
+
+// activeOrganization returns the active organization set by middlewares.Authorizer. It is
+// zero when the caller is not acting in an organization.
+func activeOrganization(ctx context.Context) primitive.ObjectID {
+	id, _ := ctx.Value("OrganizationId").(primitive.ObjectID)
+	return id
+}
+
+// requireActiveOrganization fails unless organizationId is the active organization of
+// the caller, so acting in one organization does not reach into another.
+func requireActiveOrganization(ctx context.Context, organizationId primitive.ObjectID) error {
+	if organizationId.IsZero() || activeOrganization(ctx) != organizationId {
+		return fmt.Errorf("organization not found")
+	}
+	return nil
+}
+
+// requestedAudience returns the audience a login asked its tokens to be issued for, if any.
+func requestedAudience(ctx context.Context) string {
+	audience, _ := ctx.Value("Audience").(string)
+	return audience
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+var errInvalidEmailChangeToken = errors.New("invalid or expired token")
+
+type IEmailChangeService interface {
+	RequestChange(ctx context.Context, input *entities.EmailChangeInput) (*entities.EmailChange, error)
+	ConfirmChange(ctx context.Context, input *entities.EmailChangeTokenInput) (*entities.UserView, error)
+	RevertChange(ctx context.Context, input *entities.EmailChangeTokenInput) (*entities.UserView, error)
+}
+
+// EmailChangeConfig configures the email change links. Tokens are appended to
+// ConfirmURL and RevertURL as ?token=, or sent on their own when the URL is empty.
+type EmailChangeConfig struct {
+	ConfirmURL string
+	RevertURL  string
+	// TTL is how long the confirmation link sent to the new address is valid.
+	TTL time.Duration
+	// RevertTTL is how long the link sent to the old address can undo the change.
+	RevertTTL time.Duration
+}
+
+type emailChangeService struct {
+	IEmailChangeService
+	repo                  repository.IEmailChangeRepository
+	userRepository        repository.IUserRepository
+	authenticationService IAuthenticationService
+	outboxRepository      repository.IOutboxRepository
+	auditService          IAuditService
+	notifier              INotifier
+	config                EmailChangeConfig
+	client                *mongo.Client
+}
+
+func NewEmailChangeService(client *mongo.Client, repository repository.IEmailChangeRepository, userRepository repository.IUserRepository, authenticationService IAuthenticationService, outboxRepository repository.IOutboxRepository, auditService IAuditService, notifier INotifier, config EmailChangeConfig) IEmailChangeService {
+	return &emailChangeService{
+		repo:                  repository,
+		userRepository:        userRepository,
+		authenticationService: authenticationService,
+		outboxRepository:      outboxRepository,
+		auditService:          auditService,
+		notifier:              notifier,
+		config:                config,
+		client:                client,
+	}
+}
+
+// RequestChange starts moving the caller to a new email. The new address receives a
+// confirmation link and the old one a link to cancel or revert the change. Pending
+// changes requested before are cancelled.
+func (s *emailChangeService) RequestChange(ctx context.Context, input *entities.EmailChangeInput) (result *entities.EmailChange, err error) {
+	userId := ctx.Value("UserId").(primitive.ObjectID)
+	email := strings.TrimSpace(input.Email)
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:     entities.AuditEmailChangeRequested,
+			Target:   &userId,
+			Metadata: map[string]string{"email": email},
+		}, err)
+	}()
+	user, err := s.userRepository.FindOneById(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find user", "error: ", err.Error())
+		return nil, fmt.Errorf("user not found")
+	}
+	if !utils.CheckPasswordHash(input.Password, user.Password) {
+		utils.Logger.Info("Invalid password")
+		return nil, fmt.Errorf("invalid credentials")
+	}
+	if strings.EqualFold(email, user.Email) {
+		return nil, fmt.Errorf("email is unchanged")
+	}
+	if _, err := s.userRepository.FindOneByEmailFold(ctx, email); err == nil {
+		return nil, fmt.Errorf("email already in use")
+	}
+	confirmToken, err := tokens.GenerateSecret(entities.EmailChangeConfirmPrefix, 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate email change token", "error: ", err.Error())
+		return nil, err
+	}
+	revertToken, err := tokens.GenerateSecret(entities.EmailChangeRevertPrefix, 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate email change token", "error: ", err.Error())
+		return nil, err
+	}
+	err = s.repo.CancelPendingByUserId(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to cancel pending email changes", "error: ", err.Error())
+		return nil, err
+	}
+	now := time.Now()
+	result, err = s.repo.InsertOne(ctx, &entities.EmailChange{
+		UserId:          userId,
+		OldEmail:        user.Email,
+		NewEmail:        email,
+		Status:          entities.EmailChangePending,
+		ConfirmHash:     tokens.HashSecret(confirmToken),
+		RevertHash:      tokens.HashSecret(revertToken),
+		ExpiresAt:       now.Add(s.config.TTL),
+		RevertExpiresAt: now.Add(s.config.RevertTTL),
+		CreatedAt:       now,
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert email change", "error: ", err.Error())
+		return nil, err
+	}
+	err = s.notifier.Notify(ctx, &entities.Notification{
+		To:      result.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Confirm that %s is the new email address of your account before %s: %s",
+			result.NewEmail, result.ExpiresAt.Format(time.RFC1123), emailChangeLink(s.config.ConfirmURL, confirmToken)),
+	})
+	if err != nil {
+		_ = s.repo.DeleteOneById(ctx, result.ID)
+		return nil, fmt.Errorf("failed to send confirmation")
+	}
+	err = s.notifier.Notify(ctx, &entities.Notification{
+		To:      result.OldEmail,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("A change of the email address of your account to %s was requested. If this was not you, cancel the change before %s: %s",
+			result.NewEmail, result.RevertExpiresAt.Format(time.RFC1123), emailChangeLink(s.config.RevertURL, revertToken)),
+	})
+	if err != nil {
+		_ = s.repo.DeleteOneById(ctx, result.ID)
+		return nil, fmt.Errorf("failed to send confirmation")
+	}
+	utils.Logger.Info("requested email change")
+	return result, nil
+}
+
+// ConfirmChange moves the user to the new email. Following the link proves control of
+// the address, so the email is marked verified and pending accounts are activated.
+// Access tokens carry the new email from the next refresh on.
+func (s *emailChangeService) ConfirmChange(ctx context.Context, input *entities.EmailChangeTokenInput) (result *entities.UserView, err error) {
+	var target *primitive.ObjectID
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:   entities.AuditEmailChanged,
+			Actor:  target,
+			Target: target,
+		}, err)
+	}()
+	change, err := s.repo.FindOneByConfirmHash(ctx, tokens.HashSecret(input.Token))
+	if err != nil || change.Status != entities.EmailChangePending || change.ExpiresAt.Before(time.Now()) {
+		return nil, errInvalidEmailChangeToken
+	}
+	target = &change.UserId
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	now := time.Now()
+	change, err = s.repo.UpdateStatus(sessionCtx, change.ID, entities.EmailChangePending, entities.EmailChangeConfirmed, now)
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, errInvalidEmailChangeToken
+	}
+	previous, err := s.userRepository.FindRecordById(sessionCtx, change.UserId)
+	if err != nil {
+		utils.Logger.Error("failed to find user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("user not found")
+	}
+	record, err := s.userRepository.UpdateEmail(sessionCtx, change.UserId, change.NewEmail, now)
+	if err != nil {
+		utils.Logger.Error("failed to update email", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("email already in use")
+	}
+	err = recordEvent(sessionCtx, s.outboxRepository, entities.EventUserEmailChanged, change.UserId, change)
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	if previous.Status == entities.UserPending {
+		record, err = s.userRepository.UpdateStatus(sessionCtx, change.UserId, entities.UserActive, "", nil)
+		if err != nil {
+			utils.Logger.Error("failed to activate user", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+		err = recordEvent(sessionCtx, s.outboxRepository, entities.EventUserVerified, change.UserId, entities.NewUserView(record))
+		if err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("changed email")
+	view := entities.NewUserView(record)
+	return &view, nil
+}
+
+// RevertChange cancels a pending change or, once confirmed, moves the user back to the
+// old email. The change may not have been made by the user, so every session of the
+// user is ended.
+func (s *emailChangeService) RevertChange(ctx context.Context, input *entities.EmailChangeTokenInput) (result *entities.UserView, err error) {
+	var target *primitive.ObjectID
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:   entities.AuditEmailChangeReverted,
+			Actor:  target,
+			Target: target,
+		}, err)
+	}()
+	change, err := s.repo.FindOneByRevertHash(ctx, tokens.HashSecret(input.Token))
+	if err != nil || change.RevertExpiresAt.Before(time.Now()) ||
+		(change.Status != entities.EmailChangePending && change.Status != entities.EmailChangeConfirmed) {
+		return nil, errInvalidEmailChangeToken
+	}
+	target = &change.UserId
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	confirmed := change.Status == entities.EmailChangeConfirmed
+	change, err = s.repo.UpdateStatus(sessionCtx, change.ID, change.Status, entities.EmailChangeReverted, time.Now())
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, errInvalidEmailChangeToken
+	}
+	var record *entities.UserRecord
+	if confirmed {
+		// following the revert link proves control of the old address
+		record, err = s.userRepository.UpdateEmail(sessionCtx, change.UserId, change.OldEmail, time.Now())
+		if err != nil {
+			utils.Logger.Error("failed to revert email", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, fmt.Errorf("email is no longer available")
+		}
+		err = recordEvent(sessionCtx, s.outboxRepository, entities.EventUserEmailChanged, change.UserId, change)
+		if err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	} else {
+		record, err = s.userRepository.FindRecordById(sessionCtx, change.UserId)
+		if err != nil {
+			utils.Logger.Error("failed to find user", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, fmt.Errorf("user not found")
+		}
+	}
+	err = s.authenticationService.RevokeSessions(sessionCtx, change.UserId)
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("reverted email change")
+	view := entities.NewUserView(record)
+	return &view, nil
+}
+
+func emailChangeLink(url string, token string) string {
+	if url == "" {
+		return token
+	}
+	return url + "?token=" + token
+}
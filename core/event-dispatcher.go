@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// outboxLease is how long a dispatcher keeps the outbox to itself without renewing.
+const outboxLease = 30 * time.Second
+
+type IOutboxDispatcher interface {
+	Dispatch(ctx context.Context) (int, error)
+}
+
+type outboxDispatcher struct {
+	IOutboxDispatcher
+	outboxRepository repository.IOutboxRepository
+	publisher        IEventPublisher
+	webhookService   IWebhookService
+	owner            string
+	client           *mongo.Client
+}
+
+// NewOutboxDispatcher creates the dispatcher relaying outbox events to the publisher
+// and to webhook endpoints. Only the instance holding the outbox lease dispatches, so
+// events keep their order across instances.
+func NewOutboxDispatcher(client *mongo.Client, outboxRepository repository.IOutboxRepository, publisher IEventPublisher, webhookService IWebhookService) IOutboxDispatcher {
+	return &outboxDispatcher{
+		outboxRepository: outboxRepository,
+		publisher:        publisher,
+		webhookService:   webhookService,
+		owner:            primitive.NewObjectID().Hex(),
+		client:           client,
+	}
+}
+
+// Dispatch relays the waiting outbox events in the order they were written. When an
+// event cannot be relayed, later events of the same subject wait for the next round so
+// consumers see the events of a user in order. The lease is renewed before every event
+// and each event is relayed within it, so the round stops as soon as another instance
+// could take over. It returns the number relayed.
+func (d *outboxDispatcher) Dispatch(ctx context.Context) (int, error) {
+	leased, err := d.lease(ctx)
+	if err != nil || !leased {
+		return 0, err
+	}
+	events, err := d.outboxRepository.FindUndispatched(ctx, 100)
+	if err != nil {
+		utils.Logger.Error("failed to find outbox events", "error: ", err.Error())
+		return 0, err
+	}
+	blocked := map[primitive.ObjectID]bool{}
+	dispatched := 0
+	for i := range events {
+		event := &events[i]
+		if blocked[event.Subject] {
+			continue
+		}
+		leased, err := d.lease(ctx)
+		if err != nil || !leased {
+			return dispatched, err
+		}
+		err = d.relay(ctx, event)
+		if errors.Is(err, errLeaseExpired) {
+			return dispatched, nil
+		} else if err != nil {
+			blocked[event.Subject] = true
+			continue
+		}
+		if err := d.outboxRepository.MarkDispatched(ctx, event.ID); err != nil {
+			utils.Logger.Error("failed to mark outbox event dispatched", "error: ", err.Error())
+			return dispatched, err
+		}
+		dispatched++
+	}
+	return dispatched, nil
+}
+
+// errLeaseExpired stops a round whose lease ran out while an event was relayed.
+var errLeaseExpired = errors.New("outbox lease expired")
+
+// lease takes or renews the outbox lease, reporting false while another instance holds it.
+func (d *outboxDispatcher) lease(ctx context.Context) (bool, error) {
+	leased, err := d.outboxRepository.AcquireLease(ctx, d.owner, outboxLease)
+	if err != nil {
+		utils.Logger.Error("failed to acquire outbox lease", "error: ", err.Error())
+		return false, err
+	}
+	return leased, nil
+}
+
+// relay publishes the event and enqueues its webhook deliveries before the lease just
+// renewed runs out.
+func (d *outboxDispatcher) relay(ctx context.Context, event *entities.OutboxEvent) error {
+	leaseCtx, cancel := context.WithTimeout(ctx, outboxLease)
+	defer cancel()
+	if err := d.publisher.Publish(leaseCtx, entities.NewEventMessage(event)); err != nil {
+		utils.Logger.Error("failed to publish event", "error: ", err.Error())
+		if leaseCtx.Err() != nil {
+			return errLeaseExpired
+		}
+		return err
+	}
+	if err := d.webhookService.Enqueue(leaseCtx, event); err != nil {
+		if leaseCtx.Err() != nil {
+			return errLeaseExpired
+		}
+		return err
+	}
+	if leaseCtx.Err() != nil {
+		return errLeaseExpired
+	}
+	return nil
+}
+
+// RunOutboxDispatcher dispatches the outbox every interval until ctx is done.
+func RunOutboxDispatcher(ctx context.Context, dispatcher IOutboxDispatcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = dispatcher.Dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+	"shield/repository"
+)
+
+// fakeOutboxRepository serves a fixed list of events. The lease is granted for as many
+// calls as leases allows, then refused.
+type fakeOutboxRepository struct {
+	repository.IOutboxRepository
+	events     []entities.OutboxEvent
+	leases     int
+	leaseCalls int
+	dispatched []primitive.ObjectID
+}
+
+func (r *fakeOutboxRepository) FindUndispatched(_ context.Context, limit int) ([]entities.OutboxEvent, error) {
+	if len(r.events) > limit {
+		return r.events[:limit], nil
+	}
+	return r.events, nil
+}
+
+func (r *fakeOutboxRepository) MarkDispatched(_ context.Context, id primitive.ObjectID) error {
+	r.dispatched = append(r.dispatched, id)
+	return nil
+}
+
+func (r *fakeOutboxRepository) AcquireLease(_ context.Context, _ string, _ time.Duration) (bool, error) {
+	r.leaseCalls++
+	return r.leaseCalls <= r.leases, nil
+}
+
+type fakeWebhookService struct {
+	IWebhookService
+	enqueued []primitive.ObjectID
+}
+
+func (s *fakeWebhookService) Enqueue(_ context.Context, event *entities.OutboxEvent) error {
+	s.enqueued = append(s.enqueued, event.ID)
+	return nil
+}
+
+// failingPublisher refuses the events of one subject.
+type failingPublisher struct {
+	IEventPublisher
+	failing   primitive.ObjectID
+	published []primitive.ObjectID
+}
+
+func (p *failingPublisher) Publish(_ context.Context, event *entities.EventMessage) error {
+	if event.Subject == p.failing {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func newTestOutbox(subjects ...primitive.ObjectID) []entities.OutboxEvent {
+	events := make([]entities.OutboxEvent, 0, len(subjects))
+	for _, subject := range subjects {
+		events = append(events, *newTestEvent(subject, entities.EventUserVerified))
+	}
+	return events
+}
+
+func TestOutboxDispatcherWaitsForTheLease(t *testing.T) {
+	repo := &fakeOutboxRepository{events: newTestOutbox(primitive.NewObjectID())}
+	publisher := NewMemoryPublisher()
+	published := 0
+	publisher.Subscribe(func(*entities.EventMessage) { published++ })
+
+	dispatched, err := NewOutboxDispatcher(nil, repo, publisher, &fakeWebhookService{}).Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatched != 0 || published != 0 || len(repo.dispatched) != 0 {
+		t.Errorf("dispatched %d events while another instance holds the lease", dispatched)
+	}
+}
+
+func TestOutboxDispatcherRenewsTheLeaseForEveryEvent(t *testing.T) {
+	subject := primitive.NewObjectID()
+	repo := &fakeOutboxRepository{events: newTestOutbox(subject, subject, primitive.NewObjectID()), leases: 100}
+	webhooks := &fakeWebhookService{}
+	publisher := NewMemoryPublisher()
+	var order []primitive.ObjectID
+	publisher.Subscribe(func(event *entities.EventMessage) { order = append(order, event.ID) })
+
+	dispatched, err := NewOutboxDispatcher(nil, repo, publisher, webhooks).Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatched != 3 || len(repo.dispatched) != 3 || len(webhooks.enqueued) != 3 {
+		t.Fatalf("dispatched %d, marked %d, enqueued %d, want 3", dispatched, len(repo.dispatched), len(webhooks.enqueued))
+	}
+	if repo.leaseCalls != 1+len(repo.events) {
+		t.Errorf("lease acquired %d times, want once per round and once per event", repo.leaseCalls)
+	}
+	for i, event := range repo.events {
+		if order[i] != event.ID {
+			t.Fatalf("published %v, want the outbox order", order)
+		}
+	}
+}
+
+func TestOutboxDispatcherStopsWhenTheLeaseIsLost(t *testing.T) {
+	repo := &fakeOutboxRepository{events: newTestOutbox(primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()), leases: 3}
+	publisher := &failingPublisher{}
+
+	dispatched, err := NewOutboxDispatcher(nil, repo, publisher, &fakeWebhookService{}).Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the round lease and the renewals for the first two events were granted
+	if dispatched != 2 || len(publisher.published) != 2 {
+		t.Fatalf("dispatched %d, published %d, want 2 before the lease ran out", dispatched, len(publisher.published))
+	}
+	for _, id := range repo.dispatched {
+		if id == repo.events[2].ID {
+			t.Error("event marked dispatched after the lease was lost")
+		}
+	}
+}
+
+func TestOutboxDispatcherHoldsBackTheSubjectOfAFailedEvent(t *testing.T) {
+	failing, healthy := primitive.NewObjectID(), primitive.NewObjectID()
+	repo := &fakeOutboxRepository{events: newTestOutbox(failing, healthy, failing, healthy), leases: 100}
+	publisher := &failingPublisher{failing: failing}
+
+	dispatched, err := NewOutboxDispatcher(nil, repo, publisher, &fakeWebhookService{}).Dispatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dispatched != 2 {
+		t.Fatalf("dispatched %d, want the 2 events of the healthy subject", dispatched)
+	}
+	if repo.dispatched[0] != repo.events[1].ID || repo.dispatched[1] != repo.events[3].ID {
+		t.Errorf("marked %v, want only the events of the healthy subject", repo.dispatched)
+	}
+	// the later event of the failed subject was not even attempted
+	if repo.leaseCalls != 1+3 {
+		t.Errorf("lease acquired %d times, want the blocked event skipped", repo.leaseCalls)
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"shield/entities"
+)
+
+// IEventPublisher publishes domain events to a message bus. Publish returns once the
+// bus accepted the event; the dispatcher retries events that fail.
+type IEventPublisher interface {
+	Publish(ctx context.Context, event *entities.EventMessage) error
+	Close() error
+}
+
+// MemoryPublisher hands events to in-process subscribers. It serves development and
+// tests, and deployments without a message bus.
+type MemoryPublisher struct {
+	mu          sync.RWMutex
+	subscribers []func(event *entities.EventMessage)
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Subscribe registers a function called synchronously with every published event.
+func (p *MemoryPublisher) Subscribe(subscriber func(event *entities.EventMessage)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers = append(p.subscribers, subscriber)
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event *entities.EventMessage) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, subscriber := range p.subscribers {
+		subscriber(event)
+	}
+	return nil
+}
+
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
+
+type NatsConfig struct {
+	URL string
+	// SubjectPrefix is prepended to the event type, giving subjects such as
+	// "shield.events.user.created".
+	SubjectPrefix string
+}
+
+type natsPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNatsPublisher connects to NATS and publishes every event on the subject made of
+// the prefix and the event type. NATS keeps the order of messages from one connection.
+func NewNatsPublisher(config NatsConfig) (IEventPublisher, error) {
+	conn, err := nats.Connect(config.URL, nats.Name("shield"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	prefix := config.SubjectPrefix
+	if prefix == "" {
+		prefix = "shield.events"
+	}
+	return &natsPublisher{
+		conn:   conn,
+		prefix: strings.TrimSuffix(prefix, "."),
+	}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, event *entities.EventMessage) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(p.prefix + "." + event.Type)
+	msg.Data = data
+	msg.Header.Set(nats.MsgIdHdr, event.ID.Hex())
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	// flushing confirms the server received the event before it leaves the outbox
+	flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return p.conn.FlushWithContext(flushCtx)
+}
+
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
+
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+}
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher publishes every event to one topic, keyed by its subject so the
+// events of a user land on the same partition and keep their order.
+func NewKafkaPublisher(config KafkaConfig) IEventPublisher {
+	topic := config.Topic
+	if topic == "" {
+		topic = "shield.events"
+	}
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, event *entities.EventMessage) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.Subject.Hex()),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(event.Type)},
+			{Key: "id", Value: []byte(event.ID.Hex())},
+		},
+	})
+}
+
+func (p *kafkaPublisher) Close() error {
+	if err := p.writer.Close(); err != nil {
+		utils.Logger.Error("failed to close kafka writer", "error: ", err.Error())
+		return err
+	}
+	return nil
+}
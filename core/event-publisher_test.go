@@ -0,0 +1,372 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/apiversions"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+)
+
+func newTestEvent(subject primitive.ObjectID, eventType string) *entities.OutboxEvent {
+	return &entities.OutboxEvent{
+		ID:        primitive.NewObjectID(),
+		Type:      eventType,
+		Subject:   subject,
+		Data:      []byte(`{"subject":"` + subject.Hex() + `"}`),
+		CreatedAt: time.Now(),
+	}
+}
+
+// natsMessage is a message received by the NATS stand-in.
+type natsMessage struct {
+	subject string
+	headers string
+	payload []byte
+}
+
+// natsStandIn speaks enough of the NATS client protocol for a publisher: it answers
+// the handshake and pings and records every published message. Once stalled it stops
+// answering pings, like a server that no longer acknowledges anything.
+type natsStandIn struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []natsMessage
+	stalled  bool
+	conns    []net.Conn
+}
+
+func newNatsStandIn(t *testing.T) *natsStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &natsStandIn{listener: listener}
+	go server.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		for _, conn := range server.conns {
+			_ = conn.Close()
+		}
+	})
+	return server
+}
+
+func (s *natsStandIn) url() string {
+	return "nats://" + s.listener.Addr().String()
+}
+
+func (s *natsStandIn) stall() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stalled = true
+}
+
+func (s *natsStandIn) received() []natsMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]natsMessage{}, s.messages...)
+}
+
+func (s *natsStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *natsStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	_, _ = fmt.Fprintf(conn, "INFO {\"server_id\":\"stand-in\",\"version\":\"2.10.0\",\"proto\":1,\"headers\":true,\"max_payload\":1048576}\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			s.mu.Lock()
+			stalled := s.stalled
+			s.mu.Unlock()
+			if !stalled {
+				_, _ = conn.Write([]byte("PONG\r\n"))
+			}
+		case "PUB", "HPUB":
+			// PUB <subject> [reply] <size>, HPUB <subject> [reply] <header size> <size>
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			headerSize := 0
+			if strings.ToUpper(fields[0]) == "HPUB" {
+				headerSize, _ = strconv.Atoi(fields[len(fields)-2])
+			}
+			body := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, body); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, natsMessage{
+				subject: fields[1],
+				headers: string(body[:headerSize]),
+				payload: body[headerSize:size],
+			})
+			s.mu.Unlock()
+		}
+	}
+}
+
+func TestNatsPublisherPublishesEventsOnTheirSubject(t *testing.T) {
+	server := newNatsStandIn(t)
+	publisher, err := NewNatsPublisher(NatsConfig{URL: server.url(), SubjectPrefix: "shield.test."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	event := newTestEvent(primitive.NewObjectID(), entities.EventUserCreated)
+	if err := publisher.Publish(context.Background(), entities.NewEventMessage(event)); err != nil {
+		t.Fatal(err)
+	}
+	// Publish returns after the flush, so the server already holds the message
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	if messages[0].subject != "shield.test."+entities.EventUserCreated {
+		t.Errorf("subject = %s", messages[0].subject)
+	}
+	if !strings.Contains(messages[0].headers, "Nats-Msg-Id: "+event.ID.Hex()) {
+		t.Errorf("headers = %q, want the event id for deduplication", messages[0].headers)
+	}
+	var body entities.EventMessage
+	if err := json.Unmarshal(messages[0].payload, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ID != event.ID || body.Subject != event.Subject || body.Type != event.Type {
+		t.Errorf("body = %+v, want the event", body)
+	}
+}
+
+func TestNatsPublisherFailsWithinTheContext(t *testing.T) {
+	server := newNatsStandIn(t)
+	publisher, err := NewNatsPublisher(NatsConfig{URL: server.url()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	server.stall()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if err := publisher.Publish(ctx, entities.NewEventMessage(newTestEvent(primitive.NewObjectID(), entities.EventUserCreated))); err == nil {
+		t.Fatal("publish succeeded without an acknowledgement from the server")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("returned after %s, want the 200ms deadline", elapsed)
+	}
+}
+
+// kafkaRecord is a record produced to the Kafka stand-in.
+type kafkaRecord struct {
+	topic     string
+	partition int32
+	key       string
+	value     []byte
+	headers   map[string]string
+}
+
+// kafkaStandIn is a single broker leading every partition of one topic. It answers
+// ApiVersions, Metadata and Produce with the codec of kafka-go and records what is
+// produced.
+type kafkaStandIn struct {
+	listener   net.Listener
+	topic      string
+	partitions int
+	mu         sync.Mutex
+	records    []kafkaRecord
+}
+
+func newKafkaStandIn(t *testing.T, topic string, partitions int) *kafkaStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := &kafkaStandIn{listener: listener, topic: topic, partitions: partitions}
+	go broker.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return broker
+}
+
+func (b *kafkaStandIn) received() []kafkaRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]kafkaRecord{}, b.records...)
+}
+
+func (b *kafkaStandIn) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *kafkaStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		version, correlationId, _, request, err := protocol.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		var response protocol.Message
+		switch request := request.(type) {
+		case *apiversions.Request:
+			response = &apiversions.Response{ApiKeys: []apiversions.ApiKeyResponse{
+				kafkaApiVersions(protocol.ApiVersions),
+				kafkaApiVersions(protocol.Metadata),
+				kafkaApiVersions(protocol.Produce),
+			}}
+		case *metadata.Request:
+			response = b.metadata(request)
+		case *produce.Request:
+			response = b.produce(request)
+		default:
+			return
+		}
+		if err := protocol.WriteResponse(conn, version, correlationId, response); err != nil {
+			return
+		}
+	}
+}
+
+func kafkaApiVersions(key protocol.ApiKey) apiversions.ApiKeyResponse {
+	return apiversions.ApiKeyResponse{ApiKey: int16(key), MinVersion: key.MinVersion(), MaxVersion: key.MaxVersion()}
+}
+
+func (b *kafkaStandIn) metadata(request *metadata.Request) *metadata.Response {
+	addr := b.listener.Addr().(*net.TCPAddr)
+	response := &metadata.Response{
+		Brokers:      []metadata.ResponseBroker{{NodeID: 1, Host: addr.IP.String(), Port: int32(addr.Port)}},
+		ControllerID: 1,
+	}
+	// no topic names asks for every topic
+	if len(request.TopicNames) != 0 && !slices.Contains(request.TopicNames, b.topic) {
+		return response
+	}
+	topic := metadata.ResponseTopic{Name: b.topic}
+	for i := 0; i < b.partitions; i++ {
+		topic.Partitions = append(topic.Partitions, metadata.ResponsePartition{
+			PartitionIndex: int32(i),
+			LeaderID:       1,
+			ReplicaNodes:   []int32{1},
+			IsrNodes:       []int32{1},
+		})
+	}
+	response.Topics = append(response.Topics, topic)
+	return response
+}
+
+func (b *kafkaStandIn) produce(request *produce.Request) *produce.Response {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	response := &produce.Response{}
+	for _, topic := range request.Topics {
+		responseTopic := produce.ResponseTopic{Topic: topic.Topic}
+		for _, partition := range topic.Partitions {
+			records := partition.RecordSet.Records
+			for {
+				record, err := records.ReadRecord()
+				if err != nil {
+					break
+				}
+				key, _ := protocol.ReadAll(record.Key)
+				value, _ := protocol.ReadAll(record.Value)
+				headers := map[string]string{}
+				for _, header := range record.Headers {
+					headers[header.Key] = string(header.Value)
+				}
+				b.records = append(b.records, kafkaRecord{
+					topic:     topic.Topic,
+					partition: partition.Partition,
+					key:       string(key),
+					value:     value,
+					headers:   headers,
+				})
+			}
+			responseTopic.Partitions = append(responseTopic.Partitions, produce.ResponsePartition{
+				Partition:  partition.Partition,
+				BaseOffset: int64(len(b.records)),
+			})
+		}
+		response.Topics = append(response.Topics, responseTopic)
+	}
+	return response
+}
+
+func TestKafkaPublisherKeepsTheEventsOfASubjectOnOnePartition(t *testing.T) {
+	broker := newKafkaStandIn(t, "shield.test", 4)
+	publisher := NewKafkaPublisher(KafkaConfig{Brokers: []string{broker.listener.Addr().String()}, Topic: "shield.test"})
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subjects := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+	var published []*entities.OutboxEvent
+	for i := 0; i < 4; i++ {
+		for _, subject := range subjects {
+			event := newTestEvent(subject, entities.EventUserVerified)
+			if err := publisher.Publish(ctx, entities.NewEventMessage(event)); err != nil {
+				t.Fatal(err)
+			}
+			published = append(published, event)
+		}
+	}
+
+	records := broker.received()
+	if len(records) != len(published) {
+		t.Fatalf("broker received %d records, want %d", len(records), len(published))
+	}
+	partitions := map[string]int32{}
+	for i, record := range records {
+		event := published[i]
+		if record.topic != "shield.test" || record.key != event.Subject.Hex() {
+			t.Errorf("record %d went to %s with key %s, want the subject as key", i, record.topic, record.key)
+		}
+		if record.headers["id"] != event.ID.Hex() || record.headers["type"] != event.Type {
+			t.Errorf("record %d headers = %v", i, record.headers)
+		}
+		if partition, ok := partitions[record.key]; ok && partition != record.partition {
+			t.Errorf("events of %s spread over partitions %d and %d", record.key, partition, record.partition)
+		}
+		partitions[record.key] = record.partition
+	}
+}
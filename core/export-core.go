@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// dataExportLifetime is how long a generated archive can be downloaded.
+const dataExportLifetime = 7 * 24 * time.Hour
+
+type IExportService interface {
+	RequestExport(ctx context.Context) (*entities.DataExport, error)
+	GetExport(ctx context.Context, id primitive.ObjectID) (*entities.DataExport, error)
+}
+
+type exportService struct {
+	IExportService
+	repo                          repository.IExportRepository
+	userRepository                repository.IUserRepository
+	authenticationRepository      repository.IAuthenticationRepository
+	loginRepository               repository.ILoginRepository
+	identityRepository            repository.IIdentityRepository
+	personalAccessTokenRepository repository.IPersonalAccessTokenRepository
+	organizationRepository        repository.IOrganizationRepository
+	roleRepository                repository.IRoleRepository
+	groupRepository               repository.IGroupRepository
+	client                        *mongo.Client
+}
+
+func NewExportService(client *mongo.Client, repository repository.IExportRepository, userRepository repository.IUserRepository, authenticationRepository repository.IAuthenticationRepository, loginRepository repository.ILoginRepository, identityRepository repository.IIdentityRepository, personalAccessTokenRepository repository.IPersonalAccessTokenRepository, organizationRepository repository.IOrganizationRepository, roleRepository repository.IRoleRepository, groupRepository repository.IGroupRepository) IExportService {
+	return &exportService{
+		repo:                          repository,
+		userRepository:                userRepository,
+		authenticationRepository:      authenticationRepository,
+		loginRepository:               loginRepository,
+		identityRepository:            identityRepository,
+		personalAccessTokenRepository: personalAccessTokenRepository,
+		organizationRepository:        organizationRepository,
+		roleRepository:                roleRepository,
+		groupRepository:               groupRepository,
+		client:                        client,
+	}
+}
+
+// RequestExport starts assembling an archive of the data of the caller in the
+// background. A request made while another one is pending returns the pending one.
+func (s *exportService) RequestExport(ctx context.Context) (*entities.DataExport, error) {
+	userId := ctx.Value("UserId").(primitive.ObjectID)
+	if pending, err := s.repo.FindPendingByUserId(ctx, userId); err == nil {
+		return pending, nil
+	}
+	now := time.Now()
+	export, err := s.repo.InsertOne(ctx, &entities.DataExport{
+		UserId:    userId,
+		Status:    entities.DataExportPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(dataExportLifetime),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert data export", "error: ", err.Error())
+		return nil, err
+	}
+	go s.generate(context.Background(), export.ID, userId)
+	utils.Logger.Info("requested data export")
+	return export, nil
+}
+
+// GetExport returns an export of the caller, including the archive once it is ready.
+func (s *exportService) GetExport(ctx context.Context, id primitive.ObjectID) (*entities.DataExport, error) {
+	result, err := s.repo.FindOneById(ctx, ctx.Value("UserId").(primitive.ObjectID), id)
+	if err != nil {
+		return nil, fmt.Errorf("export not found")
+	}
+	return result, nil
+}
+
+func (s *exportService) generate(ctx context.Context, id primitive.ObjectID, userId primitive.ObjectID) {
+	archive, err := s.assemble(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to assemble data export", "error: ", err.Error())
+		if err := s.repo.Fail(ctx, id, "failed to assemble the archive"); err != nil {
+			utils.Logger.Error("failed to update data export", "error: ", err.Error())
+		}
+		return
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		utils.Logger.Error("failed to encode data export", "error: ", err.Error())
+		_ = s.repo.Fail(ctx, id, "failed to encode the archive")
+		return
+	}
+	if err := s.repo.Complete(ctx, id, data); err != nil {
+		utils.Logger.Error("failed to update data export", "error: ", err.Error())
+		return
+	}
+	utils.Logger.Info("completed data export")
+}
+
+func (s *exportService) assemble(ctx context.Context, userId primitive.ObjectID) (*entities.UserDataArchive, error) {
+	record, err := s.userRepository.FindRecordById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	archive := &entities.UserDataArchive{
+		GeneratedAt: time.Now(),
+		Profile:     entities.NewUserView(record),
+	}
+	if archive.Sessions, err = s.authenticationRepository.FindManyByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+	if archive.LoginHistory, err = s.loginRepository.FindManyByUserId(ctx, userId, &entities.LoginQuery{}); err != nil {
+		return nil, err
+	}
+	if archive.Identities, err = s.identityRepository.FindManyByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+	if archive.PersonalAccessTokens, err = s.personalAccessTokenRepository.FindManyByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+	if archive.Memberships, err = s.organizationRepository.FindMembershipsByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+	if archive.RoleAssignments, err = s.roleRepository.FindAssignmentsByUserId(ctx, userId); err != nil {
+		return nil, err
+	}
+	if archive.GroupMemberships, err = s.groupRepository.FindParents(ctx, entities.GroupMemberUser, []primitive.ObjectID{userId}); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
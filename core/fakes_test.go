@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// The fakes below keep their records in memory and implement only the repository
+// methods the tests reach; calling any other method panics on the nil embedded interface.
+
+type fakeUserRepository struct {
+	repository.IUserRepository
+	mu    sync.Mutex
+	users map[primitive.ObjectID]*models.User
+}
+
+func newFakeUserRepository(users ...*models.User) *fakeUserRepository {
+	r := &fakeUserRepository{users: map[primitive.ObjectID]*models.User{}}
+	for _, user := range users {
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+func (r *fakeUserRepository) InsertOne(_ context.Context, user *models.User) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.ID = primitive.NewObjectID()
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *fakeUserRepository) FindOneById(_ context.Context, id primitive.ObjectID) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *fakeUserRepository) FindOneByEmail(_ context.Context, email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *fakeUserRepository) UpdateRole(_ context.Context, id primitive.ObjectID, role constants.Role) (*entities.UserRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	user.Role = role
+	return &entities.UserRecord{User: *user}, nil
+}
+
+type fakeIdentityRepository struct {
+	repository.IIdentityRepository
+	mu         sync.Mutex
+	identities []entities.Identity
+}
+
+func (r *fakeIdentityRepository) InsertOne(_ context.Context, identity *entities.Identity) (*entities.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity.ID = primitive.NewObjectID()
+	r.identities = append(r.identities, *identity)
+	return identity, nil
+}
+
+func (r *fakeIdentityRepository) FindOneByProviderSubject(_ context.Context, provider string, subject string) (*entities.Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.identities {
+		if r.identities[i].Provider == provider && r.identities[i].Subject == subject {
+			identity := r.identities[i]
+			return &identity, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+// fakeUserService registers users into its repository as tenants, like the user
+// service does, and records the emails it registered.
+type fakeUserService struct {
+	IUserService
+	users      *fakeUserRepository
+	registered []string
+}
+
+func (s *fakeUserService) CreateUser(ctx context.Context, user *models.User, _ *entities.ProfileDocument) (*models.User, error) {
+	if user.Password == "" {
+		return nil, errors.New("password is required")
+	}
+	s.registered = append(s.registered, user.Email)
+	user.Role = constants.Tenant
+	return s.users.InsertOne(ctx, user)
+}
+
+// fakeAuthenticationService records the users logins were created for.
+type fakeAuthenticationService struct {
+	IAuthenticationService
+	logins []primitive.ObjectID
+}
+
+func (s *fakeAuthenticationService) CreateLogin(_ context.Context, user *models.User) (*models.LoginOutput, error) {
+	s.logins = append(s.logins, user.ID)
+	return &models.LoginOutput{Token: "token-" + user.ID.Hex()}, nil
+}
+
+func newTestUser(email string) *models.User {
+	return &models.User{Email: email, Role: constants.Tenant}
+}
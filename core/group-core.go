@@ -0,0 +1,302 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+type IGroupService interface {
+	CreateGroup(ctx context.Context, organizationId primitive.ObjectID, group *entities.Group) (*entities.Group, error)
+	GetGroups(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Group, error)
+	UpdateGroup(ctx context.Context, organizationId primitive.ObjectID, group *entities.Group) (*entities.Group, error)
+	DeleteGroup(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Group, error)
+	GetMembers(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) ([]entities.GroupMember, error)
+	AddMember(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID, input *entities.GroupMemberInput) (*entities.GroupMember, error)
+	RemoveMember(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID, memberId primitive.ObjectID) (*entities.GroupMember, error)
+	GetEffectiveGroups(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) ([]entities.Group, error)
+}
+
+type groupService struct {
+	IGroupService
+	repo                   repository.IGroupRepository
+	organizationRepository repository.IOrganizationRepository
+	roleRepository         repository.IRoleRepository
+	client                 *mongo.Client
+}
+
+func NewGroupService(client *mongo.Client, repository repository.IGroupRepository, organizationRepository repository.IOrganizationRepository, roleRepository repository.IRoleRepository) IGroupService {
+	return &groupService{
+		repo:                   repository,
+		organizationRepository: organizationRepository,
+		roleRepository:         roleRepository,
+		client:                 client,
+	}
+}
+
+func (s *groupService) CreateGroup(ctx context.Context, organizationId primitive.ObjectID, group *entities.Group) (*entities.Group, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.organizationRepository.FindOneById(ctx, organizationId); err != nil {
+		return nil, fmt.Errorf("organization not found")
+	}
+	if err := s.checkRoles(ctx, group.RoleIds); err != nil {
+		return nil, err
+	}
+	group.OrganizationId = organizationId
+	group.CreatedAt = time.Now()
+	group.UpdatedAt = group.CreatedAt
+	result, err := s.repo.InsertOne(ctx, group)
+	if err != nil {
+		utils.Logger.Error("failed to insert group", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created group")
+	return result, nil
+}
+
+func (s *groupService) GetGroups(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Group, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindManyByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find groups", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *groupService) UpdateGroup(ctx context.Context, organizationId primitive.ObjectID, group *entities.Group) (*entities.Group, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.findGroup(ctx, organizationId, group.ID); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoles(ctx, group.RoleIds); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.UpdateOne(ctx, group)
+	if err != nil {
+		utils.Logger.Error("failed to update group", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("updated group")
+	return result, nil
+}
+
+// DeleteGroup deletes the group along with its memberships, including those in
+// parent groups.
+func (s *groupService) DeleteGroup(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Group, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	if _, err := s.findGroup(ctx, organizationId, id); err != nil {
+		return nil, err
+	}
+	err = s.repo.DeleteMembersByMemberId(ctx, entities.GroupMemberGroup, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete group memberships", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	result, err := s.repo.DeleteOneById(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete group", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("deleted group")
+	return result, nil
+}
+
+func (s *groupService) GetMembers(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) ([]entities.GroupMember, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.findGroup(ctx, organizationId, id); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindMembersByGroupId(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to find group members", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+// AddMember adds a user of the organization or another group of the organization to
+// the group. Adding a group that already contains the group, directly or not, would
+// create a cycle and is rejected.
+func (s *groupService) AddMember(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID, input *entities.GroupMemberInput) (*entities.GroupMember, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.findGroup(ctx, organizationId, id); err != nil {
+		return nil, err
+	}
+	if input.MemberType == entities.GroupMemberUser {
+		if _, err := s.organizationRepository.FindMembership(ctx, organizationId, input.MemberId); err != nil {
+			return nil, fmt.Errorf("user is not a member of the organization")
+		}
+	} else {
+		if _, err := s.findGroup(ctx, organizationId, input.MemberId); err != nil {
+			return nil, err
+		}
+		ancestors, err := s.ancestors(ctx, entities.GroupMemberGroup, id)
+		if err != nil {
+			return nil, err
+		}
+		if input.MemberId == id || ancestors[input.MemberId] {
+			return nil, fmt.Errorf("group membership would create a cycle")
+		}
+	}
+	result, err := s.repo.InsertMember(ctx, &entities.GroupMember{
+		GroupId:    id,
+		MemberType: input.MemberType,
+		MemberId:   input.MemberId,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert group member", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("added group member")
+	return result, nil
+}
+
+func (s *groupService) RemoveMember(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID, memberId primitive.ObjectID) (*entities.GroupMember, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.findGroup(ctx, organizationId, id); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.DeleteMember(ctx, id, memberId)
+	if err != nil {
+		return nil, fmt.Errorf("group member not found")
+	}
+	utils.Logger.Info("removed group member")
+	return result, nil
+}
+
+func (s *groupService) GetEffectiveGroups(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) ([]entities.Group, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	return effectiveGroups(ctx, s.repo, organizationId, userId)
+}
+
+func (s *groupService) findGroup(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Group, error) {
+	group, err := s.repo.FindOneById(ctx, id)
+	if err != nil || group.OrganizationId != organizationId {
+		return nil, fmt.Errorf("group not found")
+	}
+	return group, nil
+}
+
+// checkRoles fails unless the roles exist and the caller holds every permission they
+// grant. System roles stay with users and are never granted through groups.
+func (s *groupService) checkRoles(ctx context.Context, roleIds []primitive.ObjectID) error {
+	if len(roleIds) == 0 {
+		return nil
+	}
+	roles, err := s.roleRepository.FindManyByIds(ctx, roleIds)
+	if err != nil {
+		utils.Logger.Error("failed to find roles", "error: ", err.Error())
+		return err
+	}
+	if len(roles) != len(roleIds) {
+		return fmt.Errorf("role not found")
+	}
+	permissions, _ := ctx.Value("Permissions").([]string)
+	for _, role := range roles {
+		if role.System {
+			return fmt.Errorf("system role %s cannot be granted to a group", role.Name)
+		}
+		for _, permission := range role.Permissions {
+			if !PermissionGranted(permissions, permission) {
+				return fmt.Errorf("role %s exceeds the permissions of the caller", role.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *groupService) ancestors(ctx context.Context, memberType string, memberId primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	return groupAncestors(ctx, s.repo, memberType, memberId)
+}
+
+// groupAncestors walks up the membership graph and returns the ids of every group that
+// contains the member, directly or through nested groups. Visited groups are not
+// expanded twice, so the walk terminates even on a cyclic graph.
+func groupAncestors(ctx context.Context, repo repository.IGroupRepository, memberType string, memberId primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	visited := map[primitive.ObjectID]bool{}
+	parents, err := repo.FindParents(ctx, memberType, []primitive.ObjectID{memberId})
+	for err == nil && len(parents) > 0 {
+		var frontier []primitive.ObjectID
+		for _, parent := range parents {
+			if !visited[parent.GroupId] {
+				visited[parent.GroupId] = true
+				frontier = append(frontier, parent.GroupId)
+			}
+		}
+		if len(frontier) == 0 {
+			break
+		}
+		parents, err = repo.FindParents(ctx, entities.GroupMemberGroup, frontier)
+	}
+	if err != nil {
+		utils.Logger.Error("failed to find parent groups", "error: ", err.Error())
+		return nil, err
+	}
+	return visited, nil
+}
+
+// effectiveGroups returns the groups of the organization the user belongs to, either
+// directly or through nested groups.
+func effectiveGroups(ctx context.Context, repo repository.IGroupRepository, organizationId primitive.ObjectID, userId primitive.ObjectID) ([]entities.Group, error) {
+	if organizationId.IsZero() {
+		return nil, nil
+	}
+	ancestors, err := groupAncestors(ctx, repo, entities.GroupMemberUser, userId)
+	if err != nil || len(ancestors) == 0 {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(ancestors))
+	for id := range ancestors {
+		ids = append(ids, id)
+	}
+	groups, err := repo.FindManyByIds(ctx, ids)
+	if err != nil {
+		utils.Logger.Error("failed to find groups", "error: ", err.Error())
+		return nil, err
+	}
+	var result []entities.Group
+	for _, group := range groups {
+		if group.OrganizationId == organizationId {
+			result = append(result, group)
+		}
+	}
+	return result, nil
+}
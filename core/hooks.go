@@ -0,0 +1,190 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"shield/entities"
+)
+
+// Errors returned by pre hooks. ErrHookUnavailable is only returned by fail-closed hooks.
+var (
+	ErrHookDenied      = errors.New("denied by hook")
+	ErrHookUnavailable = errors.New("hook unavailable")
+)
+
+// defaultHookTimeout bounds hooks registered without a timeout.
+const defaultHookTimeout = 2 * time.Second
+
+// IHook is called at a hook point. A nil response allows the action.
+type IHook interface {
+	Call(ctx context.Context, request *entities.HookRequest) (*entities.HookResponse, error)
+}
+
+// HookFunc adapts an in-process function to IHook.
+type HookFunc func(ctx context.Context, request *entities.HookRequest) (*entities.HookResponse, error)
+
+func (f HookFunc) Call(ctx context.Context, request *entities.HookRequest) (*entities.HookResponse, error) {
+	return f(ctx, request)
+}
+
+// HookPolicy controls a registered hook. A hook failing or timing out lets the action
+// proceed when FailOpen is set and fails it otherwise.
+type HookPolicy struct {
+	Timeout  time.Duration
+	FailOpen bool
+}
+
+// IHookRunner calls the hooks registered for a hook point in registration order.
+// Hooks are registered at startup, before the runner is shared.
+type IHookRunner interface {
+	Register(point string, name string, hook IHook, policy HookPolicy)
+	// Run calls the pre hooks of request.Point and returns the claims they added, later
+	// hooks overriding earlier ones. It stops at the first hook denying the action.
+	Run(ctx context.Context, request *entities.HookRequest) (map[string]interface{}, error)
+	// Notify calls the post hooks of request.Point in the background.
+	Notify(ctx context.Context, request *entities.HookRequest)
+}
+
+type registeredHook struct {
+	name   string
+	hook   IHook
+	policy HookPolicy
+}
+
+type hookRunner struct {
+	hooks map[string][]registeredHook
+}
+
+func NewHookRunner() IHookRunner {
+	return &hookRunner{
+		hooks: map[string][]registeredHook{},
+	}
+}
+
+func (r *hookRunner) Register(point string, name string, hook IHook, policy HookPolicy) {
+	if policy.Timeout <= 0 {
+		policy.Timeout = defaultHookTimeout
+	}
+	r.hooks[point] = append(r.hooks[point], registeredHook{name: name, hook: hook, policy: policy})
+}
+
+func (r *hookRunner) Run(ctx context.Context, request *entities.HookRequest) (map[string]interface{}, error) {
+	var claims map[string]interface{}
+	for _, registered := range r.hooks[request.Point] {
+		response, err := r.call(ctx, registered, request)
+		if err != nil {
+			if registered.policy.FailOpen {
+				continue
+			}
+			return nil, fmt.Errorf("%w: %s", ErrHookUnavailable, registered.name)
+		}
+		if response == nil {
+			continue
+		}
+		if response.Deny {
+			utils.Logger.Info("action denied by hook", "hook: ", registered.name, "point: ", request.Point)
+			if response.Reason == "" {
+				return nil, ErrHookDenied
+			}
+			return nil, fmt.Errorf("%w: %s", ErrHookDenied, response.Reason)
+		}
+		for name, value := range response.Claims {
+			if claims == nil {
+				claims = map[string]interface{}{}
+			}
+			claims[name] = value
+		}
+	}
+	return claims, nil
+}
+
+func (r *hookRunner) Notify(ctx context.Context, request *entities.HookRequest) {
+	registered := r.hooks[request.Point]
+	if len(registered) == 0 {
+		return
+	}
+	// the hooks outlive the request that triggered them
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		for _, hook := range registered {
+			_, _ = r.call(ctx, hook, request)
+		}
+	}()
+}
+
+// call calls a single hook within its timeout and logs its failure.
+func (r *hookRunner) call(ctx context.Context, registered registeredHook, request *entities.HookRequest) (*entities.HookResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, registered.policy.Timeout)
+	defer cancel()
+	response, err := registered.hook.Call(ctx, request)
+	if err != nil {
+		utils.Logger.Error("hook failed", "hook: ", registered.name, "point: ", request.Point, "error: ", err.Error())
+		return nil, err
+	}
+	return response, nil
+}
+
+type HttpHookConfig struct {
+	URL string
+	// Secret signs requests with a Shield-Signature header like webhook deliveries.
+	Secret string
+}
+
+type httpHook struct {
+	config     HttpHookConfig
+	httpClient *http.Client
+}
+
+// NewHttpHook returns a hook that POSTs the request as JSON to the configured URL. A
+// 2xx answer with an empty body allows the action; any other status is a failure.
+func NewHttpHook(config HttpHookConfig) IHook {
+	return &httpHook{
+		config:     config,
+		httpClient: &http.Client{},
+	}
+}
+
+func (h *httpHook) Call(ctx context.Context, request *entities.HookRequest) (*entities.HookResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Shield-Hook", request.Point)
+	if h.config.Secret != "" {
+		httpRequest.Header.Set("Shield-Signature", WebhookSignature(h.config.Secret, time.Now(), payload))
+	}
+	httpResponse, err := h.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(httpResponse.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return nil, fmt.Errorf("hook answered %d", httpResponse.StatusCode)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+	var response entities.HookResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
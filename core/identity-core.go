@@ -0,0 +1,200 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+type IIdentityService interface {
+	GetIdentities(ctx context.Context) (*entities.IdentitiesOutput, error)
+	UnlinkIdentity(ctx context.Context, id primitive.ObjectID) (*entities.Identity, error)
+}
+
+type identityService struct {
+	IIdentityService
+	repo           repository.IIdentityRepository
+	userRepository repository.IUserRepository
+	client         *mongo.Client
+}
+
+func NewIdentityService(client *mongo.Client, repository repository.IIdentityRepository, userRepository repository.IUserRepository) IIdentityService {
+	return &identityService{
+		repo:           repository,
+		userRepository: userRepository,
+		client:         client,
+	}
+}
+
+func (s *identityService) GetIdentities(ctx context.Context) (*entities.IdentitiesOutput, error) {
+	userId := ctx.Value("UserId").(primitive.ObjectID)
+	user, err := s.userRepository.FindOneById(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find user", "error: ", err.Error())
+		return nil, err
+	}
+	identities, err := s.repo.FindManyByUserId(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find identities", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("fetched identities")
+	return &entities.IdentitiesOutput{
+		Identities:  identities,
+		HasPassword: user.Password != "",
+	}, nil
+}
+
+// UnlinkIdentity removes one of the caller's identities, unless it is the last way
+// left for them to sign in.
+func (s *identityService) UnlinkIdentity(ctx context.Context, id primitive.ObjectID) (*entities.Identity, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	userId := ctx.Value("UserId").(primitive.ObjectID)
+	// concurrent unlinks of the user conflict here, so only one of them can pass the
+	// last login method check below
+	err = s.repo.LockUser(sessionCtx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to lock identities", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	identity, err := s.repo.FindOneById(sessionCtx, id)
+	if err != nil || identity.UserId != userId {
+		utils.Logger.Info("identity not found for user")
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("identity not found")
+	}
+	user, err := s.userRepository.FindOneById(sessionCtx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	identities, err := s.repo.FindManyByUserId(sessionCtx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find identities", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	if len(identities) == 1 && user.Password == "" {
+		utils.Logger.Info("refusing to unlink last login method")
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("cannot unlink the last login method")
+	}
+	identity, err = s.repo.DeleteOneById(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete identity", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	err = session.CommitTransaction(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to commit mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("unlinked identity")
+	return identity, nil
+}
+
+// linkIdentity links an external identity to an already signed in user.
+func linkIdentity(ctx context.Context, identityRepository repository.IIdentityRepository, userId primitive.ObjectID, provider string, subject string, email string) (*entities.Identity, error) {
+	identity, err := identityRepository.FindOneByProviderSubject(ctx, provider, subject)
+	if err == nil {
+		if identity.UserId != userId {
+			utils.Logger.Info("identity already linked to another user")
+			return nil, fmt.Errorf("identity already linked to another account")
+		}
+		return identity, nil
+	}
+	identity, err = identityRepository.InsertOne(ctx, &entities.Identity{
+		UserId:    userId,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to link identity", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("linked external identity")
+	return identity, nil
+}
+
+// resolveExternalUser finds the user linked to an external identity. Unknown identities
+// are linked to the user with the same verified email, or to a user registered for them.
+func resolveExternalUser(ctx context.Context, identityRepository repository.IIdentityRepository, userRepository repository.IUserRepository, userService IUserService, provider string, subject string, email string, emailVerified bool, newUser func() *models.User) (*models.User, error) {
+	identity, err := identityRepository.FindOneByProviderSubject(ctx, provider, subject)
+	if err == nil {
+		return userRepository.FindOneById(ctx, identity.UserId)
+	}
+	if email == "" || !emailVerified {
+		utils.Logger.Info("refusing to link identity without a verified email")
+		return nil, fmt.Errorf("email not verified")
+	}
+	user, err := userRepository.FindOneByEmail(ctx, email)
+	if err != nil {
+		user, err = createExternalUser(ctx, userRepository, userService, newUser())
+		if err != nil {
+			return nil, err
+		}
+		utils.Logger.Info("created user for external identity")
+	}
+	_, err = identityRepository.InsertOne(ctx, &entities.Identity{
+		UserId:    user.ID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to link identity", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("linked external identity")
+	return user, nil
+}
+
+// createExternalUser registers a user signing in through an external identity like any
+// other registration, so hooks, events and audit records see it, and then gives it the
+// role the identity maps to.
+func createExternalUser(ctx context.Context, userRepository repository.IUserRepository, userService IUserService, user *models.User) (*models.User, error) {
+	role := user.Role
+	// external users sign in through their identity provider
+	password, err := tokens.GenerateSecret("", 32)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = password
+	user, err = userService.CreateUser(ctx, user, nil)
+	if err != nil {
+		return nil, err
+	}
+	if role != "" && role != user.Role {
+		if _, err := userRepository.UpdateRole(ctx, user.ID, role); err != nil {
+			utils.Logger.Error("failed to update role", "error: ", err.Error())
+			return nil, err
+		}
+		user.Role = role
+	}
+	return user, nil
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+const (
+	invitationDefaultTTL = 7 * 24 * time.Hour
+	invitationMaxTTL     = 30 * 24 * time.Hour
+)
+
+type IInvitationService interface {
+	CreateInvitation(ctx context.Context, organizationId primitive.ObjectID, input *entities.InvitationInput) (*entities.Invitation, error)
+	GetInvitations(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Invitation, error)
+	RevokeInvitation(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Invitation, error)
+	AcceptInvitation(ctx context.Context, input *entities.InvitationAcceptInput) (*entities.Membership, error)
+}
+
+type invitationService struct {
+	IInvitationService
+	repo                   repository.IInvitationRepository
+	organizationRepository repository.IOrganizationRepository
+	userRepository         repository.IUserRepository
+	userService            IUserService
+	notifier               INotifier
+	acceptURL              string
+	client                 *mongo.Client
+}
+
+// NewInvitationService returns the invitation service. Invite tokens are sent through
+// notifier as a link to acceptURL, or on their own when acceptURL is empty.
+func NewInvitationService(client *mongo.Client, repository repository.IInvitationRepository, organizationRepository repository.IOrganizationRepository, userRepository repository.IUserRepository, userService IUserService, notifier INotifier, acceptURL string) IInvitationService {
+	return &invitationService{
+		repo:                   repository,
+		organizationRepository: organizationRepository,
+		userRepository:         userRepository,
+		userService:            userService,
+		notifier:               notifier,
+		acceptURL:              acceptURL,
+		client:                 client,
+	}
+}
+
+func (s *invitationService) CreateInvitation(ctx context.Context, organizationId primitive.ObjectID, input *entities.InvitationInput) (*entities.Invitation, error) {
+	role, err := authorizeOrganization(ctx, s.organizationRepository, organizationId, entities.OrganizationOwner, entities.OrganizationAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if input.Role == entities.OrganizationOwner && role != entities.OrganizationOwner {
+		return nil, fmt.Errorf("only owners can invite owners")
+	}
+	now := time.Now()
+	expiresAt := now.Add(invitationDefaultTTL)
+	if input.ExpiresAt != nil {
+		if !input.ExpiresAt.After(now) || input.ExpiresAt.After(now.Add(invitationMaxTTL)) {
+			return nil, fmt.Errorf("invitations must expire within %s", invitationMaxTTL)
+		}
+		expiresAt = *input.ExpiresAt
+	}
+	organization, err := s.organizationRepository.FindOneById(ctx, organizationId)
+	if err != nil {
+		return nil, fmt.Errorf("organization not found")
+	}
+	token, err := tokens.GenerateSecret(entities.InvitationPrefix, 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate invitation token", "error: ", err.Error())
+		return nil, err
+	}
+	invitation := entities.Invitation{
+		OrganizationId: organizationId,
+		Email:          strings.ToLower(strings.TrimSpace(input.Email)),
+		Role:           input.Role,
+		TokenHash:      tokens.HashSecret(token),
+		InvitedBy:      ctx.Value("UserId").(primitive.ObjectID),
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now,
+	}
+	result, err := s.repo.InsertOne(ctx, &invitation)
+	if err != nil {
+		utils.Logger.Error("failed to insert invitation", "error: ", err.Error())
+		return nil, err
+	}
+	link := token
+	if s.acceptURL != "" {
+		link = s.acceptURL + "?token=" + token
+	}
+	err = s.notifier.Notify(ctx, &entities.Notification{
+		To:      result.Email,
+		Subject: fmt.Sprintf("You have been invited to %s", organization.Name),
+		Body: fmt.Sprintf("You have been invited to join %s as %s.\n\nAccept the invitation before %s: %s",
+			organization.Name, result.Role, result.ExpiresAt.Format(time.RFC1123), link),
+	})
+	if err != nil {
+		_, _ = s.repo.DeletePending(ctx, organizationId, result.ID)
+		return nil, fmt.Errorf("failed to send invitation")
+	}
+	utils.Logger.Info("created invitation")
+	return result, nil
+}
+
+// GetInvitations returns the invitations of the organization that are neither accepted
+// nor expired.
+func (s *invitationService) GetInvitations(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Invitation, error) {
+	if _, err := authorizeOrganization(ctx, s.organizationRepository, organizationId, entities.OrganizationOwner, entities.OrganizationAdmin); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindPendingByOrganizationId(ctx, organizationId, time.Now())
+	if err != nil {
+		utils.Logger.Error("failed to find invitations", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *invitationService) RevokeInvitation(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Invitation, error) {
+	if _, err := authorizeOrganization(ctx, s.organizationRepository, organizationId, entities.OrganizationOwner, entities.OrganizationAdmin); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.DeletePending(ctx, organizationId, id)
+	if err != nil {
+		return nil, fmt.Errorf("invitation not found")
+	}
+	utils.Logger.Info("revoked invitation")
+	return result, nil
+}
+
+// AcceptInvitation adds the invitee to the organization. An existing account with the
+// invited email is attached regardless of the casing it was registered with; otherwise
+// a new account is created from the input.
+func (s *invitationService) AcceptInvitation(ctx context.Context, input *entities.InvitationAcceptInput) (*entities.Membership, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	invitation, err := s.repo.FindOneByHash(ctx, tokens.HashSecret(input.Token))
+	if err != nil || invitation.AcceptedAt != nil {
+		return nil, fmt.Errorf("invalid invitation")
+	}
+	if invitation.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("invitation expired")
+	}
+	user, err := s.userRepository.FindOneByEmailFold(ctx, invitation.Email)
+	if err != nil {
+		if input.Password == "" {
+			return nil, fmt.Errorf("password is required to create an account")
+		}
+		user, err = s.userService.CreateUser(ctx, &models.User{
+			Email:     invitation.Email,
+			FirstName: input.FirstName,
+			LastName:  input.LastName,
+			Password:  input.Password,
+		}, nil)
+		if err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	} else if _, err := s.organizationRepository.FindAnyMembership(ctx, invitation.OrganizationId, user.ID); err == nil {
+		return nil, fmt.Errorf("user is already a member of the organization")
+	}
+	_, err = s.repo.MarkAccepted(ctx, invitation.ID, user.ID, time.Now())
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("invalid invitation")
+	}
+	result, err := s.organizationRepository.InsertMembership(ctx, &entities.Membership{
+		OrganizationId: invitation.OrganizationId,
+		UserId:         user.ID,
+		Role:           invitation.Role,
+		CreatedAt:      time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert membership", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("accepted invitation")
+	return result, nil
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/utils"
+	"github.com/go-ldap/ldap/v3"
+	"shield/entities"
+)
+
+type LdapConfig struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter is a filter with a single %s for the escaped login email.
+	UserFilter     string
+	StartTLS       bool
+	PoolSize       int
+	GroupAttribute string
+	GroupRoles     map[string]constants.Role
+	// Exclusive rejects users missing from the directory instead of passing them on
+	// to the local users collection, and fails logins while the directory is unreachable.
+	Exclusive bool
+	// Timeout bounds every directory request, 5 seconds by default. A shorter deadline
+	// of the login context wins.
+	Timeout time.Duration
+}
+
+type ldapBackend struct {
+	IAuthenticationBackend
+	config LdapConfig
+	pool   chan *ldap.Conn
+}
+
+func NewLdapBackend(config LdapConfig) IAuthenticationBackend {
+	if config.UserFilter == "" {
+		config.UserFilter = "(&(objectClass=person)(mail=%s))"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	if config.PoolSize <= 0 {
+		config.PoolSize = 4
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	return &ldapBackend{
+		config: config,
+		pool:   make(chan *ldap.Conn, config.PoolSize),
+	}
+}
+
+func (b *ldapBackend) Name() string {
+	return "ldap"
+}
+
+// Authenticate looks the user up with the service account and then binds as the
+// user to verify the password.
+func (b *ldapBackend) Authenticate(ctx context.Context, email string, password string) (*entities.DirectoryUser, error) {
+	if password == "" {
+		// an empty password would be an unauthenticated bind, which most servers accept
+		return nil, ErrInvalidCredentials
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, b.unavailable(err)
+	}
+	timeout := b.timeout(ctx)
+	conn, err := b.acquire(timeout)
+	if err != nil {
+		utils.Logger.Error("failed to connect to ldap", "error: ", err.Error())
+		return nil, b.unavailable(err)
+	}
+	conn.SetTimeout(timeout)
+	// closing the connection aborts the request in flight once ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	healthy := true
+	defer func() {
+		if !stop() {
+			healthy = false
+		}
+		b.release(conn, healthy)
+	}()
+
+	search := ldap.NewSearchRequest(
+		b.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(b.config.UserFilter, ldap.EscapeFilter(email)),
+		[]string{"dn", "mail", "givenName", "sn", b.config.GroupAttribute},
+		nil,
+	)
+	result, err := conn.Search(search)
+	if err != nil {
+		healthy = false
+		utils.Logger.Error("failed to search ldap", "error: ", err.Error())
+		return nil, b.unavailable(err)
+	}
+	if len(result.Entries) != 1 {
+		if b.config.Exclusive {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, ErrUnknownUser
+	}
+	entry := result.Entries[0]
+	err = conn.Bind(entry.DN, password)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			utils.Logger.Info("ldap rejected credentials")
+			return nil, ErrInvalidCredentials
+		}
+		healthy = false
+		utils.Logger.Error("failed to bind to ldap", "error: ", err.Error())
+		return nil, b.unavailable(err)
+	}
+	// restore the service account identity before the connection goes back to the pool
+	err = conn.Bind(b.config.BindDN, b.config.BindPassword)
+	if err != nil {
+		healthy = false
+	}
+
+	user := entities.DirectoryUser{
+		Backend:   b.Name(),
+		Subject:   entry.DN,
+		Email:     entry.GetAttributeValue("mail"),
+		FirstName: entry.GetAttributeValue("givenName"),
+		LastName:  entry.GetAttributeValue("sn"),
+		Role:      constants.Tenant,
+	}
+	if user.Email == "" {
+		user.Email = email
+	}
+	for _, group := range entry.GetAttributeValues(b.config.GroupAttribute) {
+		if role, ok := b.config.GroupRoles[strings.ToLower(group)]; ok {
+			user.Role = role
+			if role == constants.Root {
+				break
+			}
+		}
+	}
+	return &user, nil
+}
+
+// unavailable reports a failure to reach the directory. Unless the directory is
+// exclusive, the login is passed on to the local users collection.
+func (b *ldapBackend) unavailable(err error) error {
+	if b.config.Exclusive {
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrBackendUnavailable, err.Error())
+}
+
+// timeout returns how long a directory request may take within the deadline of ctx.
+func (b *ldapBackend) timeout(ctx context.Context) time.Duration {
+	timeout := b.config.Timeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	return timeout
+}
+
+func (b *ldapBackend) acquire(timeout time.Duration) (*ldap.Conn, error) {
+	select {
+	case conn := <-b.pool:
+		if !conn.IsClosing() {
+			return conn, nil
+		}
+		conn.Close()
+	default:
+	}
+	return b.dial(timeout)
+}
+
+func (b *ldapBackend) release(conn *ldap.Conn, healthy bool) {
+	if !healthy {
+		conn.Close()
+		return
+	}
+	select {
+	case b.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (b *ldapBackend) dial(timeout time.Duration) (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(b.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if b.config.StartTLS {
+		u, err := url.Parse(b.config.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		err = conn.StartTLS(&tls.Config{ServerName: u.Hostname()})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	err = conn.Bind(b.config.BindDN, b.config.BindPassword)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
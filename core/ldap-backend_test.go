@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapStubBindDN       = "cn=shield,dc=example,dc=com"
+	ldapStubBindPassword = "service"
+	ldapStubAdminsGroup  = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+type ldapStubEntry struct {
+	password   string
+	attributes map[string][]string
+}
+
+// ldapStub is an in-process directory answering simple binds and searches by mail.
+// With hang set it accepts searches without ever answering them.
+type ldapStub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	entries  map[string]ldapStubEntry
+	hang     bool
+	dials    int
+	conns    []net.Conn
+}
+
+func newLdapStub(t *testing.T) *ldapStub {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &ldapStub{
+		listener: listener,
+		entries: map[string]ldapStubEntry{
+			ldapStubBindDN: {password: ldapStubBindPassword},
+		},
+	}
+	go stub.serve()
+	t.Cleanup(stub.close)
+	return stub
+}
+
+func (s *ldapStub) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStub) add(dn string, password string, attributes map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[dn] = ldapStubEntry{password: password, attributes: attributes}
+}
+
+func (s *ldapStub) close() {
+	_ = s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+func (s *ldapStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.dials++
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+var ldapStubMailFilter = regexp.MustCompile(`\(mail=([^)]*)\)`)
+
+func (s *ldapStub) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageId := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := request.Children[1].Value.(string)
+			password := request.Children[2].Data.String()
+			s.mu.Lock()
+			entry, ok := s.entries[dn]
+			s.mu.Unlock()
+			code := uint16(ldap.LDAPResultSuccess)
+			if !ok || entry.password != password {
+				code = ldap.LDAPResultInvalidCredentials
+			}
+			s.reply(conn, messageId, ldapResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			s.mu.Lock()
+			hang := s.hang
+			s.mu.Unlock()
+			if hang {
+				continue
+			}
+			filter, err := ldap.DecompileFilter(request.Children[6])
+			if err != nil {
+				return
+			}
+			var mail string
+			if match := ldapStubMailFilter.FindStringSubmatch(filter); match != nil {
+				mail = match[1]
+			}
+			s.mu.Lock()
+			for dn, entry := range s.entries {
+				if mail == "" || len(entry.attributes["mail"]) == 0 || entry.attributes["mail"][0] != mail {
+					continue
+				}
+				s.reply(conn, messageId, ldapSearchEntry(dn, entry.attributes))
+			}
+			s.mu.Unlock()
+			s.reply(conn, messageId, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *ldapStub) reply(conn net.Conn, messageId int64, op *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageId, "MessageID"))
+	envelope.AppendChild(op)
+	_, _ = conn.Write(envelope.Bytes())
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return result
+}
+
+func ldapSearchEntry(dn string, attributes map[string][]string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(set)
+		list.AppendChild(attribute)
+	}
+	entry.AppendChild(list)
+	return entry
+}
+
+func newLdapStubBackend(stub *ldapStub, exclusive bool) IAuthenticationBackend {
+	return NewLdapBackend(LdapConfig{
+		URL:          stub.url(),
+		BindDN:       ldapStubBindDN,
+		BindPassword: ldapStubBindPassword,
+		BaseDN:       "dc=example,dc=com",
+		GroupRoles:   map[string]constants.Role{ldapStubAdminsGroup: constants.Root},
+		Exclusive:    exclusive,
+	})
+}
+
+func addAda(stub *ldapStub, groups ...string) {
+	stub.add("uid=ada,ou=people,dc=example,dc=com", "analytical", map[string][]string{
+		"mail":      {"ada@example.com"},
+		"givenName": {"Ada"},
+		"sn":        {"Lovelace"},
+		"memberOf":  groups,
+	})
+}
+
+func TestLdapBackendAuthenticatesDirectoryUsers(t *testing.T) {
+	stub := newLdapStub(t)
+	addAda(stub, "CN=Admins,OU=Groups,DC=example,DC=com")
+	backend := newLdapStubBackend(stub, false)
+
+	user, err := backend.Authenticate(context.Background(), "ada@example.com", "analytical")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Subject != "uid=ada,ou=people,dc=example,dc=com" || user.FirstName != "Ada" || user.LastName != "Lovelace" {
+		t.Errorf("user = %+v, want the directory entry", user)
+	}
+	if user.Role != constants.Root {
+		t.Errorf("role = %s, want the role mapped from the group", user.Role)
+	}
+
+	// the pooled connection is bound as the service account again and reused
+	if _, err := backend.Authenticate(context.Background(), "ada@example.com", "analytical"); err != nil {
+		t.Fatal(err)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.dials != 1 {
+		t.Errorf("dialed %d times, want the pooled connection reused", stub.dials)
+	}
+}
+
+func TestLdapBackendRejectsWrongAndEmptyPasswords(t *testing.T) {
+	stub := newLdapStub(t)
+	addAda(stub)
+	backend := newLdapStubBackend(stub, false)
+	for _, password := range []string{"wrong", ""} {
+		if _, err := backend.Authenticate(context.Background(), "ada@example.com", password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("password %q: err = %v, want ErrInvalidCredentials", password, err)
+		}
+	}
+}
+
+func TestLdapBackendPassesOnUnknownUsersUnlessExclusive(t *testing.T) {
+	stub := newLdapStub(t)
+	if _, err := newLdapStubBackend(stub, false).Authenticate(context.Background(), "grace@example.com", "x"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("err = %v, want ErrUnknownUser", err)
+	}
+	if _, err := newLdapStubBackend(stub, true).Authenticate(context.Background(), "grace@example.com", "x"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("exclusive: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLdapBackendGivesUpWithTheLoginContext(t *testing.T) {
+	stub := newLdapStub(t)
+	addAda(stub)
+	stub.mu.Lock()
+	stub.hang = true
+	stub.mu.Unlock()
+	backend := newLdapStubBackend(stub, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := backend.Authenticate(ctx, "ada@example.com", "analytical")
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("err = %v, want ErrBackendUnavailable", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("returned after %s, want the 200ms deadline of the login", elapsed)
+	}
+}
+
+func TestLdapBackendReportsUnreachableDirectory(t *testing.T) {
+	stub := newLdapStub(t)
+	stub.close()
+	if _, err := newLdapStubBackend(stub, false).Authenticate(context.Background(), "ada@example.com", "analytical"); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("err = %v, want ErrBackendUnavailable", err)
+	}
+	// an exclusive directory fails the login instead of passing it on
+	_, err := newLdapStubBackend(stub, true).Authenticate(context.Background(), "ada@example.com", "analytical")
+	if err == nil || errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("exclusive: err = %v, want a failure that is not passed on", err)
+	}
+}
+
+func TestBackendLoginPersistsDirectoryRole(t *testing.T) {
+	stub := newLdapStub(t)
+	addAda(stub, ldapStubAdminsGroup)
+	users := newFakeUserRepository()
+	userService := &fakeUserService{users: users}
+	identities := &fakeIdentityRepository{}
+	s := &authenticationService{
+		userRepository:     users,
+		userService:        userService,
+		identityRepository: identities,
+		backends:           []IAuthenticationBackend{newLdapStubBackend(stub, false)},
+	}
+	ctx := context.Background()
+	login := &models.LoginInput{Email: "ada@example.com", Password: "analytical"}
+
+	user, err := s.backendLogin(ctx, login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := users.FindOneById(ctx, user.ID); stored.Role != constants.Root {
+		t.Fatalf("stored role = %s, want root", stored.Role)
+	}
+
+	// leaving the group demotes the stored user on the next login
+	addAda(stub)
+	if _, err := s.backendLogin(ctx, login); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := users.FindOneById(ctx, user.ID); stored.Role != constants.Tenant {
+		t.Errorf("stored role = %s, want tenant after leaving the group", stored.Role)
+	}
+	if len(userService.registered) != 1 || len(users.users) != 1 || len(identities.identities) != 1 {
+		t.Errorf("%d users and %d identities, want the directory user registered and linked once", len(users.users), len(identities.identities))
+	}
+}
+
+func TestBackendLoginFallsBackToLocalUsersWhileDirectoryIsDown(t *testing.T) {
+	stub := newLdapStub(t)
+	stub.close()
+	s := &authenticationService{
+		userRepository:     newFakeUserRepository(),
+		identityRepository: &fakeIdentityRepository{},
+		backends:           []IAuthenticationBackend{newLdapStubBackend(stub, false)},
+	}
+	user, err := s.backendLogin(context.Background(), &models.LoginInput{Email: "local@example.com", Password: "secret"})
+	if err != nil || user != nil {
+		t.Errorf("user = %v, err = %v, want the login passed on to the users collection", user, err)
+	}
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"github.com/mssola/useragent"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+type ILoginHistoryService interface {
+	Record(ctx context.Context, userId primitive.ObjectID, method string, outcome error)
+	GetLogins(ctx context.Context, query *entities.LoginQuery) (*entities.LoginPage, error)
+}
+
+type loginHistoryService struct {
+	ILoginHistoryService
+	repo      repository.ILoginRepository
+	retention time.Duration
+	client    *mongo.Client
+}
+
+// NewLoginHistoryService creates the login history service. Attempts are kept for
+// retention, after which mongo removes them.
+func NewLoginHistoryService(client *mongo.Client, repository repository.ILoginRepository, retention time.Duration) ILoginHistoryService {
+	return &loginHistoryService{
+		repo:      repository,
+		retention: retention,
+		client:    client,
+	}
+}
+
+// Record adds a sign-in attempt of the user, failed when outcome is not nil, to its
+// history. Errors are only logged so that recording never fails a login.
+func (s *loginHistoryService) Record(ctx context.Context, userId primitive.ObjectID, method string, outcome error) {
+	agent := useragent.New(userAgent(ctx))
+	browser, version := agent.Browser()
+	if version != "" {
+		browser = browser + " " + strings.SplitN(version, ".", 2)[0]
+	}
+	device := "desktop"
+	if agent.Bot() {
+		device = "bot"
+	} else if agent.Mobile() {
+		device = "mobile"
+	}
+	now := time.Now()
+	attempt := &entities.LoginAttempt{
+		UserId:    userId,
+		Method:    method,
+		Success:   outcome == nil,
+		IP:        clientIP(ctx),
+		Network:   network(clientIP(ctx)),
+		UserAgent: userAgent(ctx),
+		Browser:   browser,
+		OS:        agent.OSInfo().Name,
+		Device:    device,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.retention),
+	}
+	attempt.Fingerprint = strings.Join([]string{attempt.Browser, attempt.OS, attempt.Device}, "|")
+	if outcome != nil {
+		attempt.Reason = outcome.Error()
+	}
+	known, err := s.repo.HasSucceeded(ctx, userId, "fingerprint", attempt.Fingerprint)
+	if err != nil {
+		utils.Logger.Error("failed to check login device", "error: ", err.Error())
+	}
+	attempt.NewDevice = err == nil && !known
+	known, err = s.repo.HasSucceeded(ctx, userId, "network", attempt.Network)
+	if err != nil {
+		utils.Logger.Error("failed to check login network", "error: ", err.Error())
+	}
+	attempt.NewLocation = err == nil && !known
+	_, err = s.repo.InsertOne(ctx, attempt)
+	if err != nil {
+		utils.Logger.Error("failed to insert login attempt", "error: ", err.Error())
+	}
+}
+
+// GetLogins lists the sign-in activity of the caller, newest first.
+func (s *loginHistoryService) GetLogins(ctx context.Context, query *entities.LoginQuery) (*entities.LoginPage, error) {
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	limit := query.Limit
+	query.Limit = limit + 1
+	attempts, err := s.repo.FindManyByUserId(ctx, ctx.Value("UserId").(primitive.ObjectID), query)
+	if err != nil {
+		utils.Logger.Error("failed to find login attempts", "error: ", err.Error())
+		return nil, err
+	}
+	result := &entities.LoginPage{
+		Logins: attempts,
+	}
+	if len(attempts) > limit {
+		result.Logins = attempts[:limit]
+		result.NextCursor = attempts[limit-1].ID.Hex()
+	}
+	return result, nil
+}
+
+// network returns the /24 or /48 network of an address, which stands in for the
+// location of the caller.
+func network(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%s/24", v4.Mask(net.CIDRMask(24, 32)))
+	}
+	return fmt.Sprintf("%s/48", parsed.Mask(net.CIDRMask(48, 128)))
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/draco121/horizon/utils"
+	"shield/entities"
+)
+
+// INotifier delivers notifications such as organization invitations to users.
+type INotifier interface {
+	Notify(ctx context.Context, notification *entities.Notification) error
+}
+
+type logNotifier struct{}
+
+// NewLogNotifier returns a notifier that only logs notifications. It is meant for
+// development, where no mail server is available.
+func NewLogNotifier() INotifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Notify(ctx context.Context, notification *entities.Notification) error {
+	utils.Logger.Info("notification", "to: ", notification.To, "subject: ", notification.Subject, "body: ", notification.Body)
+	return nil
+}
+
+type SmtpConfig struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+type smtpNotifier struct {
+	config SmtpConfig
+}
+
+// NewSmtpNotifier returns a notifier that sends notifications as plain text emails.
+func NewSmtpNotifier(config SmtpConfig) INotifier {
+	return &smtpNotifier{
+		config: config,
+	}
+}
+
+func (n *smtpNotifier) Notify(ctx context.Context, notification *entities.Notification) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host, _, _ := strings.Cut(n.config.Addr, ":")
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.config.From, notification.To, notification.Subject, notification.Body)
+	err := smtp.SendMail(n.config.Addr, auth, n.config.From, []string{notification.To}, []byte(message))
+	if err != nil {
+		utils.Logger.Error("failed to send notification", "error: ", err.Error())
+		return err
+	}
+	return nil
+}
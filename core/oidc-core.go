@@ -0,0 +1,259 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/oauth2"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+const oidcLoginStateLifetime = 10 * time.Minute
+
+type IOidcService interface {
+	CreateProvider(ctx context.Context, provider *entities.IdentityProvider) (*entities.IdentityProvider, error)
+	GetProviders(ctx context.Context) ([]entities.IdentityProvider, error)
+	DeleteProvider(ctx context.Context, name string) (*entities.IdentityProvider, error)
+	AuthorizationURL(ctx context.Context, name string) (*entities.FederatedRedirect, error)
+	LinkURL(ctx context.Context, name string) (*entities.FederatedRedirect, error)
+	// Callback completes a flow started by AuthorizationURL or LinkURL. binding is the
+	// Binding of the redirect, as returned by the browser.
+	Callback(ctx context.Context, name string, binding string, input *entities.OidcCallbackInput) (*entities.FederatedLoginOutput, error)
+}
+
+type oidcService struct {
+	IOidcService
+	repo                  repository.IOidcRepository
+	identityRepository    repository.IIdentityRepository
+	userRepository        repository.IUserRepository
+	userService           IUserService
+	authenticationService IAuthenticationService
+	client                *mongo.Client
+	mu                    sync.Mutex
+	providers             map[string]*oidc.Provider
+}
+
+func NewOidcService(client *mongo.Client, repository repository.IOidcRepository, identityRepository repository.IIdentityRepository, userRepository repository.IUserRepository, userService IUserService, authenticationService IAuthenticationService) IOidcService {
+	return &oidcService{
+		repo:                  repository,
+		identityRepository:    identityRepository,
+		userRepository:        userRepository,
+		userService:           userService,
+		authenticationService: authenticationService,
+		client:                client,
+		providers:             map[string]*oidc.Provider{},
+	}
+}
+
+func (s *oidcService) CreateProvider(ctx context.Context, provider *entities.IdentityProvider) (*entities.IdentityProvider, error) {
+	// fail early on a wrong issuer rather than on the first login attempt
+	_, err := oidc.NewProvider(ctx, provider.Issuer)
+	if err != nil {
+		utils.Logger.Error("failed to discover identity provider", "error: ", err.Error())
+		return nil, err
+	}
+	provider.CreatedAt = time.Now()
+	provider, err = s.repo.InsertProvider(ctx, provider)
+	if err != nil {
+		utils.Logger.Error("failed to insert identity provider", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created identity provider")
+	provider.ClientSecret = ""
+	return provider, nil
+}
+
+func (s *oidcService) GetProviders(ctx context.Context) ([]entities.IdentityProvider, error) {
+	providers, err := s.repo.FindProviders(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find identity providers", "error: ", err.Error())
+		return nil, err
+	}
+	for i := range providers {
+		providers[i].ClientSecret = ""
+	}
+	return providers, nil
+}
+
+func (s *oidcService) DeleteProvider(ctx context.Context, name string) (*entities.IdentityProvider, error) {
+	provider, err := s.repo.DeleteProviderByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to delete identity provider", "error: ", err.Error())
+		return nil, err
+	}
+	s.mu.Lock()
+	delete(s.providers, provider.Name)
+	s.mu.Unlock()
+	utils.Logger.Info("deleted identity provider")
+	provider.ClientSecret = ""
+	return provider, nil
+}
+
+func (s *oidcService) AuthorizationURL(ctx context.Context, name string) (*entities.FederatedRedirect, error) {
+	return s.authorizationURL(ctx, name, primitive.NilObjectID)
+}
+
+// LinkURL starts a flow that links the provider account to the signed in user
+// instead of logging in.
+func (s *oidcService) LinkURL(ctx context.Context, name string) (*entities.FederatedRedirect, error) {
+	return s.authorizationURL(ctx, name, ctx.Value("UserId").(primitive.ObjectID))
+}
+
+func (s *oidcService) authorizationURL(ctx context.Context, name string, linkUserId primitive.ObjectID) (*entities.FederatedRedirect, error) {
+	provider, err := s.repo.FindProviderByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to find identity provider", "error: ", err.Error())
+		return nil, err
+	}
+	config, _, err := s.oauth2Config(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	state, err := tokens.GenerateSecret("", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := tokens.GenerateSecret("", 16)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := tokens.GenerateSecret("", 16)
+	if err != nil {
+		return nil, err
+	}
+	loginState := entities.OidcLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Provider:     provider.Name,
+		LinkUserId:   linkUserId,
+		BindingHash:  tokens.HashSecret(binding),
+		ExpiresAt:    time.Now().Add(oidcLoginStateLifetime),
+	}
+	_, err = s.repo.InsertState(ctx, &loginState)
+	if err != nil {
+		utils.Logger.Error("failed to insert oidc login state", "error: ", err.Error())
+		return nil, err
+	}
+	return &entities.FederatedRedirect{
+		URL:     config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(loginState.CodeVerifier)),
+		Binding: binding,
+	}, nil
+}
+
+func (s *oidcService) Callback(ctx context.Context, name string, binding string, input *entities.OidcCallbackInput) (*entities.FederatedLoginOutput, error) {
+	loginState, err := s.repo.ConsumeState(ctx, input.State)
+	if err != nil || loginState.Provider != name {
+		utils.Logger.Info("invalid or expired oidc login state")
+		return nil, fmt.Errorf("invalid state")
+	}
+	if !tokens.CheckSecretHash(binding, loginState.BindingHash) {
+		utils.Logger.Info("oidc login state used by another browser")
+		return nil, fmt.Errorf("invalid state")
+	}
+	provider, err := s.repo.FindProviderByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to find identity provider", "error: ", err.Error())
+		return nil, err
+	}
+	config, verifier, err := s.oauth2Config(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	oauth2Token, err := config.Exchange(ctx, input.Code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		utils.Logger.Error("failed to exchange authorization code", "error: ", err.Error())
+		return nil, err
+	}
+	rawIdToken, ok := oauth2Token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("id token missing from token response")
+	}
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		utils.Logger.Error("failed to verify id token", "error: ", err.Error())
+		return nil, err
+	}
+	if idToken.Nonce != loginState.Nonce {
+		utils.Logger.Info("id token nonce mismatch")
+		return nil, fmt.Errorf("invalid nonce")
+	}
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+	}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+	if !loginState.LinkUserId.IsZero() {
+		identity, err := linkIdentity(ctx, s.identityRepository, loginState.LinkUserId, provider.Name, idToken.Subject, claims.Email)
+		if err != nil {
+			return nil, err
+		}
+		return &entities.FederatedLoginOutput{Identity: identity}, nil
+	}
+	user, err := resolveExternalUser(ctx, s.identityRepository, s.userRepository, s.userService, provider.Name, idToken.Subject, claims.Email, claims.EmailVerified, func() *models.User {
+		return &models.User{
+			Email:     claims.Email,
+			FirstName: claims.GivenName,
+			LastName:  claims.FamilyName,
+			Role:      constants.Tenant,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	login, err := s.authenticationService.CreateLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.FederatedLoginOutput{LoginOutput: login}, nil
+}
+
+func (s *oidcService) oauth2Config(ctx context.Context, provider *entities.IdentityProvider) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p, err := s.discover(ctx, provider)
+	if err != nil {
+		utils.Logger.Error("failed to discover identity provider", "error: ", err.Error())
+		return nil, nil, err
+	}
+	scopes := provider.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+	config := oauth2.Config{
+		ClientID:     provider.ClientId,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  provider.RedirectURL,
+		Endpoint:     p.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+	}
+	return &config, p.Verifier(&oidc.Config{ClientID: provider.ClientId}), nil
+}
+
+func (s *oidcService) discover(ctx context.Context, provider *entities.IdentityProvider) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.providers[provider.Name]; ok {
+		return p, nil
+	}
+	// the provider keeps using this context to refresh its key set
+	p, err := oidc.NewProvider(context.WithoutCancel(ctx), provider.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	s.providers[provider.Name] = p
+	return p, nil
+}
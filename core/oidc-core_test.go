@@ -0,0 +1,368 @@
+package core
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// oidcStandIn is an identity provider serving discovery, its key set and a token
+// endpoint that checks the client secret and the PKCE verifier of every code.
+type oidcStandIn struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	mu      sync.Mutex
+	codes   map[string]oidcStandInCode
+	tokens  int
+	idToken func(claims map[string]interface{})
+}
+
+type oidcStandInCode struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+const (
+	oidcStandInClientId     = "shield"
+	oidcStandInClientSecret = "secret"
+)
+
+func newOidcStandIn(t *testing.T) *oidcStandIn {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &oidcStandIn{key: key, codes: map[string]oidcStandInCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "stand-in",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// authorize plays the browser at the authorization endpoint: it checks the request
+// shield redirected to and returns the code and state the provider redirects back with.
+func (idp *oidcStandIn) authorize(t *testing.T, redirect string, claims map[string]interface{}) *entities.OidcCallbackInput {
+	t.Helper()
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("client_id") != oidcStandInClientId || query.Get("response_type") != "code" {
+		t.Fatalf("unexpected authorization request %s", redirect)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without PKCE: %s", redirect)
+	}
+	code := primitive.NewObjectID().Hex()
+	idTokenClaims := map[string]interface{}{
+		"iss":   idp.server.URL,
+		"aud":   oidcStandInClientId,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idTokenClaims[name] = value
+	}
+	idp.mu.Lock()
+	idp.codes[code] = oidcStandInCode{challenge: query.Get("code_challenge"), claims: idTokenClaims}
+	idp.mu.Unlock()
+	return &entities.OidcCallbackInput{Code: code, State: query.Get("state")}
+}
+
+func (idp *oidcStandIn) token(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.tokens++
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	code, found := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	switch {
+	case clientId != oidcStandInClientId || clientSecret != oidcStandInClientSecret:
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	case !found || base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if idp.idToken != nil {
+		idp.idToken(code.claims)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idp.sign(code.claims),
+	})
+}
+
+func (idp *oidcStandIn) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "stand-in", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+type fakeOidcRepository struct {
+	repository.IOidcRepository
+	provider *entities.IdentityProvider
+	states   map[string]*entities.OidcLoginState
+}
+
+func (r *fakeOidcRepository) FindProviderByName(_ context.Context, name string) (*entities.IdentityProvider, error) {
+	if r.provider.Name != name {
+		return nil, mongo.ErrNoDocuments
+	}
+	return r.provider, nil
+}
+
+func (r *fakeOidcRepository) InsertState(_ context.Context, state *entities.OidcLoginState) (primitive.ObjectID, error) {
+	state.ID = primitive.NewObjectID()
+	r.states[state.State] = state
+	return state.ID, nil
+}
+
+func (r *fakeOidcRepository) ConsumeState(_ context.Context, state string) (*entities.OidcLoginState, error) {
+	loginState, ok := r.states[state]
+	delete(r.states, state)
+	if !ok || loginState.ExpiresAt.Before(time.Now()) {
+		return nil, mongo.ErrNoDocuments
+	}
+	return loginState, nil
+}
+
+type oidcFixture struct {
+	idp         *oidcStandIn
+	service     IOidcService
+	users       *fakeUserRepository
+	userService *fakeUserService
+	identities  *fakeIdentityRepository
+	auth        *fakeAuthenticationService
+}
+
+func newOidcFixture(t *testing.T) *oidcFixture {
+	idp := newOidcStandIn(t)
+	f := &oidcFixture{
+		idp:        idp,
+		users:      newFakeUserRepository(),
+		identities: &fakeIdentityRepository{},
+		auth:       &fakeAuthenticationService{},
+	}
+	f.userService = &fakeUserService{users: f.users}
+	repo := &fakeOidcRepository{
+		provider: &entities.IdentityProvider{
+			Name:         "stand-in",
+			Issuer:       idp.server.URL,
+			ClientId:     oidcStandInClientId,
+			ClientSecret: oidcStandInClientSecret,
+			RedirectURL:  "https://shield.example.com/v1/oidc/stand-in/callback",
+		},
+		states: map[string]*entities.OidcLoginState{},
+	}
+	f.service = NewOidcService(nil, repo, f.identities, f.users, f.userService, f.auth)
+	return f
+}
+
+func TestOidcLoginCreatesUserForVerifiedEmail(t *testing.T) {
+	f := newOidcFixture(t)
+	ctx := context.Background()
+	redirect, err := f.service.AuthorizationURL(ctx, "stand-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := f.idp.authorize(t, redirect.URL, map[string]interface{}{
+		"sub":            "subject-1",
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+	})
+	output, err := f.service.Callback(ctx, "stand-in", redirect.Binding, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := f.users.FindOneByEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatal("no user created for the identity")
+	}
+	if user.FirstName != "Ada" {
+		t.Errorf("first name = %q, want Ada", user.FirstName)
+	}
+	if len(f.userService.registered) != 1 {
+		t.Errorf("user created outside of the registration")
+	}
+	identity, err := f.identities.FindOneByProviderSubject(ctx, "stand-in", "subject-1")
+	if err != nil || identity.UserId != user.ID {
+		t.Fatalf("identity not linked to the created user: %v", identity)
+	}
+	if output.LoginOutput == nil || output.Token != "token-"+user.ID.Hex() {
+		t.Errorf("login output = %+v, want a login of the created user", output.LoginOutput)
+	}
+
+	// the state is single use
+	if _, err := f.service.Callback(ctx, "stand-in", redirect.Binding, input); err == nil {
+		t.Error("replayed callback succeeded")
+	}
+}
+
+func TestOidcLoginReusesLinkedIdentity(t *testing.T) {
+	f := newOidcFixture(t)
+	ctx := context.Background()
+	claims := map[string]interface{}{"sub": "subject-1", "email": "ada@example.com", "email_verified": true}
+	var users []primitive.ObjectID
+	for i := 0; i < 2; i++ {
+		redirect, err := f.service.AuthorizationURL(ctx, "stand-in")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.service.Callback(ctx, "stand-in", redirect.Binding, f.idp.authorize(t, redirect.URL, claims)); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, f.auth.logins[len(f.auth.logins)-1])
+	}
+	if users[0] != users[1] || len(f.users.users) != 1 {
+		t.Errorf("second login created another user: %v", users)
+	}
+}
+
+func TestOidcLoginRejectsUnverifiedEmail(t *testing.T) {
+	f := newOidcFixture(t)
+	ctx := context.Background()
+	existing, _ := f.users.InsertOne(ctx, newTestUser("ada@example.com"))
+	redirect, err := f.service.AuthorizationURL(ctx, "stand-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := f.idp.authorize(t, redirect.URL, map[string]interface{}{"sub": "subject-1", "email": "ada@example.com", "email_verified": false})
+	if _, err := f.service.Callback(ctx, "stand-in", redirect.Binding, input); err == nil {
+		t.Fatal("login with an unverified email succeeded")
+	}
+	if _, err := f.identities.FindOneByProviderSubject(ctx, "stand-in", "subject-1"); err == nil {
+		t.Errorf("identity linked to %s without a verified email", existing.ID.Hex())
+	}
+}
+
+func TestOidcCallbackRejectsOtherBrowser(t *testing.T) {
+	f := newOidcFixture(t)
+	ctx := context.Background()
+	redirect, err := f.service.AuthorizationURL(ctx, "stand-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := f.idp.authorize(t, redirect.URL, map[string]interface{}{"sub": "subject-1", "email": "ada@example.com", "email_verified": true})
+	if _, err := f.service.Callback(ctx, "stand-in", "another-binding", input); err == nil {
+		t.Fatal("callback without the binding of the browser succeeded")
+	}
+	if f.idp.tokens != 0 {
+		t.Errorf("code redeemed %d times for a foreign browser", f.idp.tokens)
+	}
+}
+
+func TestOidcCallbackRejectsForeignNonce(t *testing.T) {
+	f := newOidcFixture(t)
+	ctx := context.Background()
+	f.idp.idToken = func(claims map[string]interface{}) {
+		claims["nonce"] = "nonce-of-another-flow"
+	}
+	redirect, err := f.service.AuthorizationURL(ctx, "stand-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := f.idp.authorize(t, redirect.URL, map[string]interface{}{"sub": "subject-1", "email": "ada@example.com", "email_verified": true})
+	if _, err := f.service.Callback(ctx, "stand-in", redirect.Binding, input); err == nil || err.Error() != "invalid nonce" {
+		t.Fatalf("err = %v, want invalid nonce", err)
+	}
+	if len(f.auth.logins) != 0 {
+		t.Error("login created for a token of another flow")
+	}
+}
+
+func TestOidcCallbackRejectsTokenSignedByAnotherKey(t *testing.T) {
+	f := newOidcFixture(t)
+	ctx := context.Background()
+	redirect, err := f.service.AuthorizationURL(ctx, "stand-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := f.idp.authorize(t, redirect.URL, map[string]interface{}{"sub": "subject-1", "email": "ada@example.com", "email_verified": true})
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.idp.key = forged
+	if _, err := f.service.Callback(ctx, "stand-in", redirect.Binding, input); err == nil {
+		t.Fatal("id token signed by an unknown key was accepted")
+	}
+}
+
+func TestOidcLinkAttachesIdentityToSignedInUser(t *testing.T) {
+	f := newOidcFixture(t)
+	user, _ := f.users.InsertOne(context.Background(), newTestUser("ada@example.com"))
+	ctx := context.WithValue(context.Background(), "UserId", user.ID)
+	redirect, err := f.service.LinkURL(ctx, "stand-in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the provider account may use another address than the local account
+	input := f.idp.authorize(t, redirect.URL, map[string]interface{}{"sub": "subject-1", "email": "ada@work.example.com"})
+	output, err := f.service.Callback(context.Background(), "stand-in", redirect.Binding, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Identity == nil || output.Identity.UserId != user.ID {
+		t.Fatalf("identity = %+v, want one linked to %s", output.Identity, user.ID.Hex())
+	}
+	if output.LoginOutput != nil || len(f.auth.logins) != 0 {
+		t.Error("linking an identity logged in")
+	}
+}
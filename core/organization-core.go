@@ -0,0 +1,246 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+type IOrganizationService interface {
+	CreateOrganization(ctx context.Context, organization *entities.Organization) (*entities.Organization, error)
+	GetOrganizations(ctx context.Context) ([]entities.Organization, error)
+	GetMembers(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Membership, error)
+	UpdateMember(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID, input *entities.MembershipInput) (*entities.Membership, error)
+	RemoveMember(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error)
+}
+
+type organizationService struct {
+	IOrganizationService
+	repo   repository.IOrganizationRepository
+	client *mongo.Client
+}
+
+func NewOrganizationService(client *mongo.Client, repository repository.IOrganizationRepository) IOrganizationService {
+	return &organizationService{
+		repo:   repository,
+		client: client,
+	}
+}
+
+// CreateOrganization creates an organization owned by the caller.
+func (s *organizationService) CreateOrganization(ctx context.Context, organization *entities.Organization) (*entities.Organization, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	userId := ctx.Value("UserId").(primitive.ObjectID)
+	organization.CreatedBy = userId
+	organization.CreatedAt = time.Now()
+	result, err := s.repo.InsertOne(sessionCtx, organization)
+	if err != nil {
+		utils.Logger.Error("failed to insert organization", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_, err = s.repo.InsertMembership(sessionCtx, &entities.Membership{
+		OrganizationId: result.ID,
+		UserId:         userId,
+		Role:           entities.OrganizationOwner,
+		CreatedAt:      time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert membership", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("created organization")
+	return result, nil
+}
+
+// GetOrganizations returns the organizations the caller is a member of.
+func (s *organizationService) GetOrganizations(ctx context.Context) ([]entities.Organization, error) {
+	memberships, err := s.repo.FindMembershipsByUserId(ctx, ctx.Value("UserId").(primitive.ObjectID))
+	if err != nil {
+		utils.Logger.Error("failed to find memberships", "error: ", err.Error())
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, membership := range memberships {
+		ids = append(ids, membership.OrganizationId)
+	}
+	result, err := s.repo.FindManyByIds(ctx, ids)
+	if err != nil {
+		utils.Logger.Error("failed to find organizations", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *organizationService) GetMembers(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Membership, error) {
+	if _, err := s.authorize(ctx, organizationId, entities.OrganizationOwner, entities.OrganizationAdmin, entities.OrganizationMember); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindMembershipsByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find memberships", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *organizationService) UpdateMember(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID, input *entities.MembershipInput) (*entities.Membership, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	role, err := s.authorize(sessionCtx, organizationId, entities.OrganizationOwner, entities.OrganizationAdmin)
+	if err != nil {
+		return nil, err
+	}
+	membership, err := s.repo.FindAnyMembership(sessionCtx, organizationId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("membership not found")
+	}
+	if (input.Role == entities.OrganizationOwner || membership.Role == entities.OrganizationOwner) && role != entities.OrganizationOwner {
+		return nil, fmt.Errorf("only owners can change owners")
+	}
+	if membership.Role == entities.OrganizationOwner && !membership.Suspended && input.Role != entities.OrganizationOwner {
+		if err := s.keepOwner(sessionCtx, organizationId); err != nil {
+			return nil, err
+		}
+	}
+	result, err := s.repo.UpdateMembershipRole(sessionCtx, organizationId, userId, input.Role)
+	if err != nil {
+		utils.Logger.Error("failed to update membership", "error: ", err.Error())
+		return nil, err
+	}
+	if err := session.CommitTransaction(ctx); err != nil {
+		utils.Logger.Error("failed to commit mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("updated organization member")
+	return result, nil
+}
+
+// RemoveMember removes a user from the organization. Members may always leave an
+// organization themselves.
+func (s *organizationService) RemoveMember(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	membership, err := s.repo.FindAnyMembership(sessionCtx, organizationId, userId)
+	if err != nil {
+		return nil, fmt.Errorf("membership not found")
+	}
+	if userId != ctx.Value("UserId").(primitive.ObjectID) {
+		role, err := s.authorize(sessionCtx, organizationId, entities.OrganizationOwner, entities.OrganizationAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if membership.Role == entities.OrganizationOwner && role != entities.OrganizationOwner {
+			return nil, fmt.Errorf("only owners can remove owners")
+		}
+	}
+	if membership.Role == entities.OrganizationOwner && !membership.Suspended {
+		if err := s.keepOwner(sessionCtx, organizationId); err != nil {
+			return nil, err
+		}
+	}
+	result, err := s.repo.DeleteMembership(sessionCtx, organizationId, userId)
+	if err != nil {
+		utils.Logger.Error("failed to delete membership", "error: ", err.Error())
+		return nil, err
+	}
+	if err := session.CommitTransaction(ctx); err != nil {
+		utils.Logger.Error("failed to commit mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("removed organization member")
+	return result, nil
+}
+
+// authorize returns the role of the caller in the organization, failing unless it is
+// one of roles.
+func (s *organizationService) authorize(ctx context.Context, organizationId primitive.ObjectID, roles ...string) (string, error) {
+	return authorizeOrganization(ctx, s.repo, organizationId, roles...)
+}
+
+// authorizeOrganization returns the role of the caller in the organization, failing
+// unless it is one of roles. Root users act as owners of every organization.
+func authorizeOrganization(ctx context.Context, repo repository.IOrganizationRepository, organizationId primitive.ObjectID, roles ...string) (string, error) {
+	if claims, ok := ctx.Value("Claims").(*models.JwtCustomClaims); ok && claims.Role == constants.Root {
+		if _, err := repo.FindOneById(ctx, organizationId); err != nil {
+			return "", fmt.Errorf("organization not found")
+		}
+		return entities.OrganizationOwner, nil
+	}
+	membership, err := repo.FindMembership(ctx, organizationId, ctx.Value("UserId").(primitive.ObjectID))
+	if err != nil {
+		return "", fmt.Errorf("organization not found")
+	}
+	for _, role := range roles {
+		if membership.Role == role {
+			return membership.Role, nil
+		}
+	}
+	return "", fmt.Errorf("organization role %s is not allowed to do this", membership.Role)
+}
+
+// keepOwner fails when the organization has a single owner left. Suspended owners
+// cannot act for the organization and do not count. It locks the organization first,
+// so concurrent owner changes conflict instead of each leaving the other owner alone.
+func (s *organizationService) keepOwner(ctx context.Context, organizationId primitive.ObjectID) error {
+	if err := s.repo.LockOne(ctx, organizationId); err != nil {
+		utils.Logger.Error("failed to lock organization", "error: ", err.Error())
+		return err
+	}
+	memberships, err := s.repo.FindMembershipsByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find memberships", "error: ", err.Error())
+		return err
+	}
+	owners := 0
+	for _, membership := range memberships {
+		if membership.Role == entities.OrganizationOwner && !membership.Suspended {
+			owners++
+		}
+	}
+	if owners <= 1 {
+		return fmt.Errorf("an organization needs at least one owner")
+	}
+	return nil
+}
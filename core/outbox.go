@@ -0,0 +1,33 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"shield/entities"
+	"shield/repository"
+)
+
+// recordEvent writes an event about subject to the outbox. Pass the session context of
+// the triggering write so the event is only kept when that write commits.
+func recordEvent(ctx context.Context, outboxRepository repository.IOutboxRepository, eventType string, subject primitive.ObjectID, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		utils.Logger.Error("failed to encode event", "error: ", err.Error())
+		return err
+	}
+	_, err = outboxRepository.InsertOne(ctx, &entities.OutboxEvent{
+		Type:      eventType,
+		Subject:   subject,
+		Data:      payload,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert outbox event", "error: ", err.Error())
+		return err
+	}
+	return nil
+}
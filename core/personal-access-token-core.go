@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+type IPersonalAccessTokenService interface {
+	CreateToken(ctx context.Context, input *entities.PersonalAccessTokenInput) (*entities.PersonalAccessTokenOutput, error)
+	GetTokens(ctx context.Context) ([]entities.PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, id primitive.ObjectID) (*entities.PersonalAccessToken, error)
+}
+
+type personalAccessTokenService struct {
+	IPersonalAccessTokenService
+	repo   repository.IPersonalAccessTokenRepository
+	client *mongo.Client
+}
+
+func NewPersonalAccessTokenService(client *mongo.Client, repository repository.IPersonalAccessTokenRepository) IPersonalAccessTokenService {
+	return &personalAccessTokenService{
+		repo:   repository,
+		client: client,
+	}
+}
+
+// CreateToken issues a token for the caller. Its scopes have to be covered by the
+// permissions of the caller, so a scoped token cannot mint a broader one.
+func (s *personalAccessTokenService) CreateToken(ctx context.Context, input *entities.PersonalAccessTokenInput) (*entities.PersonalAccessTokenOutput, error) {
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+	permissions, _ := ctx.Value("Permissions").([]string)
+	for _, scope := range input.Scopes {
+		if !PermissionGranted(permissions, scope) {
+			return nil, fmt.Errorf("scope %s exceeds the permissions of the caller", scope)
+		}
+	}
+	secret, err := tokens.GenerateSecret(entities.PersonalAccessTokenPrefix, 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate personal access token", "error: ", err.Error())
+		return nil, err
+	}
+	token := entities.PersonalAccessToken{
+		UserId:    ctx.Value("UserId").(primitive.ObjectID),
+		Name:      input.Name,
+		Hint:      secret[len(secret)-4:],
+		TokenHash: tokens.HashSecret(secret),
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	result, err := s.repo.InsertOne(ctx, &token)
+	if err != nil {
+		utils.Logger.Error("failed to insert personal access token", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created personal access token")
+	return &entities.PersonalAccessTokenOutput{
+		PersonalAccessToken: *result,
+		Token:               secret,
+	}, nil
+}
+
+func (s *personalAccessTokenService) GetTokens(ctx context.Context) ([]entities.PersonalAccessToken, error) {
+	result, err := s.repo.FindManyByUserId(ctx, ctx.Value("UserId").(primitive.ObjectID))
+	if err != nil {
+		utils.Logger.Error("failed to find personal access tokens", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("fetched personal access tokens")
+	return result, nil
+}
+
+func (s *personalAccessTokenService) RevokeToken(ctx context.Context, id primitive.ObjectID) (*entities.PersonalAccessToken, error) {
+	result, err := s.repo.DeleteOne(ctx, ctx.Value("UserId").(primitive.ObjectID), id)
+	if err != nil {
+		utils.Logger.Error("failed to delete personal access token", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("revoked personal access token")
+	return result, nil
+}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+type IPolicyService interface {
+	SavePolicy(ctx context.Context, policy *entities.Policy) (*entities.Policy, error)
+	GetPolicies(ctx context.Context) ([]entities.Policy, error)
+	GetPolicyVersions(ctx context.Context, name string) ([]entities.Policy, error)
+	DeletePolicy(ctx context.Context, name string) error
+	Authorize(ctx context.Context, input *entities.AuthorizationInput) (*entities.AuthorizationDecision, error)
+	Evaluate(ctx context.Context, claims *models.JwtCustomClaims, input *entities.AuthorizationInput) (*entities.AuthorizationDecision, error)
+	DryRun(ctx context.Context, input *entities.PolicyDryRunInput) (*entities.AuthorizationDecision, error)
+}
+
+type policyService struct {
+	IPolicyService
+	repo                  repository.IPolicyRepository
+	authenticationService IAuthenticationService
+	roleService           IRoleService
+	client                *mongo.Client
+	env                   *cel.Env
+	mu                    sync.Mutex
+	programs              map[primitive.ObjectID]cel.Program
+}
+
+func NewPolicyService(client *mongo.Client, repository repository.IPolicyRepository, authenticationService IAuthenticationService, roleService IRoleService) IPolicyService {
+	env, err := cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("resource", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("action", cel.StringType),
+		cel.Function("inCidr",
+			cel.Overload("inCidr_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(inCidr))),
+	)
+	if err != nil {
+		utils.Logger.Fatal("failed to create policy environment: ", err)
+	}
+	return &policyService{
+		repo:                  repository,
+		authenticationService: authenticationService,
+		roleService:           roleService,
+		client:                client,
+		env:                   env,
+		programs:              map[primitive.ObjectID]cel.Program{},
+	}
+}
+
+// SavePolicy stores policy as the next version of its name.
+func (s *policyService) SavePolicy(ctx context.Context, policy *entities.Policy) (*entities.Policy, error) {
+	_, err := s.compile(policy.Expression)
+	if err != nil {
+		return nil, err
+	}
+	policy.Version = 1
+	latest, err := s.repo.FindLatestByName(ctx, policy.Name)
+	if err == nil {
+		policy.Version = latest.Version + 1
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		utils.Logger.Error("failed to find policy", "error: ", err.Error())
+		return nil, err
+	}
+	if userId, ok := ctx.Value("UserId").(primitive.ObjectID); ok {
+		policy.CreatedBy = userId
+	}
+	policy.CreatedAt = time.Now()
+	result, err := s.repo.InsertOne(ctx, policy)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("policy %s was changed concurrently", policy.Name)
+	} else if err != nil {
+		utils.Logger.Error("failed to insert policy", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("saved policy version")
+	return result, nil
+}
+
+func (s *policyService) GetPolicies(ctx context.Context) ([]entities.Policy, error) {
+	result, err := s.repo.FindLatest(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find policies", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *policyService) GetPolicyVersions(ctx context.Context, name string) ([]entities.Policy, error) {
+	result, err := s.repo.FindVersionsByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to find policy versions", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *policyService) DeletePolicy(ctx context.Context, name string) error {
+	count, err := s.repo.DeleteManyByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to delete policy", "error: ", err.Error())
+		return err
+	} else if count == 0 {
+		return fmt.Errorf("policy not found")
+	}
+	utils.Logger.Info("deleted policy")
+	return nil
+}
+
+// Authorize authenticates the token in the input and evaluates the request for its
+// holder. The reasons name the policies involved, so they are only returned to holders
+// allowed to manage policies.
+func (s *policyService) Authorize(ctx context.Context, input *entities.AuthorizationInput) (*entities.AuthorizationDecision, error) {
+	principal, err := s.authenticationService.Authenticate(ctx, input.Token)
+	if err != nil {
+		return &entities.AuthorizationDecision{
+			Grant:   constants.Rejected,
+			Reasons: []string{"invalid token"},
+		}, nil
+	}
+	decision, err := s.Evaluate(ctx, &principal.JwtCustomClaims, input)
+	if err != nil {
+		return nil, err
+	}
+	organizationId, err := s.authenticationService.ActiveOrganization(ctx, principal)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := s.roleService.GetPrincipalPermissions(ctx, principal, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to resolve permissions", "error: ", err.Error())
+		return nil, err
+	}
+	if !PermissionGranted(permissions, entities.PermissionPoliciesManage) {
+		decision.Reasons = nil
+	}
+	return decision, nil
+}
+
+func (s *policyService) Evaluate(ctx context.Context, claims *models.JwtCustomClaims, input *entities.AuthorizationInput) (*entities.AuthorizationDecision, error) {
+	policies, err := s.repo.FindLatest(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find policies", "error: ", err.Error())
+		return nil, err
+	}
+	return s.evaluate(ctx, policies, claims, input), nil
+}
+
+// DryRun evaluates the input against the stored policies with the candidate policy
+// replacing the stored version of the same name. Nothing is saved.
+func (s *policyService) DryRun(ctx context.Context, input *entities.PolicyDryRunInput) (*entities.AuthorizationDecision, error) {
+	_, err := s.compile(input.Policy.Expression)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.repo.FindLatest(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find policies", "error: ", err.Error())
+		return nil, err
+	}
+	policies := []entities.Policy{input.Policy}
+	for _, policy := range stored {
+		if policy.Name != input.Policy.Name {
+			policies = append(policies, policy)
+		}
+	}
+	claims := models.JwtCustomClaims{
+		Email: input.Claims["email"],
+		Role:  constants.Role(input.Claims["role"]),
+	}
+	claims.UserId, _ = primitive.ObjectIDFromHex(input.Claims["userId"])
+	claims.SessionId, _ = primitive.ObjectIDFromHex(input.Claims["sessionId"])
+	return s.evaluate(ctx, policies, &claims, &input.Input), nil
+}
+
+// evaluate allows an action when an allow policy matches it and no deny policy does.
+// Every requested action must be allowed. A deny policy that fails to evaluate denies.
+// The ip and user agent of the request default to those of the caller, since services
+// asking on behalf of their own clients pass them in the context.
+func (s *policyService) evaluate(ctx context.Context, policies []entities.Policy, claims *models.JwtCustomClaims, input *entities.AuthorizationInput) *entities.AuthorizationDecision {
+	request := map[string]interface{}{
+		"ip":        clientIP(ctx),
+		"userAgent": userAgent(ctx),
+	}
+	for key, value := range input.Context {
+		request[key] = value
+	}
+	request["time"] = time.Now()
+	resource := input.Resource
+	if resource == nil {
+		resource = map[string]interface{}{}
+	}
+	activation := map[string]interface{}{
+		"claims": map[string]interface{}{
+			"email":     claims.Email,
+			"userId":    claims.UserId.Hex(),
+			"role":      string(claims.Role),
+			"sessionId": claims.SessionId.Hex(),
+		},
+		"request":  request,
+		"resource": resource,
+	}
+
+	decision := entities.AuthorizationDecision{
+		UserId:  claims.UserId,
+		Allowed: len(input.Actions) > 0,
+		Reasons: []string{},
+	}
+	for _, action := range input.Actions {
+		activation["action"] = action
+		allowed, denied := false, false
+		for _, policy := range policies {
+			if policy.Disabled || !PermissionGranted(policy.Actions, action) {
+				continue
+			}
+			label := fmt.Sprintf("%s@v%d", policy.Name, policy.Version)
+			matched, err := s.run(&policy, activation)
+			if err != nil {
+				decision.Reasons = append(decision.Reasons, fmt.Sprintf("%s failed for %s: %s", label, action, err.Error()))
+				denied = denied || policy.Effect == entities.PolicyEffectDeny
+				continue
+			}
+			if !matched {
+				continue
+			}
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("%s: %s %s", label, policy.Effect, action))
+			if policy.Effect == entities.PolicyEffectDeny {
+				denied = true
+			} else {
+				allowed = true
+			}
+		}
+		if !allowed && !denied {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("no policy allows %s", action))
+		}
+		decision.Allowed = decision.Allowed && allowed && !denied
+	}
+	decision.Grant = constants.Rejected
+	if decision.Allowed {
+		decision.Grant = constants.Allowed
+	}
+	return &decision
+}
+
+func (s *policyService) run(policy *entities.Policy, activation map[string]interface{}) (bool, error) {
+	s.mu.Lock()
+	program, ok := s.programs[policy.ID]
+	s.mu.Unlock()
+	if !ok || policy.ID.IsZero() {
+		var err error
+		program, err = s.compile(policy.Expression)
+		if err != nil {
+			return false, err
+		}
+		if !policy.ID.IsZero() {
+			s.mu.Lock()
+			s.programs[policy.ID] = program
+			s.mu.Unlock()
+		}
+	}
+	out, _, err := program.Eval(activation)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression did not evaluate to a bool")
+	}
+	return matched, nil
+}
+
+func (s *policyService) compile(expression string) (cel.Program, error) {
+	ast, issues := s.env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must evaluate to a bool, got %s", ast.OutputType())
+	}
+	return s.env.Program(ast)
+}
+
+// inCidr backs the CEL function inCidr(ip, cidr).
+func inCidr(ip ref.Val, cidr ref.Val) ref.Val {
+	addr, err := netip.ParseAddr(strings.TrimSpace(fmt.Sprint(ip.Value())))
+	if err != nil {
+		return types.False
+	}
+	prefix, err := netip.ParsePrefix(fmt.Sprint(cidr.Value()))
+	if err != nil {
+		return types.NewErr("invalid cidr %s", cidr.Value())
+	}
+	return types.Bool(prefix.Contains(addr.Unmap()))
+}
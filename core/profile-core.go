@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"github.com/go-playground/validator/v10"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// profileValidator checks the binding tags of profiles that were built by merging a
+// patch rather than bound by gin.
+var profileValidator = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}()
+
+type IProfileService interface {
+	GetSchema(ctx context.Context) (*entities.ProfileSchema, error)
+	UpdateSchema(ctx context.Context, schema *entities.ProfileSchema) (*entities.ProfileSchema, error)
+	// Validate checks document, the result of changing original. Unless admin is set,
+	// the admin-only attributes of original must be left as they are.
+	Validate(ctx context.Context, original *entities.ProfileDocument, document *entities.ProfileDocument, admin bool) error
+}
+
+type profileService struct {
+	IProfileService
+	repo     repository.IProfileRepository
+	client   *mongo.Client
+	mu       sync.Mutex
+	compiled map[primitive.ObjectID]*compiledProfileSchema
+}
+
+// compiledProfileSchema caches the compiled version of a schema until it is updated.
+type compiledProfileSchema struct {
+	updatedAt time.Time
+	schema    *jsonschema.Schema
+}
+
+func NewProfileService(client *mongo.Client, repository repository.IProfileRepository) IProfileService {
+	return &profileService{
+		repo:     repository,
+		client:   client,
+		compiled: map[primitive.ObjectID]*compiledProfileSchema{},
+	}
+}
+
+// GetSchema returns the profile schema of the active organization, falling back to
+// the instance schema. Until one is set, every custom attribute is accepted and none
+// is admin-only.
+func (s *profileService) GetSchema(ctx context.Context) (*entities.ProfileSchema, error) {
+	organizationId := activeOrganization(ctx)
+	schema, err := s.repo.FindSchema(ctx, organizationId)
+	if errors.Is(err, mongo.ErrNoDocuments) && !organizationId.IsZero() {
+		schema, err = s.repo.FindSchema(ctx, primitive.NilObjectID)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &entities.ProfileSchema{Schema: json.RawMessage(`{}`), AdminOnly: []string{}}, nil
+	} else if err != nil {
+		utils.Logger.Error("failed to find profile schema", "error: ", err.Error())
+		return nil, err
+	}
+	return schema, nil
+}
+
+// UpdateSchema replaces the profile schema of the active organization. Only callers
+// holding every permission may replace the instance schema.
+func (s *profileService) UpdateSchema(ctx context.Context, schema *entities.ProfileSchema) (*entities.ProfileSchema, error) {
+	schema.OrganizationId = activeOrganization(ctx)
+	if permissions, _ := ctx.Value("Permissions").([]string); schema.OrganizationId.IsZero() && !PermissionGranted(permissions, entities.PermissionAll) {
+		return nil, fmt.Errorf("switch to an organization to change its profile schema")
+	}
+	compiled, err := compileProfileSchema(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err.Error())
+	}
+	if schema.AdminOnly == nil {
+		schema.AdminOnly = []string{}
+	}
+	// stored with the precision of mongo, so the cached version matches the stored one
+	schema.UpdatedAt = time.Now().Truncate(time.Millisecond)
+	result, err := s.repo.UpsertSchema(ctx, schema)
+	if err != nil {
+		utils.Logger.Error("failed to update profile schema", "error: ", err.Error())
+		return nil, err
+	}
+	s.cache(result, compiled)
+	utils.Logger.Info("updated profile schema")
+	return result, nil
+}
+
+func (s *profileService) Validate(ctx context.Context, original *entities.ProfileDocument, document *entities.ProfileDocument, admin bool) error {
+	if err := profileValidator.Struct(&document.Profile); err != nil {
+		return err
+	}
+	schema, err := s.GetSchema(ctx)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		for _, name := range schema.AdminOnly {
+			if !reflect.DeepEqual(original.Attributes[name], document.Attributes[name]) {
+				return fmt.Errorf("attribute %s can only be set by an administrator", name)
+			}
+		}
+	}
+	compiled, err := s.compile(schema)
+	if err != nil {
+		utils.Logger.Error("failed to compile profile schema", "error: ", err.Error())
+		return err
+	}
+	attributes := map[string]interface{}{}
+	for name, value := range document.Attributes {
+		attributes[name] = value
+	}
+	err = compiled.Validate(attributes)
+	if err != nil {
+		return fmt.Errorf("invalid attributes: %s", err.Error())
+	}
+	return nil
+}
+
+// compile returns the compiled schema, compiling it only when it changed since the
+// last time, possibly on another instance.
+func (s *profileService) compile(schema *entities.ProfileSchema) (*jsonschema.Schema, error) {
+	s.mu.Lock()
+	cached, ok := s.compiled[schema.OrganizationId]
+	s.mu.Unlock()
+	if ok && cached.updatedAt.Equal(schema.UpdatedAt) {
+		return cached.schema, nil
+	}
+	compiled, err := compileProfileSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(schema, compiled)
+	return compiled, nil
+}
+
+func (s *profileService) cache(schema *entities.ProfileSchema, compiled *jsonschema.Schema) {
+	s.mu.Lock()
+	s.compiled[schema.OrganizationId] = &compiledProfileSchema{updatedAt: schema.UpdatedAt, schema: compiled}
+	s.mu.Unlock()
+}
+
+func compileProfileSchema(schema *entities.ProfileSchema) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	err := compiler.AddResource("profile.json", bytes.NewReader(schema.Schema))
+	if err != nil {
+		return nil, err
+	}
+	return compiler.Compile("profile.json")
+}
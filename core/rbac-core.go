@@ -0,0 +1,293 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// systemRoles seed the roles backing the legacy constants.Role values.
+var systemRoles = []entities.Role{
+	{
+		Name:        string(constants.Root),
+		Description: "Unrestricted access",
+		Permissions: []string{entities.PermissionAll},
+	},
+	{
+		Name:        string(constants.Tenant),
+		Description: "Self service access to the caller's own account",
+		Permissions: []string{entities.PermissionProfileRead, entities.PermissionProfileWrite},
+	},
+}
+
+type IRoleService interface {
+	EnsureSystemRoles(ctx context.Context) error
+	CreateRole(ctx context.Context, role *entities.Role) (*entities.Role, error)
+	GetRoles(ctx context.Context) ([]entities.Role, error)
+	UpdateRole(ctx context.Context, role *entities.Role) (*entities.Role, error)
+	DeleteRole(ctx context.Context, id primitive.ObjectID) (*entities.Role, error)
+	GetUserRoles(ctx context.Context, userId primitive.ObjectID) ([]entities.Role, error)
+	AssignRole(ctx context.Context, userId primitive.ObjectID, input *entities.RoleAssignmentInput) (*entities.RoleAssignment, error)
+	UnassignRole(ctx context.Context, userId primitive.ObjectID, roleId primitive.ObjectID) (*entities.RoleAssignment, error)
+	GetPermissions(ctx context.Context, claims *models.JwtCustomClaims, organizationId primitive.ObjectID) ([]string, error)
+	GetPrincipalPermissions(ctx context.Context, principal *entities.Principal, organizationId primitive.ObjectID) ([]string, error)
+}
+
+type roleService struct {
+	IRoleService
+	repo            repository.IRoleRepository
+	userRepository  repository.IUserRepository
+	groupRepository repository.IGroupRepository
+	auditService    IAuditService
+	client          *mongo.Client
+}
+
+func NewRoleService(client *mongo.Client, repository repository.IRoleRepository, userRepository repository.IUserRepository, groupRepository repository.IGroupRepository, auditService IAuditService) IRoleService {
+	return &roleService{
+		repo:            repository,
+		userRepository:  userRepository,
+		groupRepository: groupRepository,
+		auditService:    auditService,
+		client:          client,
+	}
+}
+
+func (s *roleService) EnsureSystemRoles(ctx context.Context) error {
+	for _, role := range systemRoles {
+		role.ID = primitive.NewObjectID()
+		role.System = true
+		role.CreatedAt = time.Now()
+		role.UpdatedAt = role.CreatedAt
+		err := s.repo.UpsertSystemRole(ctx, &role)
+		if err != nil {
+			utils.Logger.Error("failed to seed system role", "error: ", err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *roleService) CreateRole(ctx context.Context, role *entities.Role) (*entities.Role, error) {
+	role.System = false
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+	result, err := s.repo.InsertOne(ctx, role)
+	if err != nil {
+		utils.Logger.Error("failed to insert role", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created role")
+	return result, nil
+}
+
+func (s *roleService) GetRoles(ctx context.Context) ([]entities.Role, error) {
+	result, err := s.repo.FindMany(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find roles", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *roleService) UpdateRole(ctx context.Context, role *entities.Role) (*entities.Role, error) {
+	result, err := s.repo.UpdateOne(ctx, role)
+	if err != nil {
+		utils.Logger.Error("failed to update role", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("updated role")
+	return result, nil
+}
+
+func (s *roleService) DeleteRole(ctx context.Context, id primitive.ObjectID) (*entities.Role, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	role, err := s.repo.FindOneById(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to find role", "error: ", err.Error())
+		return nil, err
+	}
+	if role.System {
+		return nil, fmt.Errorf("system roles cannot be deleted")
+	}
+	err = s.repo.DeleteAssignmentsByRoleId(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete role assignments", "error: ", err.Error())
+		return nil, err
+	}
+	err = s.groupRepository.PullRole(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to remove role from groups", "error: ", err.Error())
+		return nil, err
+	}
+	role, err = s.repo.DeleteOneById(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete role", "error: ", err.Error())
+		return nil, err
+	} else {
+		_ = session.CommitTransaction(ctx)
+		utils.Logger.Info("deleted role")
+		return role, nil
+	}
+}
+
+func (s *roleService) GetUserRoles(ctx context.Context, userId primitive.ObjectID) ([]entities.Role, error) {
+	assignments, err := s.repo.FindAssignmentsByUserId(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find role assignments", "error: ", err.Error())
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(assignments))
+	for _, assignment := range assignments {
+		ids = append(ids, assignment.RoleId)
+	}
+	return s.repo.FindManyByIds(ctx, ids)
+}
+
+func (s *roleService) AssignRole(ctx context.Context, userId primitive.ObjectID, input *entities.RoleAssignmentInput) (result *entities.RoleAssignment, err error) {
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:     entities.AuditRoleAssigned,
+			Target:   &userId,
+			Metadata: map[string]string{"roleId": input.RoleId.Hex()},
+		}, err)
+	}()
+	_, err = s.userRepository.FindOneById(ctx, userId)
+	if err != nil {
+		utils.Logger.Error("failed to find user", "error: ", err.Error())
+		return nil, err
+	}
+	_, err = s.repo.FindOneById(ctx, input.RoleId)
+	if err != nil {
+		utils.Logger.Error("failed to find role", "error: ", err.Error())
+		return nil, err
+	}
+	result, err = s.repo.InsertAssignment(ctx, &entities.RoleAssignment{
+		UserId:    userId,
+		RoleId:    input.RoleId,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to assign role", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("assigned role")
+	return result, nil
+}
+
+func (s *roleService) UnassignRole(ctx context.Context, userId primitive.ObjectID, roleId primitive.ObjectID) (result *entities.RoleAssignment, err error) {
+	defer func() {
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:     entities.AuditRoleUnassigned,
+			Target:   &userId,
+			Metadata: map[string]string{"roleId": roleId.Hex()},
+		}, err)
+	}()
+	result, err = s.repo.DeleteAssignment(ctx, userId, roleId)
+	if err != nil {
+		utils.Logger.Error("failed to unassign role", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("unassigned role")
+	return result, nil
+}
+
+// GetPermissions resolves the permissions of the token holder: those of the system
+// role matching the legacy role claim, those of every assigned role and those of the
+// roles of every group the holder effectively belongs to in the organization.
+func (s *roleService) GetPermissions(ctx context.Context, claims *models.JwtCustomClaims, organizationId primitive.ObjectID) ([]string, error) {
+	var permissions []string
+	legacy, err := s.repo.FindOneByName(ctx, string(claims.Role))
+	if err == nil && legacy.System {
+		permissions = append(permissions, legacy.Permissions...)
+	}
+	roles, err := s.GetUserRoles(ctx, claims.UserId)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := effectiveGroups(ctx, s.groupRepository, organizationId, claims.UserId)
+	if err != nil {
+		return nil, err
+	}
+	var roleIds []primitive.ObjectID
+	for _, group := range groups {
+		roleIds = append(roleIds, group.RoleIds...)
+	}
+	if len(roleIds) > 0 {
+		groupRoles, err := s.repo.FindManyByIds(ctx, roleIds)
+		if err != nil {
+			utils.Logger.Error("failed to find group roles", "error: ", err.Error())
+			return nil, err
+		}
+		roles = append(roles, groupRoles...)
+	}
+	for _, role := range roles {
+		permissions = append(permissions, role.Permissions...)
+	}
+	return permissions, nil
+}
+
+// GetPrincipalPermissions resolves the permissions a principal holds in the
+// organization. Callers bound to an organization are not users and hold exactly their
+// scopes, everyone else holds the permissions of their user covered by their scopes.
+func (s *roleService) GetPrincipalPermissions(ctx context.Context, principal *entities.Principal, organizationId primitive.ObjectID) ([]string, error) {
+	if !principal.OrganizationId.IsZero() {
+		return principal.Scopes, nil
+	}
+	permissions, err := s.GetPermissions(ctx, &principal.JwtCustomClaims, organizationId)
+	if err != nil {
+		return nil, err
+	}
+	return ScopePermissions(permissions, principal.Scopes), nil
+}
+
+// PermissionGranted reports whether permission is covered by granted, where "*"
+// grants everything and "users:*" grants every users permission.
+func PermissionGranted(granted []string, permission string) bool {
+	for _, g := range granted {
+		if g == entities.PermissionAll || g == permission {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasPrefix(permission, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopePermissions limits granted to the permissions also covered by scopes. A nil
+// scopes leaves granted as it is, an empty one grants nothing.
+func ScopePermissions(granted []string, scopes []string) []string {
+	if scopes == nil {
+		return granted
+	}
+	limited := []string{}
+	for _, g := range granted {
+		for _, scope := range scopes {
+			if PermissionGranted([]string{g}, scope) {
+				limited = append(limited, scope)
+			} else if PermissionGranted([]string{scope}, g) {
+				limited = append(limited, g)
+			}
+		}
+	}
+	return limited
+}
@@ -0,0 +1,312 @@
+package core
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	dsig "github.com/russellhaering/goxmldsig"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+const samlLoginStateLifetime = 10 * time.Minute
+
+type ISamlService interface {
+	CreateConnection(ctx context.Context, connection *entities.SamlConnection) (*entities.SamlConnection, error)
+	GetConnections(ctx context.Context) ([]entities.SamlConnection, error)
+	DeleteConnection(ctx context.Context, name string) (*entities.SamlConnection, error)
+	Metadata(ctx context.Context, name string) ([]byte, error)
+	LoginURL(ctx context.Context, name string) (*entities.FederatedRedirect, error)
+	LinkURL(ctx context.Context, name string) (*entities.FederatedRedirect, error)
+	// ConsumeAssertion completes a flow started by LoginURL or LinkURL. binding is the
+	// Binding of the redirect, as returned by the browser.
+	ConsumeAssertion(ctx context.Context, name string, binding string, req *http.Request) (*entities.FederatedLoginOutput, error)
+}
+
+type samlService struct {
+	ISamlService
+	repo                  repository.ISamlRepository
+	identityRepository    repository.IIdentityRepository
+	userRepository        repository.IUserRepository
+	userService           IUserService
+	authenticationService IAuthenticationService
+	client                *mongo.Client
+	baseURL               string
+	key                   *rsa.PrivateKey
+	certificate           *x509.Certificate
+}
+
+func NewSamlService(client *mongo.Client, repository repository.ISamlRepository, identityRepository repository.IIdentityRepository, userRepository repository.IUserRepository, userService IUserService, authenticationService IAuthenticationService, baseURL string, key *rsa.PrivateKey, certificate *x509.Certificate) ISamlService {
+	return &samlService{
+		repo:                  repository,
+		identityRepository:    identityRepository,
+		userRepository:        userRepository,
+		userService:           userService,
+		authenticationService: authenticationService,
+		client:                client,
+		baseURL:               baseURL,
+		key:                   key,
+		certificate:           certificate,
+	}
+}
+
+func (s *samlService) CreateConnection(ctx context.Context, connection *entities.SamlConnection) (*entities.SamlConnection, error) {
+	_, err := parseIdpMetadata(connection.IdpMetadata)
+	if err != nil {
+		utils.Logger.Error("failed to parse idp metadata", "error: ", err.Error())
+		return nil, err
+	}
+	connection.CreatedAt = time.Now()
+	connection, err = s.repo.InsertConnection(ctx, connection)
+	if err != nil {
+		utils.Logger.Error("failed to insert saml connection", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created saml connection")
+	return connection, nil
+}
+
+func (s *samlService) GetConnections(ctx context.Context) ([]entities.SamlConnection, error) {
+	connections, err := s.repo.FindConnections(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find saml connections", "error: ", err.Error())
+		return nil, err
+	}
+	return connections, nil
+}
+
+func (s *samlService) DeleteConnection(ctx context.Context, name string) (*entities.SamlConnection, error) {
+	connection, err := s.repo.DeleteConnectionByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to delete saml connection", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("deleted saml connection")
+	return connection, nil
+}
+
+func (s *samlService) Metadata(ctx context.Context, name string) ([]byte, error) {
+	sp, _, err := s.serviceProvider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return xml.MarshalIndent(sp.Metadata(), "", "  ")
+}
+
+func (s *samlService) LoginURL(ctx context.Context, name string) (*entities.FederatedRedirect, error) {
+	return s.loginURL(ctx, name, primitive.NilObjectID)
+}
+
+// LinkURL starts a flow that links the IdP account to the signed in user instead
+// of logging in.
+func (s *samlService) LinkURL(ctx context.Context, name string) (*entities.FederatedRedirect, error) {
+	return s.loginURL(ctx, name, ctx.Value("UserId").(primitive.ObjectID))
+}
+
+func (s *samlService) loginURL(ctx context.Context, name string, linkUserId primitive.ObjectID) (*entities.FederatedRedirect, error) {
+	sp, _, err := s.serviceProvider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	req, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		utils.Logger.Error("failed to make authentication request", "error: ", err.Error())
+		return nil, err
+	}
+	relayState, err := tokens.GenerateSecret("", 16)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := tokens.GenerateSecret("", 16)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.repo.InsertState(ctx, &entities.SamlLoginState{
+		State:       relayState,
+		RequestId:   req.ID,
+		Connection:  name,
+		LinkUserId:  linkUserId,
+		BindingHash: tokens.HashSecret(binding),
+		ExpiresAt:   time.Now().Add(samlLoginStateLifetime),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert saml login state", "error: ", err.Error())
+		return nil, err
+	}
+	redirect, err := req.Redirect(relayState, sp)
+	if err != nil {
+		utils.Logger.Error("failed to sign authentication request", "error: ", err.Error())
+		return nil, err
+	}
+	return &entities.FederatedRedirect{
+		URL:     redirect.String(),
+		Binding: binding,
+	}, nil
+}
+
+func (s *samlService) ConsumeAssertion(ctx context.Context, name string, binding string, req *http.Request) (*entities.FederatedLoginOutput, error) {
+	err := req.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+	loginState, err := s.repo.ConsumeState(ctx, req.PostForm.Get("RelayState"))
+	if err != nil || loginState.Connection != name {
+		utils.Logger.Info("invalid or expired saml relay state")
+		return nil, fmt.Errorf("invalid relay state")
+	}
+	if !tokens.CheckSecretHash(binding, loginState.BindingHash) {
+		utils.Logger.Info("saml relay state used by another browser")
+		return nil, fmt.Errorf("invalid relay state")
+	}
+	sp, connection, err := s.serviceProvider(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := sp.ParseResponse(req, []string{loginState.RequestId})
+	if err != nil {
+		if invalid, ok := err.(*saml.InvalidResponseError); ok {
+			utils.Logger.Error("invalid saml response", "error: ", invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("invalid saml response")
+	}
+	err = s.repo.InsertAssertion(ctx, &entities.SamlAssertion{
+		ID:         assertion.ID,
+		Connection: name,
+		ExpiresAt:  assertion.Conditions.NotOnOrAfter,
+	})
+	if err != nil {
+		utils.Logger.Error("failed to record saml assertion", "error: ", err.Error())
+		return nil, err
+	}
+
+	mapping := connection.AttributeMapping
+	email := samlAttribute(assertion, mapping.Email)
+	if email == "" && assertion.Subject != nil && assertion.Subject.NameID != nil {
+		email = assertion.Subject.NameID.Value
+	}
+	role := connection.DefaultRole
+	if role == "" {
+		role = constants.Tenant
+	}
+	if mapped, ok := connection.RoleMapping[samlAttribute(assertion, mapping.Role)]; ok {
+		role = mapped
+	}
+	subject := email
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		subject = assertion.Subject.NameID.Value
+	}
+	if !loginState.LinkUserId.IsZero() {
+		identity, err := linkIdentity(ctx, s.identityRepository, loginState.LinkUserId, "saml:"+name, subject, email)
+		if err != nil {
+			return nil, err
+		}
+		return &entities.FederatedLoginOutput{Identity: identity}, nil
+	}
+	// the connection's IdP is only trusted to assert emails in its verified domains
+	verified := emailInDomains(email, connection.VerifiedDomains)
+	user, err := resolveExternalUser(ctx, s.identityRepository, s.userRepository, s.userService, "saml:"+name, subject, email, verified, func() *models.User {
+		return &models.User{
+			Email:     email,
+			FirstName: samlAttribute(assertion, mapping.FirstName),
+			LastName:  samlAttribute(assertion, mapping.LastName),
+			Role:      role,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	login, err := s.authenticationService.CreateLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.FederatedLoginOutput{LoginOutput: login}, nil
+}
+
+func (s *samlService) serviceProvider(ctx context.Context, name string) (*saml.ServiceProvider, *entities.SamlConnection, error) {
+	if s.key == nil || s.certificate == nil {
+		return nil, nil, fmt.Errorf("saml is not configured")
+	}
+	connection, err := s.repo.FindConnectionByName(ctx, name)
+	if err != nil {
+		utils.Logger.Error("failed to find saml connection", "error: ", err.Error())
+		return nil, nil, err
+	}
+	metadata, err := parseIdpMetadata(connection.IdpMetadata)
+	if err != nil {
+		utils.Logger.Error("failed to parse idp metadata", "error: ", err.Error())
+		return nil, nil, err
+	}
+	metadataURL, err := url.Parse(fmt.Sprintf("%s/v1/saml/%s/metadata", s.baseURL, url.PathEscape(name)))
+	if err != nil {
+		return nil, nil, err
+	}
+	acsURL, err := url.Parse(fmt.Sprintf("%s/v1/saml/%s/acs", s.baseURL, url.PathEscape(name)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &saml.ServiceProvider{
+		EntityID:          metadataURL.String(),
+		Key:               s.key,
+		Certificate:       s.certificate,
+		MetadataURL:       *metadataURL,
+		AcsURL:            *acsURL,
+		IDPMetadata:       metadata,
+		AuthnNameIDFormat: saml.EmailAddressNameIDFormat,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+	}, connection, nil
+}
+
+func parseIdpMetadata(metadata string) (*saml.EntityDescriptor, error) {
+	descriptor := saml.EntityDescriptor{}
+	err := xml.Unmarshal([]byte(metadata), &descriptor)
+	if err != nil {
+		return nil, err
+	}
+	if len(descriptor.IDPSSODescriptors) == 0 {
+		return nil, fmt.Errorf("metadata has no IDPSSODescriptor")
+	}
+	return &descriptor, nil
+}
+
+// emailInDomains reports whether the domain of email is one of domains.
+func emailInDomains(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	for _, domain := range domains {
+		if strings.EqualFold(email[at+1:], domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// samlAttribute returns the first value of the attribute with the given name or friendly name.
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	if name == "" {
+		return ""
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if (attribute.Name == name || attribute.FriendlyName == name) && len(attribute.Values) > 0 {
+				return attribute.Values[0].Value
+			}
+		}
+	}
+	return ""
+}
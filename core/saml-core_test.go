@@ -0,0 +1,299 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/draco121/horizon/constants"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+)
+
+// newTestKeyPair generates a key and a self-signed certificate for it.
+func newTestKeyPair(t *testing.T, name string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, certificate
+}
+
+type fakeSamlRepository struct {
+	repository.ISamlRepository
+	connection *entities.SamlConnection
+	states     map[string]*entities.SamlLoginState
+	assertions map[string]bool
+}
+
+func (r *fakeSamlRepository) FindConnectionByName(_ context.Context, name string) (*entities.SamlConnection, error) {
+	if r.connection.Name != name {
+		return nil, mongo.ErrNoDocuments
+	}
+	return r.connection, nil
+}
+
+func (r *fakeSamlRepository) InsertState(_ context.Context, state *entities.SamlLoginState) (primitive.ObjectID, error) {
+	state.ID = primitive.NewObjectID()
+	r.states[state.State] = state
+	return state.ID, nil
+}
+
+func (r *fakeSamlRepository) ConsumeState(_ context.Context, state string) (*entities.SamlLoginState, error) {
+	loginState, ok := r.states[state]
+	delete(r.states, state)
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+	return loginState, nil
+}
+
+func (r *fakeSamlRepository) InsertAssertion(_ context.Context, assertion *entities.SamlAssertion) error {
+	if r.assertions[assertion.ID] {
+		return fmt.Errorf("assertion %s already consumed", assertion.ID)
+	}
+	r.assertions[assertion.ID] = true
+	return nil
+}
+
+// samlServiceProviders answers the identity provider's lookups with the metadata
+// shield serves for the connection.
+type samlServiceProviders struct {
+	service ISamlService
+}
+
+func (p samlServiceProviders) GetServiceProvider(r *http.Request, _ string) (*saml.EntityDescriptor, error) {
+	metadata, err := p.service.Metadata(r.Context(), "corp")
+	if err != nil {
+		return nil, err
+	}
+	descriptor := saml.EntityDescriptor{}
+	return &descriptor, xml.Unmarshal(metadata, &descriptor)
+}
+
+type samlFixture struct {
+	idp         *saml.IdentityProvider
+	service     ISamlService
+	repo        *fakeSamlRepository
+	users       *fakeUserRepository
+	userService *fakeUserService
+	identities  *fakeIdentityRepository
+	auth        *fakeAuthenticationService
+}
+
+func newSamlFixture(t *testing.T) *samlFixture {
+	idpKey, idpCertificate := newTestKeyPair(t, "idp.example.com")
+	spKey, spCertificate := newTestKeyPair(t, "shield.example.com")
+	f := &samlFixture{
+		users:      newFakeUserRepository(),
+		identities: &fakeIdentityRepository{},
+		auth:       &fakeAuthenticationService{},
+	}
+	f.userService = &fakeUserService{users: f.users}
+	f.idp = &saml.IdentityProvider{
+		Key:         idpKey,
+		Certificate: idpCertificate,
+		MetadataURL: url.URL{Scheme: "https", Host: "idp.example.com", Path: "/metadata"},
+		SSOURL:      url.URL{Scheme: "https", Host: "idp.example.com", Path: "/sso"},
+	}
+	idpMetadata, err := xml.Marshal(f.idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.repo = &fakeSamlRepository{
+		connection: &entities.SamlConnection{
+			Name:             "corp",
+			IdpMetadata:      string(idpMetadata),
+			AttributeMapping: entities.SamlAttributeMapping{FirstName: "givenName", Role: "role"},
+			RoleMapping:      map[string]constants.Role{"admins": constants.Root},
+			VerifiedDomains:  []string{"corp.example.com"},
+		},
+		states:     map[string]*entities.SamlLoginState{},
+		assertions: map[string]bool{},
+	}
+	f.service = NewSamlService(nil, f.repo, f.identities, f.users, f.userService, f.auth, "https://shield.example.com", spKey, spCertificate)
+	f.idp.ServiceProviderProvider = samlServiceProviders{service: f.service}
+	return f
+}
+
+// signIn plays the browser: it takes the redirect to the identity provider, has the
+// identity provider answer it for session and returns the form posted back to shield.
+func (f *samlFixture) signIn(t *testing.T, idp *saml.IdentityProvider, redirect string, session *saml.Session) *http.Request {
+	t.Helper()
+	authnRequest, err := saml.NewIdpAuthnRequest(idp, httptest.NewRequest(http.MethodGet, redirect, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := authnRequest.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(authnRequest, session); err != nil {
+		t.Fatal(err)
+	}
+	form, err := authnRequest.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := url.Values{"SAMLResponse": {form.SAMLResponse}, "RelayState": {form.RelayState}}
+	req := httptest.NewRequest(http.MethodPost, form.URL, strings.NewReader(body.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func samlSession(email string, attributes ...saml.Attribute) *saml.Session {
+	return &saml.Session{
+		ID:               primitive.NewObjectID().Hex(),
+		NameID:           email,
+		NameIDFormat:     string(saml.EmailAddressNameIDFormat),
+		UserGivenName:    "Ada",
+		CustomAttributes: attributes,
+	}
+}
+
+func TestSamlLoginCreatesUserInVerifiedDomain(t *testing.T) {
+	f := newSamlFixture(t)
+	ctx := context.Background()
+	redirect, err := f.service.LoginURL(ctx, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := saml.Attribute{Name: "role", Values: []saml.AttributeValue{{Type: "xs:string", Value: "admins"}}}
+	req := f.signIn(t, f.idp, redirect.URL, samlSession("ada@corp.example.com", role))
+	output, err := f.service.ConsumeAssertion(ctx, "corp", redirect.Binding, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := f.users.FindOneByEmail(ctx, "ada@corp.example.com")
+	if err != nil {
+		t.Fatal("no user created for the assertion")
+	}
+	if user.Role != constants.Root || user.FirstName != "Ada" {
+		t.Errorf("user = %+v, want the mapped role and name", user)
+	}
+	if len(f.userService.registered) != 1 {
+		t.Errorf("user created outside of the registration")
+	}
+	if output.LoginOutput == nil || output.Token != "token-"+user.ID.Hex() {
+		t.Errorf("login output = %+v, want a login of the created user", output.LoginOutput)
+	}
+}
+
+func TestSamlLoginDoesNotTakeOverAccountsOutsideVerifiedDomains(t *testing.T) {
+	f := newSamlFixture(t)
+	ctx := context.Background()
+	victim, _ := f.users.InsertOne(ctx, newTestUser("ada@gmail.example.com"))
+	redirect, err := f.service.LoginURL(ctx, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := f.signIn(t, f.idp, redirect.URL, samlSession("ada@gmail.example.com"))
+	if _, err := f.service.ConsumeAssertion(ctx, "corp", redirect.Binding, req); err == nil {
+		t.Fatal("assertion for an email outside the verified domains logged in")
+	}
+	if _, err := f.identities.FindOneByProviderSubject(ctx, "saml:corp", "ada@gmail.example.com"); err == nil {
+		t.Errorf("identity linked to %s", victim.ID.Hex())
+	}
+	if len(f.auth.logins) != 0 {
+		t.Error("login created for a foreign account")
+	}
+}
+
+func TestSamlLoginRejectsAssertionSignedByAnotherKey(t *testing.T) {
+	f := newSamlFixture(t)
+	ctx := context.Background()
+	redirect, err := f.service.LoginURL(ctx, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedKey, forgedCertificate := newTestKeyPair(t, "idp.example.com")
+	forged := *f.idp
+	forged.Key, forged.Certificate = forgedKey, forgedCertificate
+	req := f.signIn(t, &forged, redirect.URL, samlSession("ada@corp.example.com"))
+	if _, err := f.service.ConsumeAssertion(ctx, "corp", redirect.Binding, req); err == nil || err.Error() != "invalid saml response" {
+		t.Fatalf("err = %v, want invalid saml response", err)
+	}
+	if len(f.users.users) != 0 {
+		t.Error("user created from a forged assertion")
+	}
+}
+
+func TestSamlLoginRejectsReplayAndOtherBrowsers(t *testing.T) {
+	f := newSamlFixture(t)
+	ctx := context.Background()
+	redirect, err := f.service.LoginURL(ctx, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := f.signIn(t, f.idp, redirect.URL, samlSession("ada@corp.example.com"))
+	if err := req.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	form := req.PostForm.Encode()
+	post := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, req.URL.String(), strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	if _, err := f.service.ConsumeAssertion(ctx, "corp", "another-binding", post()); err == nil {
+		t.Fatal("assertion posted by another browser was accepted")
+	}
+	// the relay state was spent by the rejected attempt
+	if _, err := f.service.ConsumeAssertion(ctx, "corp", redirect.Binding, post()); err == nil {
+		t.Fatal("assertion accepted after its relay state was used")
+	}
+	if len(f.auth.logins) != 0 {
+		t.Error("login created for a rejected assertion")
+	}
+}
+
+func TestSamlLinkAttachesIdentityOutsideVerifiedDomains(t *testing.T) {
+	f := newSamlFixture(t)
+	user, _ := f.users.InsertOne(context.Background(), newTestUser("ada@gmail.example.com"))
+	ctx := context.WithValue(context.Background(), "UserId", user.ID)
+	redirect, err := f.service.LinkURL(ctx, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := f.signIn(t, f.idp, redirect.URL, samlSession("ada@partner.example.com"))
+	output, err := f.service.ConsumeAssertion(context.Background(), "corp", redirect.Binding, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Identity == nil || output.Identity.UserId != user.ID {
+		t.Fatalf("identity = %+v, want one linked to %s", output.Identity, user.ID.Hex())
+	}
+	if len(f.auth.logins) != 0 {
+		t.Error("linking an identity logged in")
+	}
+}
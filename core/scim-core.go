@@ -0,0 +1,1040 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/draco121/horizon/models"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 1000
+)
+
+// ScimError is an error reported in the SCIM error format (RFC 7644 section 3.12).
+type ScimError struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *ScimError) Error() string {
+	return e.Detail
+}
+
+func newScimError(status int, scimType string, detail string) *ScimError {
+	return &ScimError{
+		Status:   status,
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+var errScimNotFound = newScimError(http.StatusNotFound, "", "resource not found")
+
+type IScimService interface {
+	CreateToken(ctx context.Context, organizationId primitive.ObjectID, input *entities.ScimTokenInput) (*entities.ScimTokenOutput, error)
+	GetTokens(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ScimToken, error)
+	DeleteToken(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.ScimToken, error)
+	AuthenticateToken(ctx context.Context, token string) (primitive.ObjectID, error)
+	GetUsers(ctx context.Context, query *entities.ScimListQuery) (*entities.ScimListResponse, error)
+	GetUser(ctx context.Context, id string) (*entities.ScimUser, error)
+	CreateUser(ctx context.Context, user *entities.ScimUser) (*entities.ScimUser, error)
+	ReplaceUser(ctx context.Context, id string, user *entities.ScimUser, ifMatch string) (*entities.ScimUser, error)
+	PatchUser(ctx context.Context, id string, patch *entities.ScimPatchRequest, ifMatch string) (*entities.ScimUser, error)
+	DeleteUser(ctx context.Context, id string, ifMatch string) error
+	GetGroups(ctx context.Context, query *entities.ScimListQuery) (*entities.ScimListResponse, error)
+	GetGroup(ctx context.Context, id string) (*entities.ScimGroup, error)
+	CreateGroup(ctx context.Context, group *entities.ScimGroup) (*entities.ScimGroup, error)
+	ReplaceGroup(ctx context.Context, id string, group *entities.ScimGroup, ifMatch string) (*entities.ScimGroup, error)
+	PatchGroup(ctx context.Context, id string, patch *entities.ScimPatchRequest, ifMatch string) (*entities.ScimGroup, error)
+	DeleteGroup(ctx context.Context, id string, ifMatch string) error
+}
+
+type scimService struct {
+	IScimService
+	repo                   repository.IScimRepository
+	userRepository         repository.IUserRepository
+	organizationRepository repository.IOrganizationRepository
+	groupRepository        repository.IGroupRepository
+	patRepository          repository.IPersonalAccessTokenRepository
+	userService            IUserService
+	groupService           IGroupService
+	authenticationService  IAuthenticationService
+	baseURL                string
+	client                 *mongo.Client
+}
+
+// NewScimService returns the SCIM service. SCIM users are the members of the calling
+// organization and SCIM groups are its groups; baseURL prefixes resource locations.
+func NewScimService(client *mongo.Client, repository repository.IScimRepository, userRepository repository.IUserRepository, organizationRepository repository.IOrganizationRepository, groupRepository repository.IGroupRepository, patRepository repository.IPersonalAccessTokenRepository, userService IUserService, groupService IGroupService, authenticationService IAuthenticationService, baseURL string) IScimService {
+	return &scimService{
+		repo:                   repository,
+		userRepository:         userRepository,
+		organizationRepository: organizationRepository,
+		groupRepository:        groupRepository,
+		patRepository:          patRepository,
+		userService:            userService,
+		groupService:           groupService,
+		authenticationService:  authenticationService,
+		baseURL:                strings.TrimSuffix(baseURL, "/"),
+		client:                 client,
+	}
+}
+
+func (s *scimService) CreateToken(ctx context.Context, organizationId primitive.ObjectID, input *entities.ScimTokenInput) (*entities.ScimTokenOutput, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	if _, err := s.organizationRepository.FindOneById(ctx, organizationId); err != nil {
+		return nil, fmt.Errorf("organization not found")
+	}
+	token, err := tokens.GenerateSecret(entities.ScimTokenPrefix, 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate scim token", "error: ", err.Error())
+		return nil, err
+	}
+	result, err := s.repo.InsertToken(ctx, &entities.ScimToken{
+		OrganizationId: organizationId,
+		Name:           input.Name,
+		Hint:           token[len(token)-4:],
+		TokenHash:      tokens.HashSecret(token),
+		CreatedAt:      time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert scim token", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created scim token")
+	return &entities.ScimTokenOutput{
+		ScimToken: *result,
+		Token:     token,
+	}, nil
+}
+
+func (s *scimService) GetTokens(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ScimToken, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.FindTokensByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find scim tokens", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *scimService) DeleteToken(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.ScimToken, error) {
+	if err := requireActiveOrganization(ctx, organizationId); err != nil {
+		return nil, err
+	}
+	result, err := s.repo.DeleteToken(ctx, organizationId, id)
+	if err != nil {
+		return nil, fmt.Errorf("scim token not found")
+	}
+	utils.Logger.Info("deleted scim token")
+	return result, nil
+}
+
+// AuthenticateToken returns the organization a SCIM bearer token belongs to.
+func (s *scimService) AuthenticateToken(ctx context.Context, token string) (primitive.ObjectID, error) {
+	if !strings.HasPrefix(token, entities.ScimTokenPrefix) {
+		return primitive.NilObjectID, fmt.Errorf("invalid token")
+	}
+	result, err := s.repo.FindTokenByHash(ctx, tokens.HashSecret(token))
+	if err != nil {
+		utils.Logger.Info("unknown scim token")
+		return primitive.NilObjectID, fmt.Errorf("invalid token")
+	}
+	if err := s.repo.UpdateTokenLastUsed(ctx, result.ID, time.Now()); err != nil {
+		utils.Logger.Error("failed to update scim token", "error: ", err.Error())
+	}
+	return result.OrganizationId, nil
+}
+
+func (s *scimService) GetUsers(ctx context.Context, query *entities.ScimListQuery) (*entities.ScimListResponse, error) {
+	organizationId := activeOrganization(ctx)
+	memberships, err := s.organizationRepository.FindMembershipsByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find memberships", "error: ", err.Error())
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, membership := range memberships {
+		ids = append(ids, membership.UserId)
+	}
+	users, err := s.userRepository.FindManyByIds(ctx, ids)
+	if err != nil {
+		utils.Logger.Error("failed to find users", "error: ", err.Error())
+		return nil, err
+	}
+	links, err := s.repo.FindUserLinksByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find scim users", "error: ", err.Error())
+		return nil, err
+	}
+	linksByUser := map[primitive.ObjectID]*entities.ScimUserLink{}
+	for i := range links {
+		linksByUser[links[i].UserId] = &links[i]
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID.Hex() < users[j].ID.Hex()
+	})
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, s.toScimUser(&users[i], linksByUser[users[i].ID]))
+	}
+	return s.list(resources, query)
+}
+
+func (s *scimService) GetUser(ctx context.Context, id string) (*entities.ScimUser, error) {
+	user, link, err := s.loadUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toScimUser(user, link), nil
+}
+
+// CreateUser provisions a user into the organization. An existing account is only
+// linked when it already is a member of the organization, accounts outside of it are
+// never taken over.
+func (s *scimService) CreateUser(ctx context.Context, input *entities.ScimUser) (*entities.ScimUser, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	organizationId := activeOrganization(ctx)
+	if input.UserName == "" {
+		return nil, newScimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	provisioned := false
+	user, err := s.userRepository.FindOneByEmailFold(ctx, input.UserName)
+	if err == nil {
+		if _, err := s.organizationRepository.FindAnyMembership(ctx, organizationId, user.ID); err != nil {
+			return nil, newScimError(http.StatusConflict, "uniqueness", "userName is already in use")
+		}
+		if _, err := s.repo.FindUserLink(ctx, organizationId, user.ID); err == nil {
+			return nil, newScimError(http.StatusConflict, "uniqueness", "userName is already in use")
+		}
+	} else {
+		password := input.Password
+		if password == "" {
+			// provisioned users sign in through the identity provider
+			password, err = tokens.GenerateSecret("", 32)
+			if err != nil {
+				return nil, err
+			}
+		}
+		user, err = s.userService.CreateUser(ctx, &models.User{
+			Email:     input.UserName,
+			FirstName: input.Name.GivenName,
+			LastName:  input.Name.FamilyName,
+			Password:  password,
+		}, nil)
+		if err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+		provisioned = true
+		_, err = s.organizationRepository.InsertMembership(ctx, &entities.Membership{
+			OrganizationId: organizationId,
+			UserId:         user.ID,
+			Role:           entities.OrganizationMember,
+			CreatedAt:      time.Now(),
+		})
+		if err != nil {
+			utils.Logger.Error("failed to insert membership", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	}
+	link, err := s.repo.UpsertUserLink(ctx, &entities.ScimUserLink{
+		OrganizationId: organizationId,
+		UserId:         user.ID,
+		ExternalId:     input.ExternalId,
+		Active:         input.Active == nil || *input.Active,
+		Provisioned:    provisioned,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert scim user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	if !link.Active {
+		if err := s.applyActive(ctx, user.ID, false); err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("provisioned scim user")
+	return s.toScimUser(user, link), nil
+}
+
+func (s *scimService) ReplaceUser(ctx context.Context, id string, input *entities.ScimUser, ifMatch string) (*entities.ScimUser, error) {
+	user, link, err := s.loadUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScimETag(s.toScimUser(user, link), ifMatch); err != nil {
+		return nil, err
+	}
+	return s.saveUser(ctx, user, link, input)
+}
+
+func (s *scimService) PatchUser(ctx context.Context, id string, patch *entities.ScimPatchRequest, ifMatch string) (*entities.ScimUser, error) {
+	user, link, err := s.loadUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current := s.toScimUser(user, link)
+	if err := checkScimETag(current, ifMatch); err != nil {
+		return nil, err
+	}
+	for _, operation := range patch.Operations {
+		if err := patchScimUser(current, operation); err != nil {
+			return nil, err
+		}
+	}
+	return s.saveUser(ctx, user, link, current)
+}
+
+// DeleteUser deprovisions the user: its sessions are revoked at once and it leaves the
+// organization. Accounts created through SCIM are deleted, subject to the usual grace
+// period, when they belong to no other organization.
+func (s *scimService) DeleteUser(ctx context.Context, id string, ifMatch string) error {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return err
+	}
+	organizationId := activeOrganization(ctx)
+	user, link, err := s.loadUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkScimETag(s.toScimUser(user, link), ifMatch); err != nil {
+		return err
+	}
+	if err := s.authenticationService.RevokeSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	groups, err := s.groupRepository.FindManyByOrganizationId(ctx, organizationId)
+	if err != nil {
+		utils.Logger.Error("failed to find groups", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	for _, group := range groups {
+		_, _ = s.groupRepository.DeleteMember(ctx, group.ID, user.ID)
+	}
+	_, err = s.organizationRepository.DeleteMembership(ctx, organizationId, user.ID)
+	if err != nil {
+		utils.Logger.Error("failed to delete membership", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.repo.DeleteUserLink(ctx, organizationId, user.ID)
+	if err != nil {
+		utils.Logger.Error("failed to delete scim user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	if link != nil && link.Provisioned {
+		memberships, err := s.organizationRepository.FindMembershipsByUserId(ctx, user.ID)
+		if err == nil && len(memberships) == 0 {
+			_, err = s.userService.DeleteUser(ctx, user.ID)
+			if err != nil {
+				utils.Logger.Error("failed to delete user", "error: ", err.Error())
+				_ = session.AbortTransaction(ctx)
+				return err
+			}
+		}
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("deprovisioned scim user")
+	return nil
+}
+
+func (s *scimService) GetGroups(ctx context.Context, query *entities.ScimListQuery) (*entities.ScimListResponse, error) {
+	groups, err := s.groupRepository.FindManyByOrganizationId(ctx, activeOrganization(ctx))
+	if err != nil {
+		utils.Logger.Error("failed to find groups", "error: ", err.Error())
+		return nil, err
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].ID.Hex() < groups[j].ID.Hex()
+	})
+	resources := make([]interface{}, 0, len(groups))
+	for i := range groups {
+		group, err := s.toScimGroup(ctx, &groups[i])
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, group)
+	}
+	return s.list(resources, query)
+}
+
+func (s *scimService) GetGroup(ctx context.Context, id string) (*entities.ScimGroup, error) {
+	group, err := s.loadGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toScimGroup(ctx, group)
+}
+
+func (s *scimService) CreateGroup(ctx context.Context, input *entities.ScimGroup) (*entities.ScimGroup, error) {
+	organizationId := activeOrganization(ctx)
+	if input.DisplayName == "" {
+		return nil, newScimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	if err := s.checkGroupName(ctx, primitive.NilObjectID, input.DisplayName); err != nil {
+		return nil, err
+	}
+	group, err := s.groupService.CreateGroup(ctx, organizationId, &entities.Group{
+		Name:       input.DisplayName,
+		ExternalId: input.ExternalId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncMembers(ctx, group, input.Members); err != nil {
+		return nil, err
+	}
+	utils.Logger.Info("provisioned scim group")
+	return s.toScimGroup(ctx, group)
+}
+
+func (s *scimService) ReplaceGroup(ctx context.Context, id string, input *entities.ScimGroup, ifMatch string) (*entities.ScimGroup, error) {
+	group, err := s.loadGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.toScimGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScimETag(current, ifMatch); err != nil {
+		return nil, err
+	}
+	return s.saveGroup(ctx, group, input)
+}
+
+func (s *scimService) PatchGroup(ctx context.Context, id string, patch *entities.ScimPatchRequest, ifMatch string) (*entities.ScimGroup, error) {
+	group, err := s.loadGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.toScimGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScimETag(current, ifMatch); err != nil {
+		return nil, err
+	}
+	for _, operation := range patch.Operations {
+		if err := patchScimGroup(current, operation); err != nil {
+			return nil, err
+		}
+	}
+	return s.saveGroup(ctx, group, current)
+}
+
+func (s *scimService) DeleteGroup(ctx context.Context, id string, ifMatch string) error {
+	group, err := s.loadGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+	current, err := s.toScimGroup(ctx, group)
+	if err != nil {
+		return err
+	}
+	if err := checkScimETag(current, ifMatch); err != nil {
+		return err
+	}
+	_, err = s.groupService.DeleteGroup(ctx, group.OrganizationId, group.ID)
+	return err
+}
+
+// loadUser finds a member of the calling organization along with its provisioning
+// state, which is nil for members that were never provisioned through SCIM.
+func (s *scimService) loadUser(ctx context.Context, id string) (*models.User, *entities.ScimUserLink, error) {
+	organizationId := activeOrganization(ctx)
+	userId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil, errScimNotFound
+	}
+	if _, err := s.organizationRepository.FindAnyMembership(ctx, organizationId, userId); err != nil {
+		return nil, nil, errScimNotFound
+	}
+	user, err := s.userRepository.FindOneById(ctx, userId)
+	if err != nil {
+		return nil, nil, errScimNotFound
+	}
+	link, err := s.repo.FindUserLink(ctx, organizationId, userId)
+	if err != nil {
+		link = nil
+	}
+	return user, link, nil
+}
+
+// saveUser stores the attributes of input on the user and its provisioning state,
+// suspending the membership of the user when it is deactivated. The email and password
+// only belong to the organization for users it provisioned.
+func (s *scimService) saveUser(ctx context.Context, user *models.User, link *entities.ScimUserLink, input *entities.ScimUser) (*entities.ScimUser, error) {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	if input.UserName == "" {
+		return nil, newScimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	provisioned := link != nil && link.Provisioned
+	if !provisioned && input.Password != "" {
+		return nil, newScimError(http.StatusBadRequest, "mutability", "password of a user not provisioned by scim cannot be set")
+	}
+	if !strings.EqualFold(input.UserName, user.Email) {
+		if !provisioned {
+			return nil, newScimError(http.StatusBadRequest, "mutability", "userName of a user not provisioned by scim cannot be changed")
+		}
+		if existing, err := s.userRepository.FindOneByEmailFold(ctx, input.UserName); err == nil && existing.ID != user.ID {
+			return nil, newScimError(http.StatusConflict, "uniqueness", "userName is already in use")
+		}
+	}
+	if provisioned {
+		user.Email = input.UserName
+	}
+	user.FirstName = input.Name.GivenName
+	user.LastName = input.Name.FamilyName
+	user, err = s.userRepository.UpdateProfile(ctx, user)
+	if err != nil {
+		utils.Logger.Error("failed to update user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	if input.Password != "" {
+		user.Password, err = utils.HashPassword(input.Password)
+		if err != nil {
+			utils.Logger.Error("failed to hash password", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+		if _, err := s.userRepository.UpdateOne(ctx, user); err != nil {
+			utils.Logger.Error("failed to update password", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	}
+	if link == nil {
+		link = &entities.ScimUserLink{
+			OrganizationId: activeOrganization(ctx),
+			UserId:         user.ID,
+			Active:         true,
+			CreatedAt:      time.Now(),
+		}
+	}
+	wasActive := link.Active
+	link.ExternalId = input.ExternalId
+	link.Active = input.Active == nil || *input.Active
+	link.UpdatedAt = time.Now()
+	link, err = s.repo.UpsertUserLink(ctx, link)
+	if err != nil {
+		utils.Logger.Error("failed to update scim user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	if wasActive != link.Active || !link.Active {
+		if err := s.applyActive(ctx, user.ID, link.Active); err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("updated scim user")
+	return s.toScimUser(user, link), nil
+}
+
+// applyActive suspends or restores the membership of the user in the calling
+// organization. A deactivated user loses its sessions and personal access tokens at
+// once, so nothing issued before keeps working.
+func (s *scimService) applyActive(ctx context.Context, userId primitive.ObjectID, active bool) error {
+	err := s.organizationRepository.UpdateMembershipSuspended(ctx, activeOrganization(ctx), userId, !active)
+	if err != nil {
+		utils.Logger.Error("failed to update membership", "error: ", err.Error())
+		return err
+	}
+	if active {
+		return nil
+	}
+	if err := s.authenticationService.RevokeSessions(ctx, userId); err != nil {
+		return err
+	}
+	if err := s.patRepository.DeleteManyByUserId(ctx, userId); err != nil {
+		utils.Logger.Error("failed to delete personal access tokens", "error: ", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s *scimService) toScimUser(user *models.User, link *entities.ScimUserLink) *entities.ScimUser {
+	active := true
+	created := user.ID.Timestamp()
+	modified := created
+	externalId := ""
+	if link != nil {
+		active = link.Active
+		created = link.CreatedAt
+		modified = link.UpdatedAt
+		externalId = link.ExternalId
+	}
+	result := &entities.ScimUser{
+		Schemas:    []string{entities.ScimUserSchema},
+		ID:         user.ID.Hex(),
+		ExternalId: externalId,
+		UserName:   user.Email,
+		Name: entities.ScimName{
+			Formatted:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		},
+		DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Emails: []entities.ScimEmail{
+			{Value: user.Email, Type: "work", Primary: true},
+		},
+		Active: &active,
+		Meta: &entities.ScimMeta{
+			ResourceType: "User",
+			Created:      created.UTC(),
+			LastModified: modified.UTC(),
+			Location:     s.baseURL + "/scim/v2/Users/" + user.ID.Hex(),
+		},
+	}
+	result.Meta.Version = scimETag(result)
+	return result
+}
+
+func (s *scimService) loadGroup(ctx context.Context, id string) (*entities.Group, error) {
+	groupId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errScimNotFound
+	}
+	group, err := s.groupRepository.FindOneById(ctx, groupId)
+	if err != nil || group.OrganizationId != activeOrganization(ctx) {
+		return nil, errScimNotFound
+	}
+	return group, nil
+}
+
+func (s *scimService) saveGroup(ctx context.Context, group *entities.Group, input *entities.ScimGroup) (*entities.ScimGroup, error) {
+	if input.DisplayName == "" {
+		return nil, newScimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	if err := s.checkGroupName(ctx, group.ID, input.DisplayName); err != nil {
+		return nil, err
+	}
+	group.Name = input.DisplayName
+	group.ExternalId = input.ExternalId
+	group, err := s.groupService.UpdateGroup(ctx, group.OrganizationId, group)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.syncMembers(ctx, group, input.Members); err != nil {
+		return nil, err
+	}
+	utils.Logger.Info("updated scim group")
+	return s.toScimGroup(ctx, group)
+}
+
+func (s *scimService) checkGroupName(ctx context.Context, id primitive.ObjectID, name string) error {
+	groups, err := s.groupRepository.FindManyByOrganizationId(ctx, activeOrganization(ctx))
+	if err != nil {
+		utils.Logger.Error("failed to find groups", "error: ", err.Error())
+		return err
+	}
+	for _, group := range groups {
+		if group.ID != id && strings.EqualFold(group.Name, name) {
+			return newScimError(http.StatusConflict, "uniqueness", "displayName is already in use")
+		}
+	}
+	return nil
+}
+
+// syncMembers makes members the exact set of direct members of the group.
+func (s *scimService) syncMembers(ctx context.Context, group *entities.Group, members []entities.ScimMember) error {
+	current, err := s.groupRepository.FindMembersByGroupId(ctx, group.ID)
+	if err != nil {
+		utils.Logger.Error("failed to find group members", "error: ", err.Error())
+		return err
+	}
+	wanted := map[string]entities.ScimMember{}
+	for _, member := range members {
+		wanted[member.Value] = member
+	}
+	for _, member := range current {
+		if _, ok := wanted[member.MemberId.Hex()]; ok {
+			delete(wanted, member.MemberId.Hex())
+		} else if _, err := s.groupService.RemoveMember(ctx, group.OrganizationId, group.ID, member.MemberId); err != nil {
+			return err
+		}
+	}
+	for _, member := range wanted {
+		memberId, err := primitive.ObjectIDFromHex(member.Value)
+		if err != nil {
+			return newScimError(http.StatusBadRequest, "invalidValue", fmt.Sprintf("unknown member %s", member.Value))
+		}
+		memberType := entities.GroupMemberUser
+		if strings.EqualFold(member.Type, "Group") {
+			memberType = entities.GroupMemberGroup
+		} else if member.Type == "" {
+			if nested, err := s.groupRepository.FindOneById(ctx, memberId); err == nil && nested.OrganizationId == group.OrganizationId {
+				memberType = entities.GroupMemberGroup
+			}
+		}
+		_, err = s.groupService.AddMember(ctx, group.OrganizationId, group.ID, &entities.GroupMemberInput{
+			MemberType: memberType,
+			MemberId:   memberId,
+		})
+		if err != nil {
+			return newScimError(http.StatusBadRequest, "invalidValue", err.Error())
+		}
+	}
+	return nil
+}
+
+func (s *scimService) toScimGroup(ctx context.Context, group *entities.Group) (*entities.ScimGroup, error) {
+	members, err := s.groupRepository.FindMembersByGroupId(ctx, group.ID)
+	if err != nil {
+		utils.Logger.Error("failed to find group members", "error: ", err.Error())
+		return nil, err
+	}
+	result := &entities.ScimGroup{
+		Schemas:     []string{entities.ScimGroupSchema},
+		ID:          group.ID.Hex(),
+		ExternalId:  group.ExternalId,
+		DisplayName: group.Name,
+		Members:     []entities.ScimMember{},
+		Meta: &entities.ScimMeta{
+			ResourceType: "Group",
+			Created:      group.CreatedAt.UTC(),
+			LastModified: group.UpdatedAt.UTC(),
+			Location:     s.baseURL + "/scim/v2/Groups/" + group.ID.Hex(),
+		},
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].MemberId.Hex() < members[j].MemberId.Hex()
+	})
+	for _, member := range members {
+		resourceType := "User"
+		if member.MemberType == entities.GroupMemberGroup {
+			resourceType = "Group"
+		}
+		result.Members = append(result.Members, entities.ScimMember{
+			Value: member.MemberId.Hex(),
+			Type:  resourceType,
+			Ref:   s.baseURL + "/scim/v2/" + resourceType + "s/" + member.MemberId.Hex(),
+		})
+	}
+	result.Meta.Version = scimETag(result)
+	return result, nil
+}
+
+// list filters resources and returns the requested page of them.
+func (s *scimService) list(resources []interface{}, query *entities.ScimListQuery) (*entities.ScimListResponse, error) {
+	if query.Filter != "" {
+		filter, err := parseScimFilter(query.Filter)
+		if err != nil {
+			return nil, newScimError(http.StatusBadRequest, "invalidFilter", err.Error())
+		}
+		var matched []interface{}
+		for _, resource := range resources {
+			var generic map[string]interface{}
+			encoded, _ := json.Marshal(resource)
+			_ = json.Unmarshal(encoded, &generic)
+			if filter.matches(generic) {
+				matched = append(matched, resource)
+			}
+		}
+		resources = matched
+	}
+	startIndex := query.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count := scimDefaultCount
+	if query.Count != nil {
+		count = min(max(*query.Count, 0), scimMaxCount)
+	}
+	page := []interface{}{}
+	if startIndex <= len(resources) {
+		page = resources[startIndex-1 : min(startIndex-1+count, len(resources))]
+	}
+	return &entities.ScimListResponse{
+		Schemas:      []string{entities.ScimListResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}, nil
+}
+
+// scimETag returns a weak entity tag derived from the representation of a resource.
+// It is computed before meta.version is set, which then carries the tag.
+func scimETag(resource interface{}) string {
+	encoded, _ := json.Marshal(resource)
+	sum := sha256.Sum256(encoded)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ScimETag returns the entity tag of a SCIM resource for the ETag header.
+func ScimETag(resource interface{}) string {
+	switch r := resource.(type) {
+	case *entities.ScimUser:
+		return r.Meta.Version
+	case *entities.ScimGroup:
+		return r.Meta.Version
+	}
+	return ""
+}
+
+// checkScimETag fails with 412 unless ifMatch is empty, "*" or lists the current
+// entity tag of the resource.
+func checkScimETag(resource interface{}, ifMatch string) error {
+	if ifMatch == "" || strings.TrimSpace(ifMatch) == "*" {
+		return nil
+	}
+	current := strings.TrimPrefix(ScimETag(resource), "W/")
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == current {
+			return nil
+		}
+	}
+	return newScimError(http.StatusPreconditionFailed, "", "resource has been modified")
+}
+
+// patchScimUser applies a PATCH operation to user. The email of a user mirrors its
+// userName, so email and extension attributes are accepted but ignored.
+func patchScimUser(user *entities.ScimUser, operation entities.ScimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return newScimError(http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("unsupported operation %q", operation.Op))
+	}
+	if operation.Path == "" {
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok || op == "remove" {
+			return newScimError(http.StatusBadRequest, "noTarget", "path is required")
+		}
+		for path, value := range values {
+			if err := patchScimUserAttribute(user, op, path, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return patchScimUserAttribute(user, op, operation.Path, operation.Value)
+}
+
+func patchScimUserAttribute(user *entities.ScimUser, op string, path string, value interface{}) error {
+	path = strings.ToLower(path)
+	if strings.HasPrefix(path, "urn:") && !strings.HasPrefix(path, strings.ToLower(entities.ScimUserSchema)+":") {
+		return nil
+	}
+	path = strings.ToLower(trimScimSchema(path))
+	if op == "remove" {
+		value = nil
+	}
+	switch {
+	case path == "username":
+		if op == "remove" {
+			return newScimError(http.StatusBadRequest, "mutability", "userName cannot be removed")
+		}
+		return setScimString(&user.UserName, value)
+	case path == "externalid":
+		return setScimString(&user.ExternalId, value)
+	case path == "password":
+		return setScimString(&user.Password, value)
+	case path == "name.givenname":
+		return setScimString(&user.Name.GivenName, value)
+	case path == "name.familyname":
+		return setScimString(&user.Name.FamilyName, value)
+	case path == "name":
+		if value == nil {
+			user.Name = entities.ScimName{}
+			return nil
+		}
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return newScimError(http.StatusBadRequest, "invalidValue", "name must be an object")
+		}
+		for key, v := range values {
+			if err := patchScimUserAttribute(user, op, "name."+key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case path == "active":
+		active, err := scimBool(value)
+		if err != nil {
+			return err
+		}
+		user.Active = &active
+		return nil
+	case path == "displayname", path == "name.formatted", strings.HasPrefix(path, "emails"):
+		return nil
+	default:
+		return newScimError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported path %q", path))
+	}
+}
+
+// patchScimGroup applies a PATCH operation to group, supporting value paths such as
+// members[value eq "..."] for removals.
+func patchScimGroup(group *entities.ScimGroup, operation entities.ScimPatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	path := strings.ToLower(trimScimSchema(operation.Path))
+	switch {
+	case path == "" && op != "remove":
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok {
+			return newScimError(http.StatusBadRequest, "noTarget", "path is required")
+		}
+		for key, value := range values {
+			if err := patchScimGroup(group, entities.ScimPatchOperation{Op: op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case path == "displayname" && op != "remove":
+		return setScimString(&group.DisplayName, operation.Value)
+	case path == "externalid":
+		if op == "remove" {
+			group.ExternalId = ""
+			return nil
+		}
+		return setScimString(&group.ExternalId, operation.Value)
+	case path == "members":
+		members, err := scimMembers(operation.Value)
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "add":
+			group.Members = append(group.Members, members...)
+		case "replace":
+			group.Members = members
+		case "remove":
+			if operation.Value == nil {
+				group.Members = nil
+				return nil
+			}
+			group.Members = removeScimMembers(group.Members, func(member entities.ScimMember) bool {
+				for _, removed := range members {
+					if removed.Value == member.Value {
+						return true
+					}
+				}
+				return false
+			})
+		default:
+			return newScimError(http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("unsupported operation %q", operation.Op))
+		}
+		return nil
+	case strings.HasPrefix(path, "members[") && op == "remove":
+		filter, err := parseScimFilter(operation.Path[len("members[") : len(operation.Path)-1])
+		if err != nil || !strings.HasSuffix(path, "]") {
+			return newScimError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported path %q", operation.Path))
+		}
+		group.Members = removeScimMembers(group.Members, func(member entities.ScimMember) bool {
+			return filter.matches(map[string]interface{}{"value": member.Value, "type": member.Type})
+		})
+		return nil
+	default:
+		return newScimError(http.StatusBadRequest, "invalidPath", fmt.Sprintf("unsupported path %q", operation.Path))
+	}
+}
+
+func removeScimMembers(members []entities.ScimMember, removed func(member entities.ScimMember) bool) []entities.ScimMember {
+	var result []entities.ScimMember
+	for _, member := range members {
+		if !removed(member) {
+			result = append(result, member)
+		}
+	}
+	return result
+}
+
+func scimMembers(value interface{}) ([]entities.ScimMember, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, _ := json.Marshal(value)
+	var members []entities.ScimMember
+	if err := json.Unmarshal(encoded, &members); err != nil {
+		return nil, newScimError(http.StatusBadRequest, "invalidValue", "members must be a list")
+	}
+	return members, nil
+}
+
+func setScimString(target *string, value interface{}) error {
+	if value == nil {
+		*target = ""
+		return nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return newScimError(http.StatusBadRequest, "invalidValue", "expected a string value")
+	}
+	*target = str
+	return nil
+}
+
+// scimBool accepts booleans as well as the "True" and "False" strings some identity
+// providers send.
+func scimBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if strings.EqualFold(v, "true") {
+			return true, nil
+		} else if strings.EqualFold(v, "false") {
+			return false, nil
+		}
+	}
+	return false, newScimError(http.StatusBadRequest, "invalidValue", "expected a boolean value")
+}
@@ -0,0 +1,301 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// scimFilter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2).
+type scimFilter struct {
+	// op is "and", "or", "not", "[]" for value paths, "pr" or a comparison operator.
+	op    string
+	path  string
+	value interface{}
+	left  *scimFilter
+	right *scimFilter
+}
+
+type scimFilterParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseScimFilter parses filter, which supports every operator of the RFC including
+// grouping, negation and value paths such as emails[type eq "work"].
+func parseScimFilter(filter string) (*scimFilter, error) {
+	tokens, err := tokenizeScimFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &scimFilterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+func tokenizeScimFilter(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		c := filter[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(filter) && filter[j] != '"'; j++ {
+				if filter[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(filter) {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, filter[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(filter) && !strings.ContainsRune(" \t()[]\"", rune(filter[j])); j++ {
+			}
+			tokens = append(tokens, filter[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func (p *scimFilterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *scimFilterParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *scimFilterParser) parseOr() (*scimFilter, error) {
+	left, err := p.parseAnd()
+	for err == nil && strings.EqualFold(p.peek(), "or") {
+		p.next()
+		var right *scimFilter
+		right, err = p.parseAnd()
+		left = &scimFilter{op: "or", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *scimFilterParser) parseAnd() (*scimFilter, error) {
+	left, err := p.parseFactor()
+	for err == nil && strings.EqualFold(p.peek(), "and") {
+		p.next()
+		var right *scimFilter
+		right, err = p.parseFactor()
+		left = &scimFilter{op: "and", left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *scimFilterParser) parseFactor() (*scimFilter, error) {
+	token := p.next()
+	if strings.EqualFold(token, "not") {
+		if p.next() != "(" {
+			return nil, fmt.Errorf("expected ( after not")
+		}
+		f, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &scimFilter{op: "not", left: f}, nil
+	}
+	if token == "(" {
+		return p.parseGroup()
+	}
+	if token == "" || !isScimAttrPath(token) {
+		return nil, fmt.Errorf("expected attribute path in filter")
+	}
+	if p.peek() == "[" {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != "]" {
+			return nil, fmt.Errorf("expected ] in filter")
+		}
+		return &scimFilter{op: "[]", path: token, left: f}, nil
+	}
+	op := strings.ToLower(p.next())
+	switch op {
+	case "pr":
+		return &scimFilter{op: op, path: token}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+		var value interface{}
+		if err := json.Unmarshal([]byte(p.next()), &value); err != nil {
+			return nil, fmt.Errorf("invalid comparison value in filter")
+		}
+		return &scimFilter{op: op, path: token, value: value}, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %q in filter", op)
+	}
+}
+
+func (p *scimFilterParser) parseGroup() (*scimFilter, error) {
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next() != ")" {
+		return nil, fmt.Errorf("expected ) in filter")
+	}
+	return f, nil
+}
+
+func isScimAttrPath(token string) bool {
+	r := []rune(token)
+	return len(r) > 0 && unicode.IsLetter(r[0])
+}
+
+// matches evaluates the filter against a resource decoded into generic JSON values.
+func (f *scimFilter) matches(resource map[string]interface{}) bool {
+	switch f.op {
+	case "and":
+		return f.left.matches(resource) && f.right.matches(resource)
+	case "or":
+		return f.left.matches(resource) || f.right.matches(resource)
+	case "not":
+		return !f.left.matches(resource)
+	case "[]":
+		for _, v := range scimValues(resource, f.path) {
+			if element, ok := v.(map[string]interface{}); ok && f.left.matches(element) {
+				return true
+			}
+		}
+		return false
+	case "pr":
+		for _, v := range scimValues(resource, f.path) {
+			if v != nil && v != "" {
+				return true
+			}
+		}
+		return false
+	default:
+		values := scimValues(resource, f.path)
+		if f.op == "ne" {
+			for _, v := range values {
+				if compareScimValue("eq", v, f.value) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, v := range values {
+			if compareScimValue(f.op, v, f.value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// scimValues resolves an attribute path such as "name.familyName" or
+// "emails.value" case insensitively, flattening multi-valued attributes.
+func scimValues(resource map[string]interface{}, path string) []interface{} {
+	path = trimScimSchema(path)
+	values := []interface{}{resource}
+	for _, name := range strings.Split(path, ".") {
+		var next []interface{}
+		for _, v := range values {
+			object, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, attribute := range object {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				if list, ok := attribute.([]interface{}); ok {
+					next = append(next, list...)
+				} else {
+					next = append(next, attribute)
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// trimScimSchema drops the schema URN some clients prefix attribute paths with.
+func trimScimSchema(path string) string {
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		if i := strings.LastIndex(path, ":"); i >= 0 {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+func compareScimValue(op string, actual interface{}, expected interface{}) bool {
+	switch a := actual.(type) {
+	case string:
+		e, ok := expected.(string)
+		if !ok {
+			return false
+		}
+		a, e = strings.ToLower(a), strings.ToLower(e)
+		switch op {
+		case "eq":
+			return a == e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case float64:
+		e, ok := expected.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case bool:
+		e, ok := expected.(bool)
+		return ok && op == "eq" && a == e
+	case nil:
+		return op == "eq" && expected == nil
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	horizonjwt "github.com/draco121/horizon/jwt"
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+// exchangedTokenLifetime is deliberately shorter than a login token; callers are
+// expected to exchange again rather than hold on to delegated credentials.
+const exchangedTokenLifetime = 15 * time.Minute
+
+// Token exchange errors carry the RFC 6749 error codes so they can be returned as is.
+var (
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+	ErrUnauthorizedClient   = errors.New("unauthorized_client")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+	ErrInvalidScope         = errors.New("invalid_scope")
+	ErrInvalidTarget        = errors.New("invalid_target")
+)
+
+// IsOAuthError reports whether err is one of the token exchange errors above.
+func IsOAuthError(err error) bool {
+	for _, e := range []error{ErrInvalidRequest, ErrInvalidClient, ErrInvalidGrant, ErrUnauthorizedClient,
+		ErrUnsupportedGrantType, ErrInvalidScope, ErrInvalidTarget} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+type ITokenExchangeService interface {
+	Exchange(ctx context.Context, input *entities.TokenExchangeInput) (*entities.TokenExchangeOutput, error)
+	CreateClient(ctx context.Context, input *entities.TokenExchangeClientInput) (*entities.TokenExchangeClientOutput, error)
+	DeleteClient(ctx context.Context, clientId string) (*entities.TokenExchangeClient, error)
+}
+
+type tokenExchangeService struct {
+	ITokenExchangeService
+	repo                  repository.ITokenExchangeRepository
+	authenticationService IAuthenticationService
+	client                *mongo.Client
+}
+
+func NewTokenExchangeService(client *mongo.Client, repository repository.ITokenExchangeRepository, authenticationService IAuthenticationService) ITokenExchangeService {
+	return &tokenExchangeService{
+		repo:                  repository,
+		authenticationService: authenticationService,
+		client:                client,
+	}
+}
+
+func (s *tokenExchangeService) CreateClient(ctx context.Context, input *entities.TokenExchangeClientInput) (*entities.TokenExchangeClientOutput, error) {
+	secret, err := tokens.GenerateSecret("", 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate client secret", "error: ", err.Error())
+		return nil, err
+	}
+	client := entities.TokenExchangeClient{
+		ClientId:    input.ClientId,
+		SecretHash:  tokens.HashSecret(secret),
+		Audiences:   input.Audiences,
+		Scopes:      input.Scopes,
+		AllowActors: input.AllowActors,
+		CreatedAt:   time.Now(),
+	}
+	result, err := s.repo.InsertClient(ctx, &client)
+	if err != nil {
+		utils.Logger.Error("failed to insert token exchange client", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created token exchange client")
+	return &entities.TokenExchangeClientOutput{
+		TokenExchangeClient: *result,
+		ClientSecret:        secret,
+	}, nil
+}
+
+func (s *tokenExchangeService) DeleteClient(ctx context.Context, clientId string) (*entities.TokenExchangeClient, error) {
+	client, err := s.repo.DeleteClientByClientId(ctx, clientId)
+	if err != nil {
+		utils.Logger.Error("failed to delete token exchange client", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("deleted token exchange client")
+	return client, nil
+}
+
+func (s *tokenExchangeService) Exchange(ctx context.Context, input *entities.TokenExchangeInput) (*entities.TokenExchangeOutput, error) {
+	if input.GrantType != entities.TokenExchangeGrantType {
+		return nil, ErrUnsupportedGrantType
+	}
+	if !isSupportedTokenType(input.SubjectTokenType) {
+		return nil, ErrInvalidRequest
+	}
+	if input.ActorToken != "" && !isSupportedTokenType(input.ActorTokenType) {
+		return nil, ErrInvalidRequest
+	}
+	if input.RequestedTokenType != "" && !isSupportedTokenType(input.RequestedTokenType) {
+		return nil, ErrInvalidRequest
+	}
+	client, err := s.repo.FindClientByClientId(ctx, input.ClientId)
+	if err != nil || !tokens.CheckSecretHash(input.ClientSecret, client.SecretHash) {
+		utils.Logger.Info("invalid token exchange client")
+		return nil, ErrInvalidClient
+	}
+	audience := input.Audience
+	if audience == "" && len(client.Audiences) == 1 {
+		audience = client.Audiences[0]
+	}
+	if !slices.Contains(client.Audiences, audience) {
+		utils.Logger.Info("token exchange client not allowed for audience ", audience)
+		return nil, ErrInvalidTarget
+	}
+
+	subject, err := s.authenticationService.Authenticate(ctx, input.SubjectToken)
+	if err != nil {
+		utils.Logger.Error("failed to authenticate subject token", "error: ", err.Error())
+		return nil, ErrInvalidGrant
+	}
+	subjectClaims, err := parseExchangedToken(input.SubjectToken)
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+	// a token that was itself exchanged may only be narrowed further, and one without
+	// scope grants nothing to narrow
+	exchanged := subjectClaims.ClientId != ""
+	subjectScopes := strings.Fields(subjectClaims.Scope)
+	scopes := strings.Fields(input.Scope)
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, ErrInvalidScope
+		}
+		if exchanged && !slices.Contains(subjectScopes, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+	if len(scopes) == 0 && exchanged {
+		scopes = subjectScopes
+	}
+
+	exchange := entities.TokenExchange{
+		ClientId:  client.ClientId,
+		SubjectId: subject.UserId,
+		Audience:  audience,
+		Scope:     strings.Join(scopes, " "),
+		CreatedAt: time.Now(),
+	}
+	claims := entities.ExchangedTokenClaims{
+		JwtCustomClaims: subject.JwtCustomClaims,
+		Scope:           exchange.Scope,
+		ClientId:        client.ClientId,
+		Actor:           subjectClaims.Actor,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  audience,
+			Subject:   subject.UserId.Hex(),
+			IssuedAt:  exchange.CreatedAt.Unix(),
+			ExpiresAt: exchange.CreatedAt.Add(exchangedTokenLifetime).Unix(),
+		},
+	}
+	if input.ActorToken != "" {
+		if !client.AllowActors {
+			utils.Logger.Info("token exchange client not allowed to present actor tokens")
+			return nil, ErrUnauthorizedClient
+		}
+		actor, err := s.authenticationService.Authenticate(ctx, input.ActorToken)
+		if err != nil {
+			utils.Logger.Error("failed to authenticate actor token", "error: ", err.Error())
+			return nil, ErrInvalidGrant
+		}
+		exchange.ActorId = &actor.UserId
+		claims.Actor = &entities.ActorClaim{
+			Subject: actor.UserId.Hex(),
+			Email:   actor.Email,
+			Actor:   subjectClaims.Actor,
+		}
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(horizonjwt.JWTSecretKey)
+	if err != nil {
+		utils.Logger.Error("failed to sign exchanged token", "error: ", err.Error())
+		return nil, err
+	}
+	_, err = s.repo.InsertExchange(ctx, &exchange)
+	if err != nil {
+		utils.Logger.Error("failed to record token exchange", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("exchanged token for client ", client.ClientId)
+	return &entities.TokenExchangeOutput{
+		AccessToken:     token,
+		IssuedTokenType: entities.AccessTokenType,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(exchangedTokenLifetime.Seconds()),
+		Scope:           exchange.Scope,
+	}, nil
+}
+
+func isSupportedTokenType(tokenType string) bool {
+	return tokenType == entities.AccessTokenType || tokenType == entities.JwtTokenType
+}
+
+// parseExchangedToken reads the exchange specific claims of a token that has already
+// been verified through Authenticate.
+func parseExchangedToken(token string) (*entities.ExchangedTokenClaims, error) {
+	claims := entities.ExchangedTokenClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		return horizonjwt.JWTSecretKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
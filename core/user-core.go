@@ -1,38 +1,120 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/draco121/horizon/constants"
 	"github.com/draco121/horizon/models"
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
 	"shield/repository"
 
 	"github.com/draco121/horizon/utils"
 )
 
 type IUserService interface {
-	CreateUser(ctx context.Context, user *models.User) (*models.User, error)
-	UpdateUser(ctx context.Context, user *models.User) (*models.User, error)
+	// CreateUser creates a user. document carries the profile and custom attributes of
+	// self-service registrations and is nil otherwise.
+	CreateUser(ctx context.Context, user *models.User, document *entities.ProfileDocument) (*models.User, error)
+	UpdateUser(ctx context.Context, patch []byte) (*entities.UserView, error)
+	UpdateUserProfile(ctx context.Context, id primitive.ObjectID, patch []byte) (*entities.UserView, error)
 	DeleteUser(ctx context.Context, id primitive.ObjectID) (*models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
-	GetUserById(ctx context.Context) (*models.User, error)
+	GetUserById(ctx context.Context) (*entities.UserView, error)
+	GetUsers(ctx context.Context, query *entities.UserQuery) (*entities.UserPage, error)
+	GetUser(ctx context.Context, id primitive.ObjectID) (*entities.UserView, error)
+	ChangeRole(ctx context.Context, id primitive.ObjectID, input *entities.UserRoleInput) (*entities.UserView, error)
+	ChangeStatus(ctx context.Context, id primitive.ObjectID, input *entities.UserStatusInput) (*entities.UserView, error)
+	RestoreUser(ctx context.Context, id primitive.ObjectID) (*entities.UserView, error)
+	PurgeDeletedUsers(ctx context.Context) (int, error)
 }
 
 type userService struct {
 	IUserService
-	repo   repository.IUserRepository
-	client *mongo.Client
+	repo                          repository.IUserRepository
+	organizationRepository        repository.IOrganizationRepository
+	authenticationRepository      repository.IAuthenticationRepository
+	personalAccessTokenRepository repository.IPersonalAccessTokenRepository
+	identityRepository            repository.IIdentityRepository
+	roleRepository                repository.IRoleRepository
+	groupRepository               repository.IGroupRepository
+	scimRepository                repository.IScimRepository
+	exportRepository              repository.IExportRepository
+	loginRepository               repository.ILoginRepository
+	outboxRepository              repository.IOutboxRepository
+	emailChangeRepository         repository.IEmailChangeRepository
+	auditService                  IAuditService
+	hookRunner                    IHookRunner
+	profileService                IProfileService
+	deletionGracePeriod           time.Duration
+	client                        *mongo.Client
 }
 
-func NewUserService(client *mongo.Client, repository repository.IUserRepository) IUserService {
+// NewUserService creates the user service. Deleted users can be restored for
+// deletionGracePeriod before PurgeDeletedUsers removes them for good.
+func NewUserService(client *mongo.Client, repository repository.IUserRepository, organizationRepository repository.IOrganizationRepository, authenticationRepository repository.IAuthenticationRepository, personalAccessTokenRepository repository.IPersonalAccessTokenRepository, identityRepository repository.IIdentityRepository, roleRepository repository.IRoleRepository, groupRepository repository.IGroupRepository, scimRepository repository.IScimRepository, exportRepository repository.IExportRepository, loginRepository repository.ILoginRepository, outboxRepository repository.IOutboxRepository, emailChangeRepository repository.IEmailChangeRepository, auditService IAuditService, hookRunner IHookRunner, profileService IProfileService, deletionGracePeriod time.Duration) IUserService {
 	return &userService{
-		repo:   repository,
-		client: client,
+		repo:                          repository,
+		organizationRepository:        organizationRepository,
+		authenticationRepository:      authenticationRepository,
+		personalAccessTokenRepository: personalAccessTokenRepository,
+		identityRepository:            identityRepository,
+		roleRepository:                roleRepository,
+		groupRepository:               groupRepository,
+		scimRepository:                scimRepository,
+		exportRepository:              exportRepository,
+		loginRepository:               loginRepository,
+		outboxRepository:              outboxRepository,
+		emailChangeRepository:         emailChangeRepository,
+		auditService:                  auditService,
+		hookRunner:                    hookRunner,
+		profileService:                profileService,
+		deletionGracePeriod:           deletionGracePeriod,
+		client:                        client,
 	}
 }
 
-func (s *userService) CreateUser(ctx context.Context, user *models.User) (*models.User, error) {
+func (s *userService) CreateUser(ctx context.Context, user *models.User, document *entities.ProfileDocument) (result *models.User, err error) {
+	defer func() {
+		var target *primitive.ObjectID
+		if result != nil {
+			target = &result.ID
+		}
+		s.auditService.Record(ctx, &entities.AuditEvent{
+			Type:     entities.AuditUserCreated,
+			Target:   target,
+			Metadata: map[string]string{"email": user.Email},
+		}, err)
+	}()
+	// hooks may veto the registration before anything is written
+	_, err = s.hookRunner.Run(ctx, &entities.HookRequest{
+		Point: entities.HookPreRegistration,
+		User: entities.UserView{
+			Email:     user.Email,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Role:      constants.Tenant,
+			Status:    entities.UserActive,
+		},
+		ClientIP:  clientIP(ctx),
+		UserAgent: userAgent(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if document != nil {
+		document.FirstName, document.LastName = user.FirstName, user.LastName
+		err = s.profileService.Validate(ctx, &entities.ProfileDocument{}, document, false)
+		if err != nil {
+			return nil, err
+		}
+	}
 	session, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -51,9 +133,25 @@ func (s *userService) CreateUser(ctx context.Context, user *models.User) (*model
 	} else {
 		user.Password = hashedPassword
 		user.Role = constants.Tenant
-		user, err = s.repo.InsertOne(ctx, user)
+		// the user and its event are written in the transaction
+		sessionCtx := mongo.NewSessionContext(ctx, session)
+		user, err = s.repo.InsertOne(sessionCtx, user)
 		if err != nil {
 			utils.Logger.Error("failed to insert user", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+		record := &entities.UserRecord{User: *user}
+		if document != nil {
+			record, err = s.repo.UpdateDocument(sessionCtx, user.ID, document)
+			if err != nil {
+				utils.Logger.Error("failed to store user profile", "error: ", err.Error())
+				_ = session.AbortTransaction(ctx)
+				return nil, err
+			}
+		}
+		if err := recordEvent(sessionCtx, s.outboxRepository, entities.EventUserCreated, user.ID, entities.NewUserView(record)); err != nil {
+			_ = session.AbortTransaction(ctx)
 			return nil, err
 		} else {
 			_ = session.CommitTransaction(ctx)
@@ -63,7 +161,7 @@ func (s *userService) CreateUser(ctx context.Context, user *models.User) (*model
 	}
 }
 
-func (s *userService) GetUserById(ctx context.Context) (*models.User, error) {
+func (s *userService) GetUserById(ctx context.Context) (*entities.UserView, error) {
 	session, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -76,14 +174,15 @@ func (s *userService) GetUserById(ctx context.Context) (*models.User, error) {
 		return nil, err
 	}
 	userId := ctx.Value("UserId").(primitive.ObjectID)
-	user, err := s.repo.FindOneById(ctx, userId)
+	record, err := s.repo.FindRecordById(ctx, userId)
 	if err != nil {
 		utils.Logger.Error("failed to find user", "error: ", err.Error())
 		return nil, err
 	} else {
 		_ = session.CommitTransaction(ctx)
 		utils.Logger.Info("fetched user")
-		return user, nil
+		result := entities.NewUserView(record)
+		return &result, nil
 	}
 }
 
@@ -103,6 +202,8 @@ func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models
 	if err != nil {
 		utils.Logger.Error("failed to find user", "error: ", err.Error())
 		return nil, err
+	} else if err := s.inOrganization(ctx, user.ID); err != nil {
+		return nil, err
 	} else {
 		_ = session.CommitTransaction(ctx)
 		utils.Logger.Info("fetched user")
@@ -110,7 +211,87 @@ func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models
 	}
 }
 
-func (s *userService) UpdateUser(ctx context.Context, user *models.User) (*models.User, error) {
+// UpdateUser applies a JSON Merge Patch to the profile document of the caller. Users
+// can change their password this way but not the admin-only attributes.
+func (s *userService) UpdateUser(ctx context.Context, patch []byte) (result *entities.UserView, err error) {
+	userId := ctx.Value("UserId").(primitive.ObjectID)
+	defer func() {
+		s.audit(ctx, entities.AuditUserUpdated, userId, nil, err)
+	}()
+	return s.patchUser(ctx, userId, patch, true)
+}
+
+// UpdateUserProfile applies a JSON Merge Patch to the profile document of a user on
+// behalf of an operator, who may change every attribute but not the password.
+func (s *userService) UpdateUserProfile(ctx context.Context, id primitive.ObjectID, patch []byte) (result *entities.UserView, err error) {
+	defer func() {
+		s.audit(ctx, entities.AuditUserUpdated, id, nil, err)
+	}()
+	if err := s.inOrganization(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.patchUser(ctx, id, patch, false)
+}
+
+func (s *userService) patchUser(ctx context.Context, id primitive.ObjectID, patch []byte, self bool) (*entities.UserView, error) {
+	record, err := s.repo.FindRecordById(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to find user", "error: ", err.Error())
+		return nil, fmt.Errorf("user not found")
+	}
+	original, err := json.Marshal(&entities.ProfileDocument{
+		FirstName:  record.FirstName,
+		LastName:   record.LastName,
+		Profile:    record.Profile,
+		Attributes: record.Attributes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged, err := jsonpatch.MergePatch(original, patch)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %s", err.Error())
+	}
+	var document entities.ProfileDocument
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("invalid profile: %s", err.Error())
+	}
+	// compare against the original as JSON sees it, not as it was decoded from mongo
+	var previous entities.ProfileDocument
+	if err := json.Unmarshal(original, &previous); err != nil {
+		return nil, err
+	}
+	if document.Password != "" {
+		if !self {
+			return nil, fmt.Errorf("password can only be changed by the user")
+		}
+		document.Password, err = utils.HashPassword(document.Password)
+		if err != nil {
+			utils.Logger.Error("failed to hash password", "error: ", err.Error())
+			return nil, err
+		}
+	}
+	if err := s.profileService.Validate(ctx, &previous, &document, !self); err != nil {
+		return nil, err
+	}
+	record, err = s.repo.UpdateDocument(ctx, id, &document)
+	if err != nil {
+		utils.Logger.Error("failed to update user", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("updated user")
+	view := entities.NewUserView(record)
+	return &view, nil
+}
+
+// DeleteUser marks the user as pending deletion and ends its sessions. The user can be
+// restored until the grace period ends and PurgeDeletedUsers removes it.
+func (s *userService) DeleteUser(ctx context.Context, id primitive.ObjectID) (result *models.User, err error) {
+	defer func() {
+		s.audit(ctx, entities.AuditUserDeleted, id, nil, err)
+	}()
 	session, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -122,25 +303,286 @@ func (s *userService) UpdateUser(ctx context.Context, user *models.User) (*model
 		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
 		return nil, err
 	}
-	newPassword, err := utils.HashPassword(user.Password)
+	if err := s.inOrganization(ctx, id); err != nil {
+		return nil, err
+	}
+	var changedBy *primitive.ObjectID
+	if userId, ok := ctx.Value("UserId").(primitive.ObjectID); ok {
+		changedBy = &userId
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	record, err := s.repo.ScheduleDeletion(sessionCtx, id, time.Now().Add(s.deletionGracePeriod), changedBy)
 	if err != nil {
-		utils.Logger.Error("failed to hash password", "error: ", err.Error())
+		utils.Logger.Error("failed to schedule user deletion", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("user not found")
+	}
+	_, err = s.authenticationRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete sessions", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
 		return nil, err
-	} else {
-		user.Password = newPassword
-		user, err := s.repo.UpdateOne(ctx, user)
+	}
+	err = recordEvent(sessionCtx, s.outboxRepository, entities.EventUserDeleted, id, entities.NewUserView(record))
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("scheduled user deletion")
+	s.hookRunner.Notify(ctx, &entities.HookRequest{
+		Point:     entities.HookPostDeletion,
+		User:      entities.NewUserView(record),
+		ClientIP:  clientIP(ctx),
+		UserAgent: userAgent(ctx),
+	})
+	return &record.User, nil
+}
+
+// RestoreUser cancels the pending deletion of a user, which returns to the status it
+// had before. Sessions ended by the deletion stay ended.
+func (s *userService) RestoreUser(ctx context.Context, id primitive.ObjectID) (result *entities.UserView, err error) {
+	defer func() {
+		s.audit(ctx, entities.AuditUserRestored, id, nil, err)
+	}()
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return nil, err
+	}
+	if err := s.inOrganization(ctx, id); err != nil {
+		return nil, err
+	}
+	var changedBy *primitive.ObjectID
+	if userId, ok := ctx.Value("UserId").(primitive.ObjectID); ok {
+		changedBy = &userId
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	record, err := s.repo.CancelDeletion(sessionCtx, id, changedBy)
+	if err != nil {
+		utils.Logger.Error("failed to cancel user deletion", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("user not found or not pending deletion")
+	}
+	err = recordEvent(sessionCtx, s.outboxRepository, entities.EventUserRestored, id, entities.NewUserView(record))
+	if err != nil {
+		_ = session.AbortTransaction(ctx)
+		return nil, err
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("restored user")
+	view := entities.NewUserView(record)
+	return &view, nil
+}
+
+// PurgeDeletedUsers removes the users whose grace period ended together with their
+// sessions, tokens, identities, role assignments, memberships, group memberships,
+// data exports and login history. It returns the number of users removed.
+func (s *userService) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	records, err := s.repo.FindManyToPurge(ctx, time.Now(), 100)
+	if err != nil {
+		utils.Logger.Error("failed to find users to purge", "error: ", err.Error())
+		return 0, err
+	}
+	purged := 0
+	for _, record := range records {
+		if err := s.purgeUser(ctx, record.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	if purged > 0 {
+		utils.Logger.Info("purged deleted users", "count: ", purged)
+	}
+	return purged, nil
+}
+
+func (s *userService) purgeUser(ctx context.Context, id primitive.ObjectID) error {
+	session, err := s.client.StartSession()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
+		return err
+	}
+	defer session.EndSession(ctx)
+	err = session.StartTransaction()
+	if err != nil {
+		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
+		return err
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	_, err = s.authenticationRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete sessions", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.personalAccessTokenRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete personal access tokens", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.identityRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete identities", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.roleRepository.DeleteAssignmentsByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete role assignments", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.groupRepository.DeleteMembersByMemberId(sessionCtx, entities.GroupMemberUser, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete group members", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.organizationRepository.DeleteMembershipsByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete memberships", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.scimRepository.DeleteUserLinksByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete scim users", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.exportRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete data exports", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.loginRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete login attempts", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	err = s.emailChangeRepository.DeleteManyByUserId(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete email changes", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	_, err = s.repo.DeleteOneById(sessionCtx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete user", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return err
+	}
+	if err := session.CommitTransaction(ctx); err != nil {
+		utils.Logger.Error("failed to commit mongo transaction", "error: ", err.Error())
+		return err
+	}
+	s.audit(ctx, entities.AuditUserPurged, id, nil, nil)
+	return nil
+}
+
+func (s *userService) audit(ctx context.Context, eventType string, target primitive.ObjectID, metadata map[string]string, err error) {
+	s.auditService.Record(ctx, &entities.AuditEvent{
+		Type:     eventType,
+		Target:   &target,
+		Metadata: metadata,
+	}, err)
+}
+
+// GetUsers lists users for operators. Callers other than root users only see the
+// members of their active organization.
+func (s *userService) GetUsers(ctx context.Context, query *entities.UserQuery) (*entities.UserPage, error) {
+	if query.Limit == 0 {
+		query.Limit = 50
+	}
+	if claims, ok := ctx.Value("Claims").(*models.JwtCustomClaims); !ok || claims.Role != constants.Root {
+		memberships, err := s.organizationRepository.FindMembershipsByOrganizationId(ctx, activeOrganization(ctx))
 		if err != nil {
-			utils.Logger.Error("failed to update user", "error: ", err.Error())
+			utils.Logger.Error("failed to find memberships", "error: ", err.Error())
 			return nil, err
-		} else {
-			_ = session.CommitTransaction(ctx)
-			utils.Logger.Info("updated user")
-			return user, nil
+		}
+		query.UserIds = make([]primitive.ObjectID, 0, len(memberships))
+		for _, membership := range memberships {
+			query.UserIds = append(query.UserIds, membership.UserId)
+		}
+	}
+	limit := query.Limit
+	query.Limit = limit + 1
+	records, err := s.repo.FindManyByQuery(ctx, query)
+	if err != nil {
+		utils.Logger.Error("failed to find users", "error: ", err.Error())
+		return nil, err
+	}
+	result := &entities.UserPage{
+		Users: []entities.UserView{},
+	}
+	if len(records) > limit {
+		records = records[:limit]
+		result.NextCursor = records[limit-1].ID.Hex()
+	}
+	for i := range records {
+		result.Users = append(result.Users, entities.NewUserView(&records[i]))
+	}
+	return result, nil
+}
+
+func (s *userService) GetUser(ctx context.Context, id primitive.ObjectID) (*entities.UserView, error) {
+	if err := s.inOrganization(ctx, id); err != nil {
+		return nil, err
+	}
+	record, err := s.repo.FindRecordById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	result := entities.NewUserView(record)
+	return &result, nil
+}
+
+// ChangeRole sets the legacy role of a user. Only root users can grant the root role.
+func (s *userService) ChangeRole(ctx context.Context, id primitive.ObjectID, input *entities.UserRoleInput) (result *entities.UserView, err error) {
+	defer func() {
+		s.audit(ctx, entities.AuditUserRoleChanged, id, map[string]string{"role": string(input.Role)}, err)
+	}()
+	if err := s.inOrganization(ctx, id); err != nil {
+		return nil, err
+	}
+	current, err := s.repo.FindOneById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if claims, ok := ctx.Value("Claims").(*models.JwtCustomClaims); !ok || claims.Role != constants.Root {
+		if input.Role == constants.Root {
+			return nil, fmt.Errorf("only root users can grant the root role")
+		}
+		if current.Role == constants.Root {
+			return nil, fmt.Errorf("only root users can change the role of root users")
 		}
 	}
+	record, err := s.repo.UpdateRole(ctx, id, input.Role)
+	if err != nil {
+		utils.Logger.Error("failed to update role", "error: ", err.Error())
+		return nil, fmt.Errorf("user not found")
+	}
+	utils.Logger.Info("changed user role")
+	view := entities.NewUserView(record)
+	return &view, nil
 }
 
-func (s *userService) DeleteUser(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+// ChangeStatus sets the account status of a user. Any status other than active ends
+// every session of the user at once.
+func (s *userService) ChangeStatus(ctx context.Context, id primitive.ObjectID, input *entities.UserStatusInput) (result *entities.UserView, err error) {
+	defer func() {
+		s.audit(ctx, entities.AuditUserStatusChanged, id, map[string]string{"status": input.Status, "reason": input.Reason}, err)
+	}()
 	session, err := s.client.StartSession()
 	if err != nil {
 		utils.Logger.Error("failed to start mongo session", "error: ", err.Error())
@@ -152,13 +594,68 @@ func (s *userService) DeleteUser(ctx context.Context, id primitive.ObjectID) (*m
 		utils.Logger.Error("failed to start mongo transaction", "error: ", err.Error())
 		return nil, err
 	}
-	user, err := s.repo.DeleteOneById(ctx, id)
-	if err != nil {
-		utils.Logger.Error("failed to delete user", "error: ", err.Error())
+	if err := s.inOrganization(ctx, id); err != nil {
 		return nil, err
-	} else {
-		_ = session.CommitTransaction(ctx)
-		utils.Logger.Info("deleted user")
-		return user, nil
 	}
+	var changedBy *primitive.ObjectID
+	if userId, ok := ctx.Value("UserId").(primitive.ObjectID); ok {
+		if userId == id {
+			return nil, fmt.Errorf("users cannot change their own status")
+		}
+		changedBy = &userId
+	}
+	current, err := s.repo.FindRecordById(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if claims, ok := ctx.Value("Claims").(*models.JwtCustomClaims); current.Role == constants.Root && (!ok || claims.Role != constants.Root) {
+		return nil, fmt.Errorf("only root users can change the status of root users")
+	}
+	if current.Status == entities.UserPendingDeletion {
+		return nil, fmt.Errorf("user is pending deletion and must be restored first")
+	}
+	sessionCtx := mongo.NewSessionContext(ctx, session)
+	record, err := s.repo.UpdateStatus(sessionCtx, id, input.Status, input.Reason, changedBy)
+	if err != nil {
+		utils.Logger.Error("failed to update status", "error: ", err.Error())
+		_ = session.AbortTransaction(ctx)
+		return nil, fmt.Errorf("user not found")
+	}
+	if input.Status != entities.UserActive {
+		_, err = s.authenticationRepository.DeleteManyByUserId(sessionCtx, id)
+		if err != nil {
+			utils.Logger.Error("failed to delete sessions", "error: ", err.Error())
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	} else if current.Status == entities.UserPending {
+		// activating a pending account completes its verification
+		err = recordEvent(sessionCtx, s.outboxRepository, entities.EventUserVerified, id, entities.NewUserView(record))
+		if err != nil {
+			_ = session.AbortTransaction(ctx)
+			return nil, err
+		}
+	}
+	_ = session.CommitTransaction(ctx)
+	utils.Logger.Info("changed user status")
+	view := entities.NewUserView(record)
+	return &view, nil
+}
+
+// inOrganization fails unless the user is the caller or a member of the active
+// organization of the caller. Root users and internal calls are not scoped.
+func (s *userService) inOrganization(ctx context.Context, userId primitive.ObjectID) error {
+	claims, ok := ctx.Value("Claims").(*models.JwtCustomClaims)
+	if !ok || claims.Role == constants.Root || claims.UserId == userId {
+		return nil
+	}
+	organizationId := activeOrganization(ctx)
+	if organizationId.IsZero() {
+		return fmt.Errorf("user not found")
+	}
+	_, err := s.organizationRepository.FindMembership(ctx, organizationId, userId)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	return nil
 }
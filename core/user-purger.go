@@ -0,0 +1,21 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// RunUserPurger purges deleted users whose grace period ended every interval until ctx
+// is done. Failures are logged by the user service and retried on the next tick.
+func RunUserPurger(ctx context.Context, userService IUserService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = userService.PurgeDeletedUsers(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
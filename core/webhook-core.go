@@ -0,0 +1,266 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/draco121/horizon/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"shield/entities"
+	"shield/repository"
+	"shield/tokens"
+)
+
+const (
+	// webhookMaxAttempts is the number of attempts after which a delivery is dead.
+	webhookMaxAttempts = 8
+	// webhookBackoff is the delay before the second attempt; it doubles after every
+	// failed attempt up to webhookMaxBackoff.
+	webhookBackoff    = 30 * time.Second
+	webhookMaxBackoff = 6 * time.Hour
+	// webhookLease keeps a claimed delivery from other workers while it is attempted.
+	webhookLease = time.Minute
+)
+
+type IWebhookService interface {
+	CreateEndpoint(ctx context.Context, input *entities.WebhookEndpointInput) (*entities.WebhookEndpointOutput, error)
+	GetEndpoints(ctx context.Context) ([]entities.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id primitive.ObjectID) (*entities.WebhookEndpoint, error)
+	GetDeliveries(ctx context.Context, endpointId primitive.ObjectID, query *entities.WebhookDeliveryQuery) ([]entities.WebhookDelivery, error)
+	Redeliver(ctx context.Context, id primitive.ObjectID) (*entities.WebhookDelivery, error)
+	Enqueue(ctx context.Context, event *entities.OutboxEvent) error
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+type webhookService struct {
+	IWebhookService
+	repo       repository.IWebhookRepository
+	httpClient *http.Client
+	client     *mongo.Client
+}
+
+func NewWebhookService(client *mongo.Client, repository repository.IWebhookRepository) IWebhookService {
+	return &webhookService{
+		repo:       repository,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		client:     client,
+	}
+}
+
+func (s *webhookService) CreateEndpoint(ctx context.Context, input *entities.WebhookEndpointInput) (*entities.WebhookEndpointOutput, error) {
+	secret, err := tokens.GenerateSecret(entities.WebhookSecretPrefix, 32)
+	if err != nil {
+		utils.Logger.Error("failed to generate webhook secret", "error: ", err.Error())
+		return nil, err
+	}
+	endpoint, err := s.repo.InsertEndpoint(ctx, &entities.WebhookEndpoint{
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      input.Events,
+		Secret:      secret,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		utils.Logger.Error("failed to insert webhook endpoint", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("created webhook endpoint")
+	return &entities.WebhookEndpointOutput{
+		WebhookEndpoint: *endpoint,
+		Secret:          secret,
+	}, nil
+}
+
+func (s *webhookService) GetEndpoints(ctx context.Context) ([]entities.WebhookEndpoint, error) {
+	result, err := s.repo.FindEndpoints(ctx)
+	if err != nil {
+		utils.Logger.Error("failed to find webhook endpoints", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, id primitive.ObjectID) (*entities.WebhookEndpoint, error) {
+	result, err := s.repo.DeleteEndpoint(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete webhook endpoint", "error: ", err.Error())
+		return nil, fmt.Errorf("webhook endpoint not found")
+	}
+	err = s.repo.DeleteDeliveriesByEndpointId(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to delete webhook deliveries", "error: ", err.Error())
+		return nil, err
+	}
+	utils.Logger.Info("deleted webhook endpoint")
+	return result, nil
+}
+
+func (s *webhookService) GetDeliveries(ctx context.Context, endpointId primitive.ObjectID, query *entities.WebhookDeliveryQuery) ([]entities.WebhookDelivery, error) {
+	result, err := s.repo.FindDeliveries(ctx, endpointId, query.Status)
+	if err != nil {
+		utils.Logger.Error("failed to find webhook deliveries", "error: ", err.Error())
+		return nil, err
+	}
+	return result, nil
+}
+
+// Redeliver gives a delivery, typically a dead one, a new round of attempts.
+func (s *webhookService) Redeliver(ctx context.Context, id primitive.ObjectID) (*entities.WebhookDelivery, error) {
+	result, err := s.repo.Redeliver(ctx, id)
+	if err != nil {
+		utils.Logger.Error("failed to redeliver webhook", "error: ", err.Error())
+		return nil, fmt.Errorf("webhook delivery not found")
+	}
+	utils.Logger.Info("scheduled webhook redelivery")
+	return result, nil
+}
+
+// Enqueue creates a delivery of the event for every endpoint subscribed to it.
+func (s *webhookService) Enqueue(ctx context.Context, event *entities.OutboxEvent) error {
+	endpoints, err := s.repo.FindEndpointsByEvent(ctx, event.Type)
+	if err != nil {
+		utils.Logger.Error("failed to find webhook endpoints", "error: ", err.Error())
+		return err
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(&entities.WebhookPayload{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Data,
+	})
+	if err != nil {
+		utils.Logger.Error("failed to encode webhook payload", "error: ", err.Error())
+		return err
+	}
+	deliveries := make([]entities.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, entities.WebhookDelivery{
+			EndpointId:    endpoint.ID,
+			EventId:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        entities.WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+			CreatedAt:     time.Now(),
+		})
+	}
+	if err := s.repo.InsertDeliveries(ctx, deliveries); err != nil {
+		utils.Logger.Error("failed to insert webhook deliveries", "error: ", err.Error())
+		return err
+	}
+	return nil
+}
+
+// DeliverDue attempts every delivery that is due. Failed attempts are retried with
+// exponential back-off until webhookMaxAttempts, after which the delivery is dead.
+// It returns the number of attempts made.
+func (s *webhookService) DeliverDue(ctx context.Context) (int, error) {
+	attempts := 0
+	for {
+		delivery, err := s.repo.ClaimDue(ctx, time.Now(), webhookLease)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return attempts, nil
+		} else if err != nil {
+			utils.Logger.Error("failed to claim webhook delivery", "error: ", err.Error())
+			return attempts, err
+		}
+		attempts++
+		endpoint, err := s.repo.FindEndpointById(ctx, delivery.EndpointId)
+		if err != nil {
+			// the endpoint was removed after the event was dispatched
+			delivery.Status = entities.WebhookDeliveryDead
+			delivery.LastError = "endpoint not found"
+		} else {
+			s.attempt(ctx, endpoint, delivery)
+		}
+		if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+			utils.Logger.Error("failed to update webhook delivery", "error: ", err.Error())
+			return attempts, err
+		}
+	}
+}
+
+func (s *webhookService) attempt(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) {
+	delivery.Attempts++
+	statusCode, err := s.post(ctx, endpoint, delivery)
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		now := time.Now()
+		delivery.Status = entities.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = entities.WebhookDeliveryDead
+		utils.Logger.Error("webhook delivery is dead", "delivery: ", delivery.ID.Hex(), "error: ", err.Error())
+		return
+	}
+	backoff := webhookBackoff << (delivery.Attempts - 1)
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	delivery.NextAttemptAt = time.Now().Add(backoff)
+}
+
+func (s *webhookService) post(ctx context.Context, endpoint *entities.WebhookEndpoint, delivery *entities.WebhookDelivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Shield-Event", delivery.EventType)
+	request.Header.Set("Shield-Delivery", delivery.ID.Hex())
+	request.Header.Set("Shield-Signature", WebhookSignature(endpoint.Secret, time.Now(), delivery.Payload))
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint responded with %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// WebhookSignature returns the Shield-Signature header for a payload sent at the given
+// time: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<payload>">". Receivers
+// should recompute it with the endpoint secret and reject stale timestamps.
+func WebhookSignature(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RunWebhookWorker delivers due webhooks every interval until ctx is done.
+func RunWebhookWorker(ctx context.Context, webhookService IWebhookService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = webhookService.DeliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
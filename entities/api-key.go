@@ -0,0 +1,58 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ApiKeyPrefix marks organization api keys so secret scanners can find leaked ones.
+// A key reads shk_<keyId>_<secret>; only the key id is stored in clear.
+const ApiKeyPrefix = "shk_"
+
+// ApiKey belongs to an organization rather than a person, so it outlives any member.
+type ApiKey struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id"`
+	KeyId              string             `json:"keyId"`
+	OrganizationId     primitive.ObjectID `json:"organizationId"`
+	Name               string             `json:"name"`
+	SecretHash         string             `json:"-"`
+	PreviousSecretHash string             `json:"-"`
+	PreviousExpiresAt  *time.Time         `json:"previousExpiresAt,omitempty"`
+	Scopes             []string           `json:"scopes"`
+	AllowedIPs         []string           `json:"allowedIps"`
+	ExpiresAt          *time.Time         `json:"expiresAt,omitempty"`
+	LastUsedAt         *time.Time         `json:"lastUsedAt,omitempty"`
+	RotatedAt          *time.Time         `json:"rotatedAt,omitempty"`
+	CreatedAt          time.Time          `json:"createdAt"`
+}
+
+// ApiKeyInput creates a key holding the permissions listed in Scopes.
+type ApiKeyInput struct {
+	Name       string     `json:"name" binding:"required"`
+	Scopes     []string   `json:"scopes" binding:"required,min=1"`
+	AllowedIPs []string   `json:"allowedIps"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+type ApiKeyRotateInput struct {
+	// OverlapSeconds keeps the previous secret valid for this long after rotation.
+	OverlapSeconds int64 `json:"overlapSeconds"`
+}
+
+// ApiKeyOutput is only returned on creation and rotation; the key cannot be retrieved again.
+type ApiKeyOutput struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+// ApiKeyUsage is written for every attempt to authenticate with an api key.
+type ApiKeyUsage struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	KeyId          string             `json:"keyId"`
+	OrganizationId primitive.ObjectID `json:"organizationId"`
+	IP             string             `json:"ip"`
+	Allowed        bool               `json:"allowed"`
+	Reason         string             `json:"reason"`
+	CreatedAt      time.Time          `json:"createdAt"`
+}
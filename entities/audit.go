@@ -0,0 +1,78 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit event types.
+const (
+	AuditLogin                = "login"
+	AuditRefresh              = "refresh"
+	AuditLogout               = "logout"
+	AuditUserCreated          = "user.created"
+	AuditUserUpdated          = "user.updated"
+	AuditUserDeleted          = "user.deleted"
+	AuditUserRestored         = "user.restored"
+	AuditUserPurged           = "user.purged"
+	AuditUserRoleChanged      = "user.role_changed"
+	AuditUserStatusChanged    = "user.status_changed"
+	AuditEmailChangeRequested = "user.email_change_requested"
+	AuditEmailChanged         = "user.email_changed"
+	AuditEmailChangeReverted  = "user.email_change_reverted"
+	AuditRoleAssigned         = "role.assigned"
+	AuditRoleUnassigned       = "role.unassigned"
+)
+
+// Audit event outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is an entry of the append-only audit log. Every event carries the hash of
+// the one before it, so editing or removing an event breaks the chain from there on.
+type AuditEvent struct {
+	ID             primitive.ObjectID  `json:"id" bson:"_id"`
+	Sequence       int64               `json:"sequence"`
+	Type           string              `json:"type"`
+	Outcome        string              `json:"outcome"`
+	Reason         string              `json:"reason,omitempty"`
+	Actor          *primitive.ObjectID `json:"actor,omitempty"`
+	Target         *primitive.ObjectID `json:"target,omitempty"`
+	OrganizationId *primitive.ObjectID `json:"organizationId,omitempty"`
+	IP             string              `json:"ip,omitempty"`
+	UserAgent      string              `json:"userAgent,omitempty"`
+	Metadata       map[string]string   `json:"metadata,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	PreviousHash   string              `json:"previousHash"`
+	Hash           string              `json:"hash"`
+}
+
+// AuditQuery filters the audit log. Cursor is the nextCursor of the previous page.
+type AuditQuery struct {
+	Type           string     `form:"type"`
+	Outcome        string     `form:"outcome" binding:"omitempty,oneof=success failure"`
+	Actor          string     `form:"actor"`
+	Target         string     `form:"target"`
+	OrganizationId string     `form:"organizationId"`
+	From           *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To             *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor         string     `form:"cursor"`
+	Limit          int        `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
+
+// AuditVerification is the result of checking the hash chain of the audit log.
+// BrokenAt is the sequence of the first event that does not check out.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt *int64 `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
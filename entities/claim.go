@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Claim template sources. "organization.metadata.<key>" reads a key of the metadata of
+// the active organization.
+const (
+	ClaimSourceUserId               = "user.id"
+	ClaimSourceUserEmail            = "user.email"
+	ClaimSourceUserFirstName        = "user.firstname"
+	ClaimSourceUserLastName         = "user.lastname"
+	ClaimSourceUserRole             = "user.role"
+	ClaimSourceOrganizationId       = "organization.id"
+	ClaimSourceOrganizationName     = "organization.name"
+	ClaimSourceOrganizationRole     = "organization.role"
+	ClaimSourceOrganizationMetadata = "organization.metadata."
+	ClaimSourceGroups               = "groups"
+	ClaimSourcePermissions          = "permissions"
+)
+
+// ClaimTemplate adds the claim Claim to access tokens, filled from Source or, when
+// Source is empty, set to Value. Templates without an audience apply to every token;
+// a template for the audience of a token replaces the one without for the same claim.
+type ClaimTemplate struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Claim     string             `json:"claim" binding:"required"`
+	Source    string             `json:"source,omitempty"`
+	Value     string             `json:"value,omitempty"`
+	Audience  string             `json:"audience,omitempty"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
@@ -0,0 +1,13 @@
+package entities
+
+import "github.com/draco121/horizon/constants"
+
+// DirectoryUser is a user whose credentials were verified by an authentication backend.
+type DirectoryUser struct {
+	Backend   string         `json:"backend"`
+	Subject   string         `json:"subject"`
+	Email     string         `json:"email"`
+	FirstName string         `json:"firstname"`
+	LastName  string         `json:"lastname"`
+	Role      constants.Role `json:"role"`
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Prefixes of the tokens sent to the new and the old address of an email change.
+const (
+	EmailChangeConfirmPrefix = "shec_"
+	EmailChangeRevertPrefix  = "sher_"
+)
+
+// Email change statuses. A pending change is cancelled when the user requests another.
+const (
+	EmailChangePending   = "pending"
+	EmailChangeConfirmed = "confirmed"
+	EmailChangeReverted  = "reverted"
+	EmailChangeCancelled = "cancelled"
+)
+
+// EmailChange moves a user from OldEmail to NewEmail once the link sent to NewEmail is
+// followed before ExpiresAt. The link sent to OldEmail cancels the change, or undoes
+// it once confirmed, until RevertExpiresAt.
+type EmailChange struct {
+	ID              primitive.ObjectID `json:"id" bson:"_id"`
+	UserId          primitive.ObjectID `json:"userId"`
+	OldEmail        string             `json:"oldEmail"`
+	NewEmail        string             `json:"newEmail"`
+	Status          string             `json:"status"`
+	ConfirmHash     string             `json:"-"`
+	RevertHash      string             `json:"-"`
+	ExpiresAt       time.Time          `json:"expiresAt"`
+	RevertExpiresAt time.Time          `json:"revertExpiresAt"`
+	ConfirmedAt     *time.Time         `json:"confirmedAt,omitempty"`
+	RevertedAt      *time.Time         `json:"revertedAt,omitempty"`
+	CreatedAt       time.Time          `json:"createdAt"`
+}
+
+// EmailChangeInput requests an email change. The current password is required so a
+// stolen session alone cannot take the account over.
+type EmailChangeInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// EmailChangeTokenInput carries the token of a confirmation or revert link.
+type EmailChangeTokenInput struct {
+	Token string `json:"token" binding:"required"`
+}
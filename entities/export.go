@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Data export statuses.
+const (
+	DataExportPending   = "pending"
+	DataExportCompleted = "completed"
+	DataExportFailed    = "failed"
+)
+
+// DataExport is a request of a user for a copy of the data shield stores about them.
+// The archive is assembled in the background and kept until ExpiresAt.
+type DataExport struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	UserId      primitive.ObjectID `json:"userId"`
+	Status      string             `json:"status"`
+	Error       string             `json:"error,omitempty"`
+	Size        int                `json:"size,omitempty"`
+	Archive     []byte             `json:"-"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	CompletedAt *time.Time         `json:"completedAt,omitempty"`
+	ExpiresAt   time.Time          `json:"expiresAt"`
+}
+
+// UserDataArchive is the content of a data export. Secrets such as password and token
+// hashes are left out.
+type UserDataArchive struct {
+	GeneratedAt          time.Time             `json:"generatedAt"`
+	Profile              UserView              `json:"profile"`
+	Sessions             []Session             `json:"sessions"`
+	LoginHistory         []LoginAttempt        `json:"loginHistory"`
+	Identities           []Identity            `json:"identities"`
+	PersonalAccessTokens []PersonalAccessToken `json:"personalAccessTokens"`
+	Memberships          []Membership          `json:"memberships"`
+	RoleAssignments      []RoleAssignment      `json:"roleAssignments"`
+	GroupMemberships     []GroupMember         `json:"groupMemberships"`
+}
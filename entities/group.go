@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of members a group can have.
+const (
+	GroupMemberUser  = "user"
+	GroupMemberGroup = "group"
+)
+
+// Group is a set of users and other groups of an organization. Every member of a
+// group, direct or through nested groups, is granted the roles of the group.
+type Group struct {
+	ID             primitive.ObjectID   `json:"id" bson:"_id"`
+	OrganizationId primitive.ObjectID   `json:"organizationId"`
+	Name           string               `json:"name" binding:"required"`
+	Description    string               `json:"description"`
+	ExternalId     string               `json:"externalId,omitempty"`
+	RoleIds        []primitive.ObjectID `json:"roleIds"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	UpdatedAt      time.Time            `json:"updatedAt"`
+}
+
+// GroupMember is an edge from a group to one of its direct members.
+type GroupMember struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	GroupId    primitive.ObjectID `json:"groupId"`
+	MemberType string             `json:"memberType"`
+	MemberId   primitive.ObjectID `json:"memberId"`
+	CreatedAt  time.Time          `json:"createdAt"`
+}
+
+type GroupMemberInput struct {
+	MemberType string             `json:"memberType" binding:"required,oneof=user group"`
+	MemberId   primitive.ObjectID `json:"memberId" binding:"required"`
+}
@@ -0,0 +1,33 @@
+package entities
+
+// Hook points. Pre hooks may deny the action they precede; post hooks are only notified.
+const (
+	HookPreRegistration = "pre-registration"
+	HookPreToken        = "pre-token"
+	HookPostDeletion    = "post-deletion"
+)
+
+// Triggers of the pre-token hook.
+const (
+	HookTriggerLogin              = "login"
+	HookTriggerRefresh            = "refresh"
+	HookTriggerSwitchOrganization = "switch-organization"
+)
+
+// HookRequest is sent to the hooks of a hook point. User is the user being registered,
+// logged in or deleted; it has no id yet for pre-registration hooks.
+type HookRequest struct {
+	Point     string   `json:"point"`
+	Trigger   string   `json:"trigger,omitempty"`
+	User      UserView `json:"user"`
+	ClientIP  string   `json:"clientIp,omitempty"`
+	UserAgent string   `json:"userAgent,omitempty"`
+}
+
+// HookResponse is the answer of a hook. Claims returned by pre-token hooks are added
+// to the access token under "ext"; they are ignored at the other hook points.
+type HookResponse struct {
+	Deny   bool                   `json:"deny"`
+	Reason string                 `json:"reason,omitempty"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
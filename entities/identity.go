@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/draco121/horizon/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity links an account at an external identity provider to a local user.
+type Identity struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserId    primitive.ObjectID `json:"userId"`
+	Provider  string             `json:"provider"`
+	Subject   string             `json:"subject"`
+	Email     string             `json:"email"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// FederatedLoginOutput is the result of an external login callback. It carries tokens
+// for a login, or the linked identity when the flow was started to link an account.
+type FederatedLoginOutput struct {
+	*models.LoginOutput
+	Identity *Identity `json:"identity,omitempty"`
+}
+
+// FederatedRedirect starts an external login at URL. Binding has to come back with the
+// callback, so the flow can only be completed by the browser that started it.
+type FederatedRedirect struct {
+	URL     string
+	Binding string
+}
+
+type IdentitiesOutput struct {
+	Identities  []Identity `json:"identities"`
+	HasPassword bool       `json:"hasPassword"`
+}
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InvitationPrefix marks organization invite tokens.
+const InvitationPrefix = "shi_"
+
+// Invitation offers a role in an organization to whoever controls Email. Emails are
+// stored lower cased so the invitee may use any casing when accepting.
+type Invitation struct {
+	ID             primitive.ObjectID  `json:"id" bson:"_id"`
+	OrganizationId primitive.ObjectID  `json:"organizationId"`
+	Email          string              `json:"email"`
+	Role           string              `json:"role"`
+	TokenHash      string              `json:"-"`
+	InvitedBy      primitive.ObjectID  `json:"invitedBy"`
+	ExpiresAt      time.Time           `json:"expiresAt"`
+	AcceptedAt     *time.Time          `json:"acceptedAt,omitempty"`
+	AcceptedBy     *primitive.ObjectID `json:"acceptedBy,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt"`
+}
+
+type InvitationInput struct {
+	Email     string     `json:"email" binding:"required,email"`
+	Role      string     `json:"role" binding:"required,oneof=owner admin member"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// InvitationAcceptInput accepts an invitation. The profile fields are only needed
+// when no account exists for the invited email yet.
+type InvitationAcceptInput struct {
+	Token     string `json:"token" binding:"required"`
+	FirstName string `json:"firstname"`
+	LastName  string `json:"lastname"`
+	Password  string `json:"password"`
+}
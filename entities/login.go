@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Login methods recorded in the login history.
+const (
+	LoginMethodPassword = "password"
+	LoginMethodRefresh  = "refresh"
+)
+
+// LoginAttempt is an entry of the sign-in activity of a user. NewDevice and NewLocation
+// flag attempts from a browser and operating system, or a network, the user had not
+// signed in from before. Networks are /24 for IPv4 and /48 for IPv6.
+type LoginAttempt struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	UserId      primitive.ObjectID `json:"-"`
+	Method      string             `json:"method"`
+	Success     bool               `json:"success"`
+	Reason      string             `json:"reason,omitempty"`
+	IP          string             `json:"ip"`
+	Network     string             `json:"-"`
+	UserAgent   string             `json:"userAgent"`
+	Browser     string             `json:"browser,omitempty"`
+	OS          string             `json:"os,omitempty"`
+	Device      string             `json:"device"`
+	Fingerprint string             `json:"-"`
+	NewDevice   bool               `json:"newDevice"`
+	NewLocation bool               `json:"newLocation"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	ExpiresAt   time.Time          `json:"-"`
+}
+
+type LoginQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type LoginPage struct {
+	Logins     []LoginAttempt `json:"logins"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
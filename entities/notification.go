@@ -0,0 +1,8 @@
+package entities
+
+// Notification is a message shield sends to a user out of band, usually by email.
+type Notification struct {
+	To      string
+	Subject string
+	Body    string
+}
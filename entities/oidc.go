@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdentityProvider is an external OpenID Connect provider users can sign in with.
+type IdentityProvider struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Name         string             `json:"name" binding:"required"`
+	Issuer       string             `json:"issuer" binding:"required"`
+	ClientId     string             `json:"clientId" binding:"required"`
+	ClientSecret string             `json:"clientSecret,omitempty"`
+	RedirectURL  string             `json:"redirectUrl" binding:"required"`
+	Scopes       []string           `json:"scopes"`
+	CreatedAt    time.Time          `json:"createdAt"`
+}
+
+// OidcLoginState is kept between the authorization redirect and the callback.
+type OidcLoginState struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	State        string             `json:"state"`
+	Nonce        string             `json:"nonce"`
+	CodeVerifier string             `json:"codeVerifier"`
+	Provider     string             `json:"provider"`
+	LinkUserId   primitive.ObjectID `json:"linkUserId"`
+	BindingHash  string             `json:"-"`
+	ExpiresAt    time.Time          `json:"expiresAt"`
+}
+
+type OidcCallbackInput struct {
+	Code  string `form:"code" binding:"required"`
+	State string `form:"state" binding:"required"`
+}
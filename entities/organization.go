@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Roles a member can hold within an organization, from most to least privileged.
+const (
+	OrganizationOwner  = "owner"
+	OrganizationAdmin  = "admin"
+	OrganizationMember = "member"
+)
+
+type Organization struct {
+	ID   primitive.ObjectID `json:"id" bson:"_id"`
+	Name string             `json:"name" binding:"required"`
+	// Metadata can be mapped into access tokens by claim templates.
+	Metadata  map[string]string  `json:"metadata,omitempty"`
+	CreatedBy primitive.ObjectID `json:"createdBy"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+type Membership struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	OrganizationId primitive.ObjectID `json:"organizationId"`
+	UserId         primitive.ObjectID `json:"userId"`
+	Role           string             `json:"role"`
+	// Suspended memberships grant no access to the organization. SCIM suspends the
+	// members its identity provider deactivates.
+	Suspended bool      `json:"suspended"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MembershipInput changes the role of a member. Users only become members by accepting
+// an invitation.
+type MembershipInput struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity events captured in the outbox.
+const (
+	EventUserCreated      = "user.created"
+	EventUserVerified     = "user.verified"
+	EventUserEmailChanged = "user.email_changed"
+	EventUserDeleted      = "user.deleted"
+	EventUserRestored     = "user.restored"
+	EventSessionCreated   = "session.created"
+	EventSessionEnded     = "session.ended"
+	EventSessionsRevoked  = "sessions.revoked"
+)
+
+// OutboxEvent is an event written in the same transaction as the change it describes
+// and dispatched afterwards. Data is the JSON encoded payload.
+type OutboxEvent struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Type         string             `json:"type"`
+	Subject      primitive.ObjectID `json:"subject"`
+	Data         []byte             `json:"-"`
+	CreatedAt    time.Time          `json:"createdAt"`
+	DispatchedAt *time.Time         `json:"dispatchedAt,omitempty"`
+}
+
+// EventMessage is the body published to the message bus. Subject is the user the event
+// is about; events of the same subject are published in order.
+type EventMessage struct {
+	ID        primitive.ObjectID `json:"id"`
+	Type      string             `json:"type"`
+	Subject   primitive.ObjectID `json:"subject"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      json.RawMessage    `json:"data"`
+}
+
+func NewEventMessage(event *OutboxEvent) *EventMessage {
+	return &EventMessage{
+		ID:        event.ID,
+		Type:      event.Type,
+		Subject:   event.Subject,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Data,
+	}
+}
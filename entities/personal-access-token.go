@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PersonalAccessTokenPrefix marks personal access tokens so secret scanners can find leaked ones.
+const PersonalAccessTokenPrefix = "shp_"
+
+type PersonalAccessToken struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	UserId     primitive.ObjectID `json:"userId"`
+	Name       string             `json:"name"`
+	Hint       string             `json:"hint"`
+	TokenHash  string             `json:"-"`
+	Scopes     []string           `json:"scopes"`
+	ExpiresAt  *time.Time         `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time         `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt"`
+}
+
+// PersonalAccessTokenInput creates a token holding the permissions of its owner that the
+// scopes cover, such as "profile:read" or "users:*".
+type PersonalAccessTokenInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// PersonalAccessTokenOutput is only returned on creation; the token cannot be retrieved again.
+type PersonalAccessTokenOutput struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
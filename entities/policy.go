@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// Policy is one version of a named CEL rule. Updating a policy stores a new version;
+// only the latest version of each name is evaluated.
+type Policy struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name" binding:"required"`
+	Version     int                `json:"version"`
+	Description string             `json:"description"`
+	// Actions limits the policy to matching actions, with the same wildcards as permissions.
+	Actions    []string           `json:"actions" binding:"required"`
+	Effect     string             `json:"effect" binding:"required,oneof=allow deny"`
+	Expression string             `json:"expression" binding:"required"`
+	Disabled   bool               `json:"disabled"`
+	CreatedBy  primitive.ObjectID `json:"createdBy"`
+	CreatedAt  time.Time          `json:"createdAt"`
+}
+
+// AuthorizationInput is a superset of the horizon authorization request, so horizon
+// clients can call the decision endpoint unchanged.
+type AuthorizationInput struct {
+	Token    string                 `json:"token"`
+	Actions  []string               `json:"actions" binding:"required"`
+	Resource map[string]interface{} `json:"resource"`
+	Context  map[string]interface{} `json:"context"`
+}
+
+// AuthorizationDecision answers an AuthorizationInput. Reasons are left out for callers
+// not allowed to see the policies.
+type AuthorizationDecision struct {
+	Grant   constants.Grant    `json:"grant"`
+	UserId  primitive.ObjectID `json:"userId"`
+	Allowed bool               `json:"allowed"`
+	Reasons []string           `json:"reasons,omitempty"`
+}
+
+// PolicyDryRunInput evaluates a request as if Policy were saved.
+type PolicyDryRunInput struct {
+	Policy Policy             `json:"policy"`
+	Claims map[string]string  `json:"claims"`
+	Input  AuthorizationInput `json:"input"`
+}
@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Profile holds the profile attributes every user has.
+type Profile struct {
+	DisplayName string `json:"displayName,omitempty" binding:"omitempty,max=100"`
+	Locale      string `json:"locale,omitempty" binding:"omitempty,bcp47_language_tag"`
+	Timezone    string `json:"timezone,omitempty" binding:"omitempty,timezone"`
+	AvatarURL   string `json:"avatarUrl,omitempty" binding:"omitempty,url"`
+	Phone       string `json:"phone,omitempty" binding:"omitempty,e164"`
+}
+
+// ProfileDocument is the part of a user that JSON Merge Patch requests apply to.
+// Password is write-only: it is always empty in the document being patched.
+type ProfileDocument struct {
+	FirstName  string                 `json:"firstname"`
+	LastName   string                 `json:"lastname"`
+	Profile    Profile                `json:"profile"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Password   string                 `json:"password,omitempty"`
+}
+
+// RegistrationInput is the body of a self-service registration.
+type RegistrationInput struct {
+	Email      string                 `json:"email" binding:"required,email"`
+	Password   string                 `json:"password" binding:"required"`
+	FirstName  string                 `json:"firstname"`
+	LastName   string                 `json:"lastname"`
+	Profile    Profile                `json:"profile"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// ProfileSchema is the JSON Schema custom attributes are validated against. AdminOnly
+// lists the attributes only operators may set; users may edit the others. Each
+// organization has its own schema; the one without OrganizationId covers the instance.
+type ProfileSchema struct {
+	OrganizationId primitive.ObjectID `json:"organizationId" bson:"-"`
+	Schema         json.RawMessage    `json:"schema" binding:"required"`
+	AdminOnly      []string           `json:"adminOnly"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+}
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Permissions checked by the routes shield serves itself.
+const (
+	PermissionAll                    = "*"
+	PermissionProfileRead            = "profile:read"
+	PermissionProfileWrite           = "profile:write"
+	PermissionUsersDelete            = "users:delete"
+	PermissionRolesManage            = "roles:manage"
+	PermissionTokenExchangeManage    = "token-exchange:manage"
+	PermissionIdentityProviderManage = "identity-providers:manage"
+	PermissionApiKeysManage          = "api-keys:manage"
+	PermissionPoliciesManage         = "policies:manage"
+	PermissionGroupsManage           = "groups:manage"
+	PermissionScimManage             = "scim:manage"
+	PermissionUsersRead              = "users:read"
+	PermissionUsersManage            = "users:manage"
+	PermissionAuditRead              = "audit:read"
+	PermissionWebhooksManage         = "webhooks:manage"
+	PermissionClaimsManage           = "claims:manage"
+)
+
+// Role is a named set of permissions. System roles mirror the legacy constants.Role
+// values carried on every user and cannot be deleted.
+type Role struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name" binding:"required"`
+	Description string             `json:"description"`
+	Permissions []string           `json:"permissions"`
+	System      bool               `json:"system"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// RoleAssignment grants a role to a user in addition to their legacy role.
+type RoleAssignment struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserId    primitive.ObjectID `json:"userId"`
+	RoleId    primitive.ObjectID `json:"roleId"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+type RoleAssignmentInput struct {
+	RoleId primitive.ObjectID `json:"roleId" binding:"required"`
+}
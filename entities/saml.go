@@ -0,0 +1,50 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SamlConnection is an enterprise SAML identity provider users can sign in with.
+type SamlConnection struct {
+	ID               primitive.ObjectID        `json:"id" bson:"_id"`
+	Name             string                    `json:"name" binding:"required"`
+	IdpMetadata      string                    `json:"idpMetadata" binding:"required"`
+	AttributeMapping SamlAttributeMapping      `json:"attributeMapping"`
+	RoleMapping      map[string]constants.Role `json:"roleMapping"`
+	DefaultRole      constants.Role            `json:"defaultRole"`
+	// VerifiedDomains are the email domains the IdP is trusted to assert. Identities
+	// with an email in another domain are only accepted once linked to a user.
+	VerifiedDomains []string  `json:"verifiedDomains"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// SamlAttributeMapping names the assertion attributes holding each user field.
+// An empty Email falls back to the assertion NameID.
+type SamlAttributeMapping struct {
+	Email     string `json:"email"`
+	FirstName string `json:"firstname"`
+	LastName  string `json:"lastname"`
+	Role      string `json:"role"`
+}
+
+// SamlLoginState ties an AuthnRequest to the RelayState it was sent with.
+type SamlLoginState struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	State      string             `json:"state"`
+	RequestId  string             `json:"requestId"`
+	Connection string             `json:"connection"`
+	LinkUserId primitive.ObjectID `json:"linkUserId"`
+	// BindingHash ties the state to the browser that started the flow.
+	BindingHash string    `json:"-"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// SamlAssertion records a consumed assertion id so it cannot be replayed.
+type SamlAssertion struct {
+	ID         string    `json:"id" bson:"_id"`
+	Connection string    `json:"connection"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
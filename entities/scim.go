@@ -0,0 +1,127 @@
+package entities
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ScimTokenPrefix marks the bearer tokens identity providers use to call the SCIM API.
+const ScimTokenPrefix = "shs_"
+
+const (
+	ScimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimGroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimPatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ScimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ScimToken authenticates the SCIM client of an organization.
+type ScimToken struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	OrganizationId primitive.ObjectID `json:"organizationId"`
+	Name           string             `json:"name"`
+	Hint           string             `json:"hint"`
+	TokenHash      string             `json:"-"`
+	LastUsedAt     *time.Time         `json:"lastUsedAt,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+}
+
+type ScimTokenInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// ScimTokenOutput is only returned on creation; the token cannot be retrieved again.
+type ScimTokenOutput struct {
+	ScimToken
+	Token string `json:"token"`
+}
+
+// ScimUserLink holds the provisioning state of a user that has no place on the user
+// itself. Provisioned is set when the user was created through SCIM rather than linked.
+type ScimUserLink struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	OrganizationId primitive.ObjectID `json:"organizationId"`
+	UserId         primitive.ObjectID `json:"userId"`
+	ExternalId     string             `json:"externalId"`
+	Active         bool               `json:"active"`
+	Provisioned    bool               `json:"provisioned"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+}
+
+type ScimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version,omitempty"`
+}
+
+type ScimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type ScimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type ScimUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalId  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        ScimName    `json:"name"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []ScimEmail `json:"emails,omitempty"`
+	Password    string      `json:"password,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Meta        *ScimMeta   `json:"meta,omitempty"`
+}
+
+type ScimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type ScimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalId  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []ScimMember `json:"members"`
+	Meta        *ScimMeta    `json:"meta,omitempty"`
+}
+
+type ScimListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type ScimPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+type ScimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []ScimPatchOperation `json:"Operations"`
+}
+
+// ScimListQuery holds the query parameters of a SCIM list request. StartIndex is one based.
+type ScimListQuery struct {
+	Filter     string `form:"filter"`
+	StartIndex int    `form:"startIndex"`
+	Count      *int   `form:"count"`
+}
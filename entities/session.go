@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"encoding/json"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/draco121/horizon/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session extends the horizon session with the organization the session is acting in
+// and the audience its access tokens are issued for.
+type Session struct {
+	models.Session `bson:",inline"`
+	OrganizationId primitive.ObjectID `json:"organizationId"`
+	Audience       string             `json:"audience,omitempty"`
+}
+
+// AccessTokenClaims are the claims of the access tokens shield issues. They embed the
+// horizon claims, so horizon's jwt.VerifyJwtToken still accepts these tokens. Ext holds
+// the claims added by pre-token hooks and Mapped those rendered from claim templates.
+type AccessTokenClaims struct {
+	models.JwtCustomClaims
+	OrganizationId   primitive.ObjectID     `json:"organizationId"`
+	OrganizationRole string                 `json:"organizationRole,omitempty"`
+	Groups           []primitive.ObjectID   `json:"groups,omitempty"`
+	Permissions      []string               `json:"permissions,omitempty"`
+	Ext              map[string]interface{} `json:"ext,omitempty"`
+	Mapped           map[string]interface{} `json:"-"`
+	jwt.StandardClaims
+}
+
+// MarshalJSON adds the mapped claims next to the fixed ones. Mapped claims never
+// replace a fixed claim.
+func (c AccessTokenClaims) MarshalJSON() ([]byte, error) {
+	type fixed AccessTokenClaims
+	payload, err := json.Marshal(fixed(c))
+	if err != nil || len(c.Mapped) == 0 {
+		return payload, err
+	}
+	claims := map[string]interface{}{}
+	err = json.Unmarshal(payload, &claims)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range c.Mapped {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	return json.Marshal(claims)
+}
+
+// Principal is the caller resolved from a token. OrganizationId is set for callers bound
+// to an organization, such as api keys, which act in it and hold exactly their scopes.
+// Scopes is nil for session tokens; other tokens only hold the permissions of their user
+// that their scopes cover.
+type Principal struct {
+	models.JwtCustomClaims
+	OrganizationId primitive.ObjectID
+	Scopes         []string
+}
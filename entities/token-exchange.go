@@ -0,0 +1,88 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/draco121/horizon/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	AccessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	JwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeClient is a client allowed to use the token exchange grant and the
+// audiences and scopes it may request.
+type TokenExchangeClient struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	ClientId    string             `json:"clientId"`
+	SecretHash  string             `json:"-"`
+	Audiences   []string           `json:"audiences"`
+	Scopes      []string           `json:"scopes"`
+	AllowActors bool               `json:"allowActors"`
+	CreatedAt   time.Time          `json:"createdAt"`
+}
+
+type TokenExchangeClientInput struct {
+	ClientId    string   `json:"clientId" binding:"required"`
+	Audiences   []string `json:"audiences" binding:"required"`
+	Scopes      []string `json:"scopes"`
+	AllowActors bool     `json:"allowActors"`
+}
+
+type TokenExchangeClientOutput struct {
+	TokenExchangeClient
+	ClientSecret string `json:"clientSecret"`
+}
+
+type TokenExchangeInput struct {
+	GrantType          string `form:"grant_type" binding:"required"`
+	ClientId           string `form:"client_id"`
+	ClientSecret       string `form:"client_secret"`
+	SubjectToken       string `form:"subject_token" binding:"required"`
+	SubjectTokenType   string `form:"subject_token_type" binding:"required"`
+	ActorToken         string `form:"actor_token"`
+	ActorTokenType     string `form:"actor_token_type"`
+	Audience           string `form:"audience"`
+	Scope              string `form:"scope"`
+	RequestedTokenType string `form:"requested_token_type"`
+}
+
+type TokenExchangeOutput struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// ActorClaim identifies the party acting on behalf of the subject, see RFC 8693 section 4.1.
+type ActorClaim struct {
+	Subject string      `json:"sub"`
+	Email   string      `json:"email,omitempty"`
+	Actor   *ActorClaim `json:"act,omitempty"`
+}
+
+// ExchangedTokenClaims embeds the regular custom claims so exchanged tokens are
+// accepted anywhere a login token is.
+type ExchangedTokenClaims struct {
+	models.JwtCustomClaims
+	Scope    string      `json:"scope,omitempty"`
+	ClientId string      `json:"client_id,omitempty"`
+	Actor    *ActorClaim `json:"act,omitempty"`
+	jwt.StandardClaims
+}
+
+// TokenExchange is the audit record written for every issued exchange token.
+type TokenExchange struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id"`
+	ClientId  string              `json:"clientId"`
+	SubjectId primitive.ObjectID  `json:"subjectId"`
+	ActorId   *primitive.ObjectID `json:"actorId,omitempty"`
+	Audience  string              `json:"audience"`
+	Scope     string              `json:"scope"`
+	CreatedAt time.Time           `json:"createdAt"`
+}
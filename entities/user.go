@@ -0,0 +1,105 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/draco121/horizon/constants"
+	"github.com/draco121/horizon/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Account statuses. Users stored before statuses existed have none and are active.
+const (
+	UserActive    = "active"
+	UserSuspended = "suspended"
+	UserDisabled  = "disabled"
+	UserPending   = "pending"
+	// UserPendingDeletion marks a deleted user that can still be restored until PurgeAt.
+	UserPendingDeletion = "pending-deletion"
+)
+
+// UserRecord is a user as stored, with the fields shield keeps next to the horizon ones.
+type UserRecord struct {
+	models.User     `bson:",inline"`
+	Status          string              `json:"status"`
+	StatusReason    string              `json:"statusReason"`
+	StatusChangedAt *time.Time          `json:"statusChangedAt"`
+	StatusChangedBy *primitive.ObjectID `json:"statusChangedBy"`
+	// StatusBeforeDeletion is the status a restored user returns to.
+	StatusBeforeDeletion string     `json:"statusBeforeDeletion"`
+	PurgeAt              *time.Time `json:"purgeAt"`
+	// EmailVerifiedAt is when the user last proved control of Email.
+	EmailVerifiedAt *time.Time             `json:"emailVerifiedAt"`
+	Profile         Profile                `json:"profile"`
+	Attributes      map[string]interface{} `json:"attributes"`
+}
+
+// UserView is the representation of a user returned to operators. It leaves out the
+// password hash.
+type UserView struct {
+	ID              primitive.ObjectID     `json:"id"`
+	Email           string                 `json:"email"`
+	FirstName       string                 `json:"firstname"`
+	LastName        string                 `json:"lastname"`
+	Role            constants.Role         `json:"role"`
+	Status          string                 `json:"status"`
+	StatusReason    string                 `json:"statusReason,omitempty"`
+	StatusChangedAt *time.Time             `json:"statusChangedAt,omitempty"`
+	StatusChangedBy *primitive.ObjectID    `json:"statusChangedBy,omitempty"`
+	PurgeAt         *time.Time             `json:"purgeAt,omitempty"`
+	EmailVerifiedAt *time.Time             `json:"emailVerifiedAt,omitempty"`
+	Profile         Profile                `json:"profile"`
+	Attributes      map[string]interface{} `json:"attributes,omitempty"`
+	CreatedAt       time.Time              `json:"createdAt"`
+}
+
+func NewUserView(record *UserRecord) UserView {
+	status := record.Status
+	if status == "" {
+		status = UserActive
+	}
+	return UserView{
+		ID:              record.ID,
+		Email:           record.Email,
+		FirstName:       record.FirstName,
+		LastName:        record.LastName,
+		Role:            record.Role,
+		Status:          status,
+		StatusReason:    record.StatusReason,
+		StatusChangedAt: record.StatusChangedAt,
+		StatusChangedBy: record.StatusChangedBy,
+		PurgeAt:         record.PurgeAt,
+		EmailVerifiedAt: record.EmailVerifiedAt,
+		Profile:         record.Profile,
+		Attributes:      record.Attributes,
+		CreatedAt:       record.ID.Timestamp(),
+	}
+}
+
+// UserQuery filters the admin user list. Q matches a prefix of the email, first name
+// or last name. Cursor is the nextCursor of the previous page.
+type UserQuery struct {
+	Role          string     `form:"role" binding:"omitempty,oneof=root tenant"`
+	Status        string     `form:"status" binding:"omitempty,oneof=active suspended disabled pending pending-deletion"`
+	CreatedAfter  *time.Time `form:"createdAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"createdBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+	Q             string     `form:"q"`
+	Cursor        string     `form:"cursor"`
+	Limit         int        `form:"limit" binding:"omitempty,min=1,max=200"`
+	// UserIds restricts the list to these users when set. It is not bound from the query.
+	UserIds []primitive.ObjectID `form:"-"`
+}
+
+type UserPage struct {
+	Users      []UserView `json:"users"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type UserRoleInput struct {
+	Role constants.Role `json:"role" binding:"required,oneof=root tenant"`
+}
+
+type UserStatusInput struct {
+	Status string `json:"status" binding:"required,oneof=active suspended disabled pending"`
+	Reason string `json:"reason" binding:"required"`
+}
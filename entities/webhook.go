@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookSecretPrefix marks webhook signing secrets.
+const WebhookSecretPrefix = "whsec_"
+
+// Webhook delivery statuses. Dead deliveries exhausted their attempts and stay in the
+// store until they are redelivered.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookEndpoint receives the events matching Events, where "*" matches every event.
+type WebhookEndpoint struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	URL         string             `json:"url"`
+	Description string             `json:"description,omitempty"`
+	Events      []string           `json:"events"`
+	Secret      string             `json:"-"`
+	CreatedAt   time.Time          `json:"createdAt"`
+}
+
+type WebhookEndpointInput struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events" binding:"required,min=1"`
+}
+
+// WebhookEndpointOutput is only returned on creation; the secret cannot be retrieved again.
+type WebhookEndpointOutput struct {
+	WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is one event to be delivered to one endpoint. Payload is the exact
+// body sent on every attempt.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id"`
+	EndpointId     primitive.ObjectID `json:"endpointId"`
+	EventId        primitive.ObjectID `json:"eventId"`
+	EventType      string             `json:"eventType"`
+	Payload        []byte             `json:"-"`
+	Status         string             `json:"status"`
+	Attempts       int                `json:"attempts"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt"`
+	LastError      string             `json:"lastError,omitempty"`
+	LastStatusCode int                `json:"lastStatusCode,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	DeliveredAt    *time.Time         `json:"deliveredAt,omitempty"`
+}
+
+type WebhookDeliveryQuery struct {
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+}
+
+// WebhookPayload is the body posted to endpoints.
+type WebhookPayload struct {
+	ID        primitive.ObjectID `json:"id"`
+	Type      string             `json:"type"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      json.RawMessage    `json:"data"`
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"github.com/draco121/horizon/utils"
 	"os"
+	"strconv"
+	"time"
 
 	"shield/controllers"
 	"shield/core"
+	"shield/middlewares"
 	"shield/repository"
 	"shield/routes"
 
@@ -21,12 +25,121 @@ func RunApp() {
 	db := client.Database("authentication-service")
 	authRepo := repository.NewAuthenticationRepository(db)
 	userRepo := repository.NewUserRepository(db)
-	userService := core.NewUserService(client, userRepo)
-	authService := core.NewAuthenticationService(client, authRepo, userRepo)
-	controller := controllers.NewControllers(authService, userService)
+	identityRepo := repository.NewIdentityRepository(db)
+	patRepo := repository.NewPersonalAccessTokenRepository(db)
+	apiKeyRepo := repository.NewApiKeyRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	policyRepo := repository.NewPolicyRepository(db)
+	tokenExchangeRepo := repository.NewTokenExchangeRepository(db)
+	oidcRepo := repository.NewOidcRepository(db)
+	samlRepo := repository.NewSamlRepository(db)
+	organizationRepo := repository.NewOrganizationRepository(db)
+	invitationRepo := repository.NewInvitationRepository(db)
+	groupRepo := repository.NewGroupRepository(db)
+	scimRepo := repository.NewScimRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	loginRepo := repository.NewLoginRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	claimRepo := repository.NewClaimRepository(db)
+	profileRepo := repository.NewProfileRepository(db)
+	emailChangeRepo := repository.NewEmailChangeRepository(db)
+	if err := userRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := exportRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := auditRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := loginRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := outboxRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := webhookRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := claimRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := emailChangeRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := apiKeyRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := organizationRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := oidcRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := samlRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := invitationRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := policyRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := scimRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := patRepo.EnsureIndexes(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	auditService := core.NewAuditService(client, auditRepo)
+	loginHistoryService := core.NewLoginHistoryService(client, loginRepo, loadDuration("LOGIN_HISTORY_RETENTION", 90*24*time.Hour))
+	// in-process hooks are registered on hookRunner here, next to the configured HTTP hooks
+	hookRunner := loadHookRunner()
+	profileService := core.NewProfileService(client, profileRepo)
+	userService := core.NewUserService(client, userRepo, organizationRepo, authRepo, patRepo, identityRepo, roleRepo, groupRepo, scimRepo, exportRepo, loginRepo, outboxRepo, emailChangeRepo, auditService, hookRunner, profileService, loadDuration("USER_DELETION_GRACE_PERIOD", 30*24*time.Hour))
+	go core.RunUserPurger(context.Background(), userService, loadDuration("USER_PURGE_INTERVAL", time.Hour))
+	apiKeyService := core.NewApiKeyService(client, apiKeyRepo, organizationRepo)
+	roleService := core.NewRoleService(client, roleRepo, userRepo, groupRepo, auditService)
+	if err := roleService.EnsureSystemRoles(context.Background()); err != nil {
+		utils.Logger.Fatal(err)
+	}
+	claimsMaxSize, _ := strconv.Atoi(os.Getenv("CLAIMS_MAX_SIZE"))
+	claimService := core.NewClaimService(client, claimRepo, organizationRepo, claimsMaxSize)
+	authService := core.NewAuthenticationService(client, authRepo, userRepo, userService, identityRepo, patRepo, organizationRepo, groupRepo, roleService, apiKeyService, auditService, loginHistoryService, outboxRepo, hookRunner, claimService, loadAuthenticationBackends()...)
+	tokenExchangeService := core.NewTokenExchangeService(client, tokenExchangeRepo, authService)
+	oidcService := core.NewOidcService(client, oidcRepo, identityRepo, userRepo, userService, authService)
+	samlKey, samlCertificate := loadSamlKeyPair()
+	samlService := core.NewSamlService(client, samlRepo, identityRepo, userRepo, userService, authService, os.Getenv("BASE_URL"), samlKey, samlCertificate)
+	identityService := core.NewIdentityService(client, identityRepo, userRepo)
+	patService := core.NewPersonalAccessTokenService(client, patRepo)
+	policyService := core.NewPolicyService(client, policyRepo, authService, roleService)
+	organizationService := core.NewOrganizationService(client, organizationRepo)
+	notifier := loadNotifier()
+	invitationService := core.NewInvitationService(client, invitationRepo, organizationRepo, userRepo, userService, notifier, os.Getenv("INVITATION_URL"))
+	emailChangeService := core.NewEmailChangeService(client, emailChangeRepo, userRepo, authService, outboxRepo, auditService, notifier, core.EmailChangeConfig{
+		ConfirmURL: os.Getenv("EMAIL_CHANGE_CONFIRM_URL"),
+		RevertURL:  os.Getenv("EMAIL_CHANGE_REVERT_URL"),
+		TTL:        loadDuration("EMAIL_CHANGE_TTL", 24*time.Hour),
+		RevertTTL:  loadDuration("EMAIL_CHANGE_REVERT_TTL", 7*24*time.Hour),
+	})
+	groupService := core.NewGroupService(client, groupRepo, organizationRepo, roleRepo)
+	scimService := core.NewScimService(client, scimRepo, userRepo, organizationRepo, groupRepo, patRepo, userService, groupService, authService, os.Getenv("BASE_URL"))
+	exportService := core.NewExportService(client, exportRepo, userRepo, authRepo, loginRepo, identityRepo, patRepo, organizationRepo, roleRepo, groupRepo)
+	webhookService := core.NewWebhookService(client, webhookRepo)
+	go core.RunWebhookWorker(context.Background(), webhookService, loadDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second))
+	publisher := loadEventPublisher()
+	defer publisher.Close()
+	dispatcher := core.NewOutboxDispatcher(client, outboxRepo, publisher, webhookService)
+	go core.RunOutboxDispatcher(context.Background(), dispatcher, loadDuration("OUTBOX_POLL_INTERVAL", time.Second))
+	controller := controllers.NewControllers(authService, userService, tokenExchangeService, oidcService, samlService, identityService, patService, apiKeyService, roleService, policyService, organizationService, invitationService, groupService, scimService, exportService, auditService, loginHistoryService, webhookService, claimService, profileService, emailChangeService)
 	router := gin.New()
+	if err := router.SetTrustedProxies(loadTrustedProxies()); err != nil {
+		utils.Logger.Fatal(err)
+	}
 	router.Use(gin.LoggerWithWriter(utils.Logger.Out))
-	routes.RegisterRoutes(controller, router)
+	routes.RegisterRoutes(controller, middlewares.NewAuthorizer(authService, roleService, scimService), router)
 	err := router.Run()
 	utils.Logger.Info("authentication service started successfully")
 	if err != nil {
@@ -34,6 +147,7 @@ func RunApp() {
 		return
 	}
 }
+
 func main() {
 	_ = godotenv.Load()
 	RunApp()
@@ -0,0 +1,15 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ClientInfo exposes the caller's address and user agent to services through the
+// request context, under the "ClientIP" and "UserAgent" keys.
+func ClientInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("ClientIP", c.ClientIP())
+		c.Set("UserAgent", c.Request.UserAgent())
+		c.Next()
+	}
+}
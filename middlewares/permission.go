@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/draco121/horizon/utils"
+	"github.com/gin-gonic/gin"
+	"shield/core"
+)
+
+// Authorizer checks requests against the permissions stored by shield itself, in
+// place of the action levels of the horizon authorization service.
+type Authorizer struct {
+	authenticationService core.IAuthenticationService
+	roleService           core.IRoleService
+	scimService           core.IScimService
+}
+
+func NewAuthorizer(authenticationService core.IAuthenticationService, roleService core.IRoleService, scimService core.IScimService) Authorizer {
+	return Authorizer{
+		authenticationService: authenticationService,
+		roleService:           roleService,
+		scimService:           scimService,
+	}
+}
+
+// RequirePermission authenticates the Authorization header and aborts unless the
+// caller holds permission within the scopes of its token. On success "UserId", "Claims",
+// "Permissions" and "OrganizationId" are set.
+func (a Authorizer) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		principal, err := a.authenticationService.Authenticate(c, token)
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		claims := &principal.JwtCustomClaims
+		organizationId, err := a.authenticationService.ActiveOrganization(c, principal)
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		permissions, err := a.roleService.GetPrincipalPermissions(c, principal, organizationId)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !core.PermissionGranted(permissions, permission) {
+			utils.Logger.Info("permission denied: ", permission)
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Set("UserId", claims.UserId)
+		c.Set("Claims", claims)
+		c.Set("Permissions", permissions)
+		c.Set("OrganizationId", organizationId)
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"shield/entities"
+)
+
+// RequireScimToken authenticates SCIM clients by the bearer token of their
+// organization and sets "OrganizationId" on success.
+func (a Authorizer) RequireScimToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		organizationId, err := a.scimService.AuthenticateToken(c, token)
+		if err != nil {
+			c.Header("Content-Type", "application/scim+json")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"schemas": []string{entities.ScimErrorSchema},
+				"status":  "401",
+				"detail":  err.Error(),
+			})
+			return
+		}
+		c.Set("OrganizationId", organizationId)
+		c.Next()
+	}
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IApiKeyRepository interface {
+	InsertOne(ctx context.Context, key *entities.ApiKey) (*entities.ApiKey, error)
+	FindOneByKeyId(ctx context.Context, keyId string) (*entities.ApiKey, error)
+	FindManyByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ApiKey, error)
+	UpdateSecret(ctx context.Context, key *entities.ApiKey) (*entities.ApiKey, error)
+	UpdateLastUsed(ctx context.Context, id primitive.ObjectID, lastUsedAt time.Time) error
+	DeleteOne(ctx context.Context, organizationId primitive.ObjectID, keyId string) (*entities.ApiKey, error)
+	InsertUsage(ctx context.Context, usage *entities.ApiKeyUsage) error
+	FindUsages(ctx context.Context, organizationId primitive.ObjectID, keyId string, limit int64) ([]entities.ApiKeyUsage, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type apiKeyRepository struct {
+	IApiKeyRepository
+	db *mongo.Database
+}
+
+func NewApiKeyRepository(database *mongo.Database) IApiKeyRepository {
+	return &apiKeyRepository{
+		db: database,
+	}
+}
+
+func (r *apiKeyRepository) InsertOne(ctx context.Context, key *entities.ApiKey) (*entities.ApiKey, error) {
+	key.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("api-keys").InsertOne(ctx, key)
+	if err != nil {
+		return nil, err
+	} else {
+		return key, nil
+	}
+}
+
+func (r *apiKeyRepository) FindOneByKeyId(ctx context.Context, keyId string) (*entities.ApiKey, error) {
+	filter := bson.D{{Key: "keyid", Value: keyId}}
+	result := entities.ApiKey{}
+	err := r.db.Collection("api-keys").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *apiKeyRepository) FindManyByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ApiKey, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}}
+	cursor, err := r.db.Collection("api-keys").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.ApiKey{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *apiKeyRepository) UpdateSecret(ctx context.Context, key *entities.ApiKey) (*entities.ApiKey, error) {
+	filter := bson.M{"_id": key.ID}
+	update := bson.M{"$set": bson.M{
+		"secrethash":         key.SecretHash,
+		"previoussecrethash": key.PreviousSecretHash,
+		"previousexpiresat":  key.PreviousExpiresAt,
+		"rotatedat":          key.RotatedAt,
+	}}
+	result := entities.ApiKey{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("api-keys").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *apiKeyRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID, lastUsedAt time.Time) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"lastusedat": lastUsedAt,
+	}}
+	_, err := r.db.Collection("api-keys").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *apiKeyRepository) DeleteOne(ctx context.Context, organizationId primitive.ObjectID, keyId string) (*entities.ApiKey, error) {
+	filter := bson.D{{Key: "keyid", Value: keyId}, {Key: "organizationid", Value: organizationId}}
+	result := entities.ApiKey{}
+	err := r.db.Collection("api-keys").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *apiKeyRepository) InsertUsage(ctx context.Context, usage *entities.ApiKeyUsage) error {
+	usage.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("api-key-usages").InsertOne(ctx, usage)
+	return err
+}
+
+func (r *apiKeyRepository) FindUsages(ctx context.Context, organizationId primitive.ObjectID, keyId string, limit int64) ([]entities.ApiKeyUsage, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "keyid", Value: keyId}}
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}).SetLimit(limit)
+	cursor, err := r.db.Collection("api-key-usages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.ApiKeyUsage{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// EnsureIndexes creates the unique index keys are verified with and the index backing
+// the usage history of a key.
+func (r *apiKeyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("api-keys").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "keyid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("api-key-usages").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "organizationid", Value: 1}, {Key: "keyid", Value: 1}, {Key: "createdat", Value: -1}},
+	})
+	return err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IAuditRepository only appends and reads; audit events are never updated or deleted.
+type IAuditRepository interface {
+	InsertOne(ctx context.Context, event *entities.AuditEvent) (*entities.AuditEvent, error)
+	FindLast(ctx context.Context) (*entities.AuditEvent, error)
+	FindManyByQuery(ctx context.Context, query *entities.AuditQuery) ([]entities.AuditEvent, error)
+	FindManyAfterSequence(ctx context.Context, sequence int64, limit int) ([]entities.AuditEvent, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type auditRepository struct {
+	IAuditRepository
+	db *mongo.Database
+}
+
+func NewAuditRepository(database *mongo.Database) IAuditRepository {
+	return &auditRepository{
+		db: database,
+	}
+}
+
+func (r *auditRepository) InsertOne(ctx context.Context, event *entities.AuditEvent) (*entities.AuditEvent, error) {
+	_, err := r.db.Collection("audit-events").InsertOne(ctx, event)
+	if err != nil {
+		return nil, err
+	} else {
+		return event, nil
+	}
+}
+
+func (r *auditRepository) FindLast(ctx context.Context) (*entities.AuditEvent, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	result := entities.AuditEvent{}
+	err := r.db.Collection("audit-events").FindOne(ctx, bson.D{}, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// FindManyByQuery returns up to query.Limit events matching the query, newest first.
+// Pages are keyed on the sequence number.
+func (r *auditRepository) FindManyByQuery(ctx context.Context, query *entities.AuditQuery) ([]entities.AuditEvent, error) {
+	filter := bson.D{}
+	if query.Cursor != "" {
+		cursor, err := strconv.ParseInt(query.Cursor, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		filter = append(filter, bson.E{Key: "sequence", Value: bson.M{"$lt": cursor}})
+	}
+	if query.Type != "" {
+		filter = append(filter, bson.E{Key: "type", Value: query.Type})
+	}
+	if query.Outcome != "" {
+		filter = append(filter, bson.E{Key: "outcome", Value: query.Outcome})
+	}
+	for key, value := range map[string]string{"actor": query.Actor, "target": query.Target, "organizationid": query.OrganizationId} {
+		if value == "" {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", key)
+		}
+		filter = append(filter, bson.E{Key: key, Value: id})
+	}
+	createdAt := bson.M{}
+	if query.From != nil {
+		createdAt["$gte"] = *query.From
+	}
+	if query.To != nil {
+		createdAt["$lt"] = *query.To
+	}
+	if len(createdAt) > 0 {
+		filter = append(filter, bson.E{Key: "createdat", Value: createdAt})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: -1}}).SetLimit(int64(query.Limit))
+	return r.find(ctx, filter, opts)
+}
+
+// FindManyAfterSequence returns up to limit events following the given sequence number,
+// oldest first.
+func (r *auditRepository) FindManyAfterSequence(ctx context.Context, sequence int64, limit int) ([]entities.AuditEvent, error) {
+	filter := bson.D{{Key: "sequence", Value: bson.M{"$gt": sequence}}}
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}).SetLimit(int64(limit))
+	return r.find(ctx, filter, opts)
+}
+
+func (r *auditRepository) find(ctx context.Context, filter bson.D, opts *options.FindOptions) ([]entities.AuditEvent, error) {
+	cursor, err := r.db.Collection("audit-events").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.AuditEvent{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// EnsureIndexes creates the unique sequence index that keeps the chain linear and the
+// indexes backing the admin query.
+func (r *auditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("audit-events").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sequence", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "type", Value: 1}, {Key: "sequence", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "sequence", Value: -1}}},
+		{Keys: bson.D{{Key: "target", Value: 1}, {Key: "sequence", Value: -1}}},
+		{Keys: bson.D{{Key: "organizationid", Value: 1}, {Key: "sequence", Value: -1}}},
+	})
+	return err
+}
@@ -5,18 +5,22 @@ import (
 	"errors"
 	"time"
 
-	"github.com/draco121/horizon/models"
+	"shield/entities"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type IAuthenticationRepository interface {
-	InsertOne(ctx context.Context, session *models.Session) (primitive.ObjectID, error)
-	UpdateOne(ctx context.Context, session *models.Session) (*models.Session, error)
-	FindOneById(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
-	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
+	InsertOne(ctx context.Context, session *entities.Session) (primitive.ObjectID, error)
+	UpdateOne(ctx context.Context, session *entities.Session) (*entities.Session, error)
+	FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Session, error)
+	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Session, error)
+	UpdateOrganization(ctx context.Context, id primitive.ObjectID, organizationId primitive.ObjectID) (*entities.Session, error)
+	DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) (int64, error)
+	FindManyByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.Session, error)
 }
 
 type authenticationRepository struct {
@@ -30,7 +34,7 @@ func NewAuthenticationRepository(database *mongo.Database) IAuthenticationReposi
 	}
 }
 
-func (ur *authenticationRepository) InsertOne(ctx context.Context, session *models.Session) (primitive.ObjectID, error) {
+func (ur *authenticationRepository) InsertOne(ctx context.Context, session *entities.Session) (primitive.ObjectID, error) {
 
 	result, err := ur.db.Collection("sessions").InsertOne(ctx, session)
 	if err != nil {
@@ -41,12 +45,12 @@ func (ur *authenticationRepository) InsertOne(ctx context.Context, session *mode
 	}
 }
 
-func (ur *authenticationRepository) UpdateOne(ctx context.Context, session *models.Session) (*models.Session, error) {
+func (ur *authenticationRepository) UpdateOne(ctx context.Context, session *entities.Session) (*entities.Session, error) {
 	filter := bson.M{"_id": session.ID}
 	update := bson.M{"$set": bson.M{
 		"updatedAt": time.Now(),
 	}}
-	result := models.Session{}
+	result := entities.Session{}
 	err := ur.db.Collection("sessions").FindOneAndUpdate(ctx, filter, update).Decode(&result)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, err
@@ -55,9 +59,9 @@ func (ur *authenticationRepository) UpdateOne(ctx context.Context, session *mode
 	}
 }
 
-func (ur *authenticationRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+func (ur *authenticationRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Session, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
-	result := models.Session{}
+	result := entities.Session{}
 	err := ur.db.Collection("sessions").FindOne(ctx, filter).Decode(&result)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, err
@@ -67,10 +71,10 @@ func (ur *authenticationRepository) FindOneById(ctx context.Context, id primitiv
 
 }
 
-func (ur *authenticationRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+func (ur *authenticationRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Session, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
-	result := models.Session{}
-	err := ur.db.Collection("users").FindOneAndDelete(ctx, filter).Decode(&result)
+	result := entities.Session{}
+	err := ur.db.Collection("sessions").FindOneAndDelete(ctx, filter).Decode(&result)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, err
 	} else {
@@ -78,3 +82,44 @@ func (ur *authenticationRepository) DeleteOneById(ctx context.Context, id primit
 	}
 
 }
+
+func (ur *authenticationRepository) UpdateOrganization(ctx context.Context, id primitive.ObjectID, organizationId primitive.ObjectID) (*entities.Session, error) {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"organizationid": organizationId,
+		"updatedat":      time.Now(),
+	}}
+	result := entities.Session{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := ur.db.Collection("sessions").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (ur *authenticationRepository) DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) (int64, error) {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	result, err := ur.db.Collection("sessions").DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	} else {
+		return result.DeletedCount, nil
+	}
+}
+
+func (ur *authenticationRepository) FindManyByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.Session, error) {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	cursor, err := ur.db.Collection("sessions").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Session{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IClaimRepository interface {
+	InsertOne(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error)
+	UpdateOne(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error)
+	FindMany(ctx context.Context) ([]entities.ClaimTemplate, error)
+	FindManyByAudience(ctx context.Context, audience string) ([]entities.ClaimTemplate, error)
+	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.ClaimTemplate, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type claimRepository struct {
+	IClaimRepository
+	db *mongo.Database
+}
+
+func NewClaimRepository(database *mongo.Database) IClaimRepository {
+	return &claimRepository{
+		db: database,
+	}
+}
+
+func (r *claimRepository) InsertOne(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error) {
+	template.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("claim-templates").InsertOne(ctx, template)
+	if err != nil {
+		return nil, err
+	} else {
+		return template, nil
+	}
+}
+
+func (r *claimRepository) UpdateOne(ctx context.Context, template *entities.ClaimTemplate) (*entities.ClaimTemplate, error) {
+	filter := bson.M{"_id": template.ID}
+	update := bson.M{"$set": bson.M{
+		"claim":     template.Claim,
+		"source":    template.Source,
+		"value":     template.Value,
+		"audience":  template.Audience,
+		"updatedat": time.Now(),
+	}}
+	result := entities.ClaimTemplate{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("claim-templates").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *claimRepository) FindMany(ctx context.Context) ([]entities.ClaimTemplate, error) {
+	return r.find(ctx, bson.D{})
+}
+
+// FindManyByAudience finds the templates applying to tokens for audience: those for the
+// audience and those without one.
+func (r *claimRepository) FindManyByAudience(ctx context.Context, audience string) ([]entities.ClaimTemplate, error) {
+	return r.find(ctx, bson.D{{Key: "audience", Value: bson.M{"$in": []string{"", audience}}}})
+}
+
+func (r *claimRepository) find(ctx context.Context, filter bson.D) ([]entities.ClaimTemplate, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "claim", Value: 1}, {Key: "audience", Value: 1}})
+	cursor, err := r.db.Collection("claim-templates").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.ClaimTemplate{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *claimRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.ClaimTemplate, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.ClaimTemplate{}
+	err := r.db.Collection("claim-templates").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// EnsureIndexes creates the unique index allowing a single template per claim and audience.
+func (r *claimRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("claim-templates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "audience", Value: 1}, {Key: "claim", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IEmailChangeRepository interface {
+	InsertOne(ctx context.Context, change *entities.EmailChange) (*entities.EmailChange, error)
+	FindOneByConfirmHash(ctx context.Context, hash string) (*entities.EmailChange, error)
+	FindOneByRevertHash(ctx context.Context, hash string) (*entities.EmailChange, error)
+	CancelPendingByUserId(ctx context.Context, userId primitive.ObjectID) error
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, from string, to string, at time.Time) (*entities.EmailChange, error)
+	DeleteOneById(ctx context.Context, id primitive.ObjectID) error
+	DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type emailChangeRepository struct {
+	IEmailChangeRepository
+	db *mongo.Database
+}
+
+func NewEmailChangeRepository(database *mongo.Database) IEmailChangeRepository {
+	return &emailChangeRepository{
+		db: database,
+	}
+}
+
+func (r *emailChangeRepository) InsertOne(ctx context.Context, change *entities.EmailChange) (*entities.EmailChange, error) {
+	change.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("email-changes").InsertOne(ctx, change)
+	if err != nil {
+		return nil, err
+	} else {
+		return change, nil
+	}
+}
+
+func (r *emailChangeRepository) FindOneByConfirmHash(ctx context.Context, hash string) (*entities.EmailChange, error) {
+	return r.findOne(ctx, bson.D{{Key: "confirmhash", Value: hash}})
+}
+
+func (r *emailChangeRepository) FindOneByRevertHash(ctx context.Context, hash string) (*entities.EmailChange, error) {
+	return r.findOne(ctx, bson.D{{Key: "reverthash", Value: hash}})
+}
+
+func (r *emailChangeRepository) findOne(ctx context.Context, filter bson.D) (*entities.EmailChange, error) {
+	result := entities.EmailChange{}
+	err := r.db.Collection("email-changes").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *emailChangeRepository) CancelPendingByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.M{"userid": userId, "status": entities.EmailChangePending}
+	update := bson.M{"$set": bson.M{"status": entities.EmailChangeCancelled}}
+	_, err := r.db.Collection("email-changes").UpdateMany(ctx, filter, update)
+	return err
+}
+
+// UpdateStatus moves a change from status from to status to, recording at as the time
+// it was confirmed or reverted. It fails with mongo.ErrNoDocuments when the change is
+// no longer in status from, so a link cannot be used twice.
+func (r *emailChangeRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, from string, to string, at time.Time) (*entities.EmailChange, error) {
+	filter := bson.M{"_id": id, "status": from}
+	set := bson.M{"status": to}
+	switch to {
+	case entities.EmailChangeConfirmed:
+		set["confirmedat"] = at
+	case entities.EmailChangeReverted:
+		set["revertedat"] = at
+	}
+	result := entities.EmailChange{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("email-changes").FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *emailChangeRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.db.Collection("email-changes").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *emailChangeRepository) DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	_, err := r.db.Collection("email-changes").DeleteMany(ctx, bson.M{"userid": userId})
+	return err
+}
+
+// EnsureIndexes creates the indexes links are looked up with and the TTL index removing
+// changes once they can no longer be reverted.
+func (r *emailChangeRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("email-changes").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "confirmhash", Value: 1}}},
+		{Keys: bson.D{{Key: "reverthash", Value: 1}}},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "revertexpiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IExportRepository interface {
+	InsertOne(ctx context.Context, export *entities.DataExport) (*entities.DataExport, error)
+	FindOneById(ctx context.Context, userId primitive.ObjectID, id primitive.ObjectID) (*entities.DataExport, error)
+	FindPendingByUserId(ctx context.Context, userId primitive.ObjectID) (*entities.DataExport, error)
+	Complete(ctx context.Context, id primitive.ObjectID, archive []byte) error
+	Fail(ctx context.Context, id primitive.ObjectID, reason string) error
+	DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type exportRepository struct {
+	IExportRepository
+	db *mongo.Database
+}
+
+func NewExportRepository(database *mongo.Database) IExportRepository {
+	return &exportRepository{
+		db: database,
+	}
+}
+
+func (r *exportRepository) InsertOne(ctx context.Context, export *entities.DataExport) (*entities.DataExport, error) {
+	export.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("data-exports").InsertOne(ctx, export)
+	if err != nil {
+		return nil, err
+	} else {
+		return export, nil
+	}
+}
+
+func (r *exportRepository) FindOneById(ctx context.Context, userId primitive.ObjectID, id primitive.ObjectID) (*entities.DataExport, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "userid", Value: userId}}
+	result := entities.DataExport{}
+	err := r.db.Collection("data-exports").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *exportRepository) FindPendingByUserId(ctx context.Context, userId primitive.ObjectID) (*entities.DataExport, error) {
+	filter := bson.D{{Key: "userid", Value: userId}, {Key: "status", Value: entities.DataExportPending}}
+	opts := options.FindOne().SetProjection(bson.M{"archive": 0})
+	result := entities.DataExport{}
+	err := r.db.Collection("data-exports").FindOne(ctx, filter, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *exportRepository) Complete(ctx context.Context, id primitive.ObjectID, archive []byte) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"status":      entities.DataExportCompleted,
+		"archive":     archive,
+		"size":        len(archive),
+		"completedat": time.Now(),
+	}}
+	_, err := r.db.Collection("data-exports").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *exportRepository) Fail(ctx context.Context, id primitive.ObjectID, reason string) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"status":      entities.DataExportFailed,
+		"error":       reason,
+		"completedat": time.Now(),
+	}}
+	_, err := r.db.Collection("data-exports").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *exportRepository) DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("data-exports").DeleteMany(ctx, filter)
+	return err
+}
+
+// EnsureIndexes creates the lookup index and the TTL index that drops expired exports.
+func (r *exportRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("data-exports").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type IGroupRepository interface {
+	InsertOne(ctx context.Context, group *entities.Group) (*entities.Group, error)
+	UpdateOne(ctx context.Context, group *entities.Group) (*entities.Group, error)
+	FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Group, error)
+	FindManyByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Group, error)
+	FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]entities.Group, error)
+	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Group, error)
+	PullRole(ctx context.Context, roleId primitive.ObjectID) error
+	InsertMember(ctx context.Context, member *entities.GroupMember) (*entities.GroupMember, error)
+	FindMembersByGroupId(ctx context.Context, groupId primitive.ObjectID) ([]entities.GroupMember, error)
+	FindParents(ctx context.Context, memberType string, memberIds []primitive.ObjectID) ([]entities.GroupMember, error)
+	DeleteMember(ctx context.Context, groupId primitive.ObjectID, memberId primitive.ObjectID) (*entities.GroupMember, error)
+	DeleteMembersByMemberId(ctx context.Context, memberType string, memberId primitive.ObjectID) error
+}
+
+type groupRepository struct {
+	IGroupRepository
+	db *mongo.Database
+}
+
+func NewGroupRepository(database *mongo.Database) IGroupRepository {
+	return &groupRepository{
+		db: database,
+	}
+}
+
+func (r *groupRepository) InsertOne(ctx context.Context, group *entities.Group) (*entities.Group, error) {
+	group.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("groups").InsertOne(ctx, group)
+	if err != nil {
+		return nil, err
+	} else {
+		return group, nil
+	}
+}
+
+func (r *groupRepository) UpdateOne(ctx context.Context, group *entities.Group) (*entities.Group, error) {
+	filter := bson.M{"_id": group.ID}
+	update := bson.M{"$set": bson.M{
+		"name":        group.Name,
+		"description": group.Description,
+		"externalid":  group.ExternalId,
+		"roleids":     group.RoleIds,
+		"updatedat":   time.Now(),
+	}}
+	_, err := r.db.Collection("groups").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	} else {
+		return r.FindOneById(ctx, group.ID)
+	}
+}
+
+func (r *groupRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Group, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Group{}
+	err := r.db.Collection("groups").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *groupRepository) FindManyByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Group, error) {
+	return r.find(ctx, bson.D{{Key: "organizationid", Value: organizationId}})
+}
+
+func (r *groupRepository) FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]entities.Group, error) {
+	return r.find(ctx, bson.D{{Key: "_id", Value: bson.M{"$in": ids}}})
+}
+
+func (r *groupRepository) find(ctx context.Context, filter bson.D) ([]entities.Group, error) {
+	cursor, err := r.db.Collection("groups").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Group{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *groupRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Group, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Group{}
+	err := r.db.Collection("groups").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.db.Collection("group-members").DeleteMany(ctx, bson.D{{Key: "groupid", Value: id}})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PullRole removes a deleted role from every group granting it.
+func (r *groupRepository) PullRole(ctx context.Context, roleId primitive.ObjectID) error {
+	filter := bson.D{{Key: "roleids", Value: roleId}}
+	update := bson.M{"$pull": bson.M{"roleids": roleId}}
+	_, err := r.db.Collection("groups").UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (r *groupRepository) InsertMember(ctx context.Context, member *entities.GroupMember) (*entities.GroupMember, error) {
+	filter := bson.D{{Key: "groupid", Value: member.GroupId}, {Key: "memberid", Value: member.MemberId}}
+	count, err := r.db.Collection("group-members").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	} else if count > 0 {
+		return nil, fmt.Errorf("record exists")
+	}
+	member.ID = primitive.NewObjectID()
+	_, err = r.db.Collection("group-members").InsertOne(ctx, member)
+	if err != nil {
+		return nil, err
+	} else {
+		return member, nil
+	}
+}
+
+func (r *groupRepository) FindMembersByGroupId(ctx context.Context, groupId primitive.ObjectID) ([]entities.GroupMember, error) {
+	return r.findMembers(ctx, bson.D{{Key: "groupid", Value: groupId}})
+}
+
+// FindParents returns the edges of the groups directly containing any of memberIds.
+func (r *groupRepository) FindParents(ctx context.Context, memberType string, memberIds []primitive.ObjectID) ([]entities.GroupMember, error) {
+	return r.findMembers(ctx, bson.D{{Key: "membertype", Value: memberType}, {Key: "memberid", Value: bson.M{"$in": memberIds}}})
+}
+
+func (r *groupRepository) findMembers(ctx context.Context, filter bson.D) ([]entities.GroupMember, error) {
+	cursor, err := r.db.Collection("group-members").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.GroupMember{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *groupRepository) DeleteMember(ctx context.Context, groupId primitive.ObjectID, memberId primitive.ObjectID) (*entities.GroupMember, error) {
+	filter := bson.D{{Key: "groupid", Value: groupId}, {Key: "memberid", Value: memberId}}
+	result := entities.GroupMember{}
+	err := r.db.Collection("group-members").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *groupRepository) DeleteMembersByMemberId(ctx context.Context, memberType string, memberId primitive.ObjectID) error {
+	filter := bson.D{{Key: "membertype", Value: memberType}, {Key: "memberid", Value: memberId}}
+	_, err := r.db.Collection("group-members").DeleteMany(ctx, filter)
+	return err
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IIdentityRepository interface {
+	InsertOne(ctx context.Context, identity *entities.Identity) (*entities.Identity, error)
+	FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Identity, error)
+	FindOneByProviderSubject(ctx context.Context, provider string, subject string) (*entities.Identity, error)
+	FindManyByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.Identity, error)
+	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Identity, error)
+	DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error
+	LockUser(ctx context.Context, userId primitive.ObjectID) error
+}
+
+type identityRepository struct {
+	IIdentityRepository
+	db *mongo.Database
+}
+
+func NewIdentityRepository(database *mongo.Database) IIdentityRepository {
+	return &identityRepository{
+		db: database,
+	}
+}
+
+func (r *identityRepository) InsertOne(ctx context.Context, identity *entities.Identity) (*entities.Identity, error) {
+	result, _ := r.FindOneByProviderSubject(ctx, identity.Provider, identity.Subject)
+	if result != nil {
+		return nil, fmt.Errorf("record exists")
+	} else {
+		identity.ID = primitive.NewObjectID()
+		_, err := r.db.Collection("identities").InsertOne(ctx, identity)
+		if err != nil {
+			return nil, err
+		} else {
+			return identity, nil
+		}
+	}
+}
+
+func (r *identityRepository) FindOneByProviderSubject(ctx context.Context, provider string, subject string) (*entities.Identity, error) {
+	filter := bson.D{{Key: "provider", Value: provider}, {Key: "subject", Value: subject}}
+	result := entities.Identity{}
+	err := r.db.Collection("identities").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *identityRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Identity, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Identity{}
+	err := r.db.Collection("identities").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *identityRepository) FindManyByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.Identity, error) {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	cursor, err := r.db.Collection("identities").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Identity{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *identityRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Identity, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Identity{}
+	err := r.db.Collection("identities").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *identityRepository) DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("identities").DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("identity-locks").DeleteOne(ctx, bson.D{{Key: "_id", Value: userId}})
+	return err
+}
+
+// LockUser writes the lock document of the user. Transactions that lock the same user
+// conflict, so checks across the identities of a user cannot interleave.
+func (r *identityRepository) LockUser(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "_id", Value: userId}}
+	update := bson.M{"$set": bson.M{"lockedat": time.Now()}}
+	_, err := r.db.Collection("identity-locks").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IInvitationRepository interface {
+	InsertOne(ctx context.Context, invitation *entities.Invitation) (*entities.Invitation, error)
+	FindOneByHash(ctx context.Context, hash string) (*entities.Invitation, error)
+	FindPendingByOrganizationId(ctx context.Context, organizationId primitive.ObjectID, now time.Time) ([]entities.Invitation, error)
+	MarkAccepted(ctx context.Context, id primitive.ObjectID, userId primitive.ObjectID, acceptedAt time.Time) (*entities.Invitation, error)
+	DeletePending(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Invitation, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type invitationRepository struct {
+	IInvitationRepository
+	db *mongo.Database
+}
+
+func NewInvitationRepository(database *mongo.Database) IInvitationRepository {
+	return &invitationRepository{
+		db: database,
+	}
+}
+
+func (r *invitationRepository) InsertOne(ctx context.Context, invitation *entities.Invitation) (*entities.Invitation, error) {
+	invitation.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("invitations").InsertOne(ctx, invitation)
+	if err != nil {
+		return nil, err
+	} else {
+		return invitation, nil
+	}
+}
+
+func (r *invitationRepository) FindOneByHash(ctx context.Context, hash string) (*entities.Invitation, error) {
+	filter := bson.D{{Key: "tokenhash", Value: hash}}
+	result := entities.Invitation{}
+	err := r.db.Collection("invitations").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *invitationRepository) FindPendingByOrganizationId(ctx context.Context, organizationId primitive.ObjectID, now time.Time) ([]entities.Invitation, error) {
+	filter := bson.D{
+		{Key: "organizationid", Value: organizationId},
+		{Key: "acceptedat", Value: nil},
+		{Key: "expiresat", Value: bson.M{"$gt": now}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}})
+	cursor, err := r.db.Collection("invitations").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Invitation{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// MarkAccepted accepts a pending invitation. The filter on acceptedat makes the update
+// atomic, so an invitation can only be accepted once even under concurrent requests.
+func (r *invitationRepository) MarkAccepted(ctx context.Context, id primitive.ObjectID, userId primitive.ObjectID, acceptedAt time.Time) (*entities.Invitation, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "acceptedat", Value: nil}}
+	update := bson.M{"$set": bson.M{
+		"acceptedat": acceptedAt,
+		"acceptedby": userId,
+	}}
+	result := entities.Invitation{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("invitations").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *invitationRepository) DeletePending(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.Invitation, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "organizationid", Value: organizationId}, {Key: "acceptedat", Value: nil}}
+	result := entities.Invitation{}
+	err := r.db.Collection("invitations").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// EnsureIndexes creates the unique index invitations are accepted with.
+func (r *invitationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("invitations").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenhash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
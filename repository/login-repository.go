@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ILoginRepository interface {
+	InsertOne(ctx context.Context, attempt *entities.LoginAttempt) (*entities.LoginAttempt, error)
+	FindManyByUserId(ctx context.Context, userId primitive.ObjectID, query *entities.LoginQuery) ([]entities.LoginAttempt, error)
+	HasSucceeded(ctx context.Context, userId primitive.ObjectID, field string, value string) (bool, error)
+	DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type loginRepository struct {
+	ILoginRepository
+	db *mongo.Database
+}
+
+func NewLoginRepository(database *mongo.Database) ILoginRepository {
+	return &loginRepository{
+		db: database,
+	}
+}
+
+func (r *loginRepository) InsertOne(ctx context.Context, attempt *entities.LoginAttempt) (*entities.LoginAttempt, error) {
+	attempt.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("login-attempts").InsertOne(ctx, attempt)
+	if err != nil {
+		return nil, err
+	} else {
+		return attempt, nil
+	}
+}
+
+// FindManyByUserId returns up to query.Limit attempts of the user, newest first.
+func (r *loginRepository) FindManyByUserId(ctx context.Context, userId primitive.ObjectID, query *entities.LoginQuery) ([]entities.LoginAttempt, error) {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	if query.Cursor != "" {
+		cursor, err := primitive.ObjectIDFromHex(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		filter = append(filter, bson.E{Key: "_id", Value: bson.M{"$lt": cursor}})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(query.Limit))
+	cursor, err := r.db.Collection("login-attempts").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.LoginAttempt{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// HasSucceeded reports whether the user signed in successfully with the given value of
+// field, such as a device fingerprint or a network, within the retention period.
+func (r *loginRepository) HasSucceeded(ctx context.Context, userId primitive.ObjectID, field string, value string) (bool, error) {
+	filter := bson.D{{Key: "userid", Value: userId}, {Key: "success", Value: true}, {Key: field, Value: value}}
+	count, err := r.db.Collection("login-attempts").CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	} else {
+		return count > 0, nil
+	}
+}
+
+func (r *loginRepository) DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("login-attempts").DeleteMany(ctx, filter)
+	return err
+}
+
+// EnsureIndexes creates the indexes backing the history and the new device checks, and
+// the TTL index enforcing retention.
+func (r *loginRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("login-attempts").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "fingerprint", Value: 1}}},
+		{Keys: bson.D{{Key: "userid", Value: 1}, {Key: "network", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IOidcRepository interface {
+	InsertProvider(ctx context.Context, provider *entities.IdentityProvider) (*entities.IdentityProvider, error)
+	FindProviderByName(ctx context.Context, name string) (*entities.IdentityProvider, error)
+	FindProviders(ctx context.Context) ([]entities.IdentityProvider, error)
+	DeleteProviderByName(ctx context.Context, name string) (*entities.IdentityProvider, error)
+	InsertState(ctx context.Context, state *entities.OidcLoginState) (primitive.ObjectID, error)
+	ConsumeState(ctx context.Context, state string) (*entities.OidcLoginState, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type oidcRepository struct {
+	IOidcRepository
+	db *mongo.Database
+}
+
+func NewOidcRepository(database *mongo.Database) IOidcRepository {
+	return &oidcRepository{
+		db: database,
+	}
+}
+
+func (r *oidcRepository) InsertProvider(ctx context.Context, provider *entities.IdentityProvider) (*entities.IdentityProvider, error) {
+	result, _ := r.FindProviderByName(ctx, provider.Name)
+	if result != nil {
+		return nil, fmt.Errorf("record exists")
+	} else {
+		provider.ID = primitive.NewObjectID()
+		_, err := r.db.Collection("identity-providers").InsertOne(ctx, provider)
+		if err != nil {
+			return nil, err
+		} else {
+			return provider, nil
+		}
+	}
+}
+
+func (r *oidcRepository) FindProviderByName(ctx context.Context, name string) (*entities.IdentityProvider, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	result := entities.IdentityProvider{}
+	err := r.db.Collection("identity-providers").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *oidcRepository) FindProviders(ctx context.Context) ([]entities.IdentityProvider, error) {
+	cursor, err := r.db.Collection("identity-providers").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.IdentityProvider{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *oidcRepository) DeleteProviderByName(ctx context.Context, name string) (*entities.IdentityProvider, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	result := entities.IdentityProvider{}
+	err := r.db.Collection("identity-providers").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *oidcRepository) InsertState(ctx context.Context, state *entities.OidcLoginState) (primitive.ObjectID, error) {
+	state.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("oidc-login-states").InsertOne(ctx, state)
+	if err != nil {
+		return primitive.NilObjectID, err
+	} else {
+		return state.ID, nil
+	}
+}
+
+// ConsumeState deletes and returns an unexpired login state, so each state can only be used once.
+func (r *oidcRepository) ConsumeState(ctx context.Context, state string) (*entities.OidcLoginState, error) {
+	filter := bson.D{{Key: "state", Value: state}, {Key: "expiresat", Value: bson.M{"$gt": time.Now()}}}
+	result := entities.OidcLoginState{}
+	err := r.db.Collection("oidc-login-states").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// EnsureIndexes creates the index login states are consumed with and the TTL index
+// dropping them once they expire.
+func (r *oidcRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("oidc-login-states").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IOrganizationRepository interface {
+	InsertOne(ctx context.Context, organization *entities.Organization) (*entities.Organization, error)
+	FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Organization, error)
+	LockOne(ctx context.Context, id primitive.ObjectID) error
+	FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]entities.Organization, error)
+	InsertMembership(ctx context.Context, membership *entities.Membership) (*entities.Membership, error)
+	FindMembership(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error)
+	FindAnyMembership(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error)
+	FindMembershipsByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.Membership, error)
+	FindMembershipsByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Membership, error)
+	UpdateMembershipRole(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID, role string) (*entities.Membership, error)
+	UpdateMembershipSuspended(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID, suspended bool) error
+	DeleteMembership(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error)
+	DeleteMembershipsByUserId(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type organizationRepository struct {
+	IOrganizationRepository
+	db *mongo.Database
+}
+
+func NewOrganizationRepository(database *mongo.Database) IOrganizationRepository {
+	return &organizationRepository{
+		db: database,
+	}
+}
+
+func (r *organizationRepository) InsertOne(ctx context.Context, organization *entities.Organization) (*entities.Organization, error) {
+	organization.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("organizations").InsertOne(ctx, organization)
+	if err != nil {
+		return nil, err
+	} else {
+		return organization, nil
+	}
+}
+
+func (r *organizationRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Organization, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Organization{}
+	err := r.db.Collection("organizations").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// LockOne writes to the organization so that concurrent transactions changing it
+// conflict with each other.
+func (r *organizationRepository) LockOne(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.M{"$inc": bson.M{"lock": 1}}
+	_, err := r.db.Collection("organizations").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *organizationRepository) FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]entities.Organization, error) {
+	filter := bson.D{{Key: "_id", Value: bson.M{"$in": ids}}}
+	cursor, err := r.db.Collection("organizations").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Organization{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *organizationRepository) InsertMembership(ctx context.Context, membership *entities.Membership) (*entities.Membership, error) {
+	result, _ := r.FindMembership(ctx, membership.OrganizationId, membership.UserId)
+	if result != nil {
+		return nil, fmt.Errorf("record exists")
+	} else {
+		membership.ID = primitive.NewObjectID()
+		_, err := r.db.Collection("memberships").InsertOne(ctx, membership)
+		if err != nil {
+			return nil, err
+		} else {
+			return membership, nil
+		}
+	}
+}
+
+// FindMembership finds the membership of the user in the organization unless it is
+// suspended, so a suspended member is refused like a stranger.
+func (r *organizationRepository) FindMembership(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}, {Key: "suspended", Value: bson.M{"$ne": true}}}
+	result := entities.Membership{}
+	err := r.db.Collection("memberships").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// FindAnyMembership finds the membership of the user in the organization, suspended or not.
+func (r *organizationRepository) FindAnyMembership(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}}
+	result := entities.Membership{}
+	err := r.db.Collection("memberships").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// FindMembershipsByUserId finds the memberships of the user that are not suspended.
+func (r *organizationRepository) FindMembershipsByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.Membership, error) {
+	return r.findMemberships(ctx, bson.D{{Key: "userid", Value: userId}, {Key: "suspended", Value: bson.M{"$ne": true}}})
+}
+
+func (r *organizationRepository) FindMembershipsByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.Membership, error) {
+	return r.findMemberships(ctx, bson.D{{Key: "organizationid", Value: organizationId}})
+}
+
+func (r *organizationRepository) findMemberships(ctx context.Context, filter bson.D) ([]entities.Membership, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdat", Value: 1}})
+	cursor, err := r.db.Collection("memberships").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Membership{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *organizationRepository) UpdateMembershipRole(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID, role string) (*entities.Membership, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}}
+	update := bson.M{"$set": bson.M{
+		"role": role,
+	}}
+	result := entities.Membership{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("memberships").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *organizationRepository) UpdateMembershipSuspended(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID, suspended bool) error {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}}
+	update := bson.M{"$set": bson.M{"suspended": suspended}}
+	_, err := r.db.Collection("memberships").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *organizationRepository) DeleteMembership(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.Membership, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}}
+	result := entities.Membership{}
+	err := r.db.Collection("memberships").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *organizationRepository) DeleteMembershipsByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("memberships").DeleteMany(ctx, filter)
+	return err
+}
+
+// EnsureIndexes creates the unique index allowing a single membership per user and
+// organization.
+func (r *organizationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("memberships").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "organizationid", Value: 1}, {Key: "userid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
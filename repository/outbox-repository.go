@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IOutboxRepository interface {
+	InsertOne(ctx context.Context, event *entities.OutboxEvent) (*entities.OutboxEvent, error)
+	FindUndispatched(ctx context.Context, limit int) ([]entities.OutboxEvent, error)
+	MarkDispatched(ctx context.Context, id primitive.ObjectID) error
+	AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type outboxRepository struct {
+	IOutboxRepository
+	db *mongo.Database
+}
+
+func NewOutboxRepository(database *mongo.Database) IOutboxRepository {
+	return &outboxRepository{
+		db: database,
+	}
+}
+
+func (r *outboxRepository) InsertOne(ctx context.Context, event *entities.OutboxEvent) (*entities.OutboxEvent, error) {
+	event.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("outbox").InsertOne(ctx, event)
+	if err != nil {
+		return nil, err
+	} else {
+		return event, nil
+	}
+}
+
+// FindUndispatched returns up to limit events not dispatched yet, oldest first.
+func (r *outboxRepository) FindUndispatched(ctx context.Context, limit int) ([]entities.OutboxEvent, error) {
+	filter := bson.D{{Key: "dispatchedat", Value: nil}}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.db.Collection("outbox").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.OutboxEvent{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *outboxRepository) MarkDispatched(ctx context.Context, id primitive.ObjectID) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"dispatchedat": time.Now(),
+	}}
+	_, err := r.db.Collection("outbox").UpdateOne(ctx, filter, update)
+	return err
+}
+
+// AcquireLease takes or extends the dispatcher lease for owner. It reports false while
+// another owner holds an unexpired lease.
+func (r *outboxRepository) AcquireLease(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "_id", Value: "dispatcher"},
+		{Key: "$or", Value: bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expiresat": bson.M{"$lte": now}},
+		}},
+	}
+	update := bson.M{"$set": bson.M{
+		"owner":     owner,
+		"expiresat": now.Add(ttl),
+	}}
+	_, err := r.db.Collection("outbox-leases").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	} else {
+		return true, nil
+	}
+}
+
+// EnsureIndexes creates the index the dispatcher polls and drops dispatched events
+// after a week.
+func (r *outboxRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("outbox").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "dispatchedat", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "dispatchedat", Value: 1}}, Options: options.Index().SetName("dispatchedat_ttl").SetExpireAfterSeconds(7 * 24 * 60 * 60)},
+	})
+	return err
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IPersonalAccessTokenRepository interface {
+	InsertOne(ctx context.Context, token *entities.PersonalAccessToken) (*entities.PersonalAccessToken, error)
+	FindOneByHash(ctx context.Context, hash string) (*entities.PersonalAccessToken, error)
+	FindManyByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.PersonalAccessToken, error)
+	UpdateLastUsed(ctx context.Context, id primitive.ObjectID, lastUsedAt time.Time) error
+	DeleteOne(ctx context.Context, userId primitive.ObjectID, id primitive.ObjectID) (*entities.PersonalAccessToken, error)
+	DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type personalAccessTokenRepository struct {
+	IPersonalAccessTokenRepository
+	db *mongo.Database
+}
+
+func NewPersonalAccessTokenRepository(database *mongo.Database) IPersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{
+		db: database,
+	}
+}
+
+func (r *personalAccessTokenRepository) InsertOne(ctx context.Context, token *entities.PersonalAccessToken) (*entities.PersonalAccessToken, error) {
+	token.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("personal-access-tokens").InsertOne(ctx, token)
+	if err != nil {
+		return nil, err
+	} else {
+		return token, nil
+	}
+}
+
+func (r *personalAccessTokenRepository) FindOneByHash(ctx context.Context, hash string) (*entities.PersonalAccessToken, error) {
+	filter := bson.D{{Key: "tokenhash", Value: hash}}
+	result := entities.PersonalAccessToken{}
+	err := r.db.Collection("personal-access-tokens").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *personalAccessTokenRepository) FindManyByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.PersonalAccessToken, error) {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	cursor, err := r.db.Collection("personal-access-tokens").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.PersonalAccessToken{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *personalAccessTokenRepository) UpdateLastUsed(ctx context.Context, id primitive.ObjectID, lastUsedAt time.Time) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"lastusedat": lastUsedAt,
+	}}
+	_, err := r.db.Collection("personal-access-tokens").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *personalAccessTokenRepository) DeleteOne(ctx context.Context, userId primitive.ObjectID, id primitive.ObjectID) (*entities.PersonalAccessToken, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "userid", Value: userId}}
+	result := entities.PersonalAccessToken{}
+	err := r.db.Collection("personal-access-tokens").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *personalAccessTokenRepository) DeleteManyByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("personal-access-tokens").DeleteMany(ctx, filter)
+	return err
+}
+
+// EnsureIndexes creates the unique index tokens are authenticated with.
+func (r *personalAccessTokenRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("personal-access-tokens").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenhash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IPolicyRepository interface {
+	InsertOne(ctx context.Context, policy *entities.Policy) (*entities.Policy, error)
+	FindLatestByName(ctx context.Context, name string) (*entities.Policy, error)
+	FindVersionsByName(ctx context.Context, name string) ([]entities.Policy, error)
+	FindLatest(ctx context.Context) ([]entities.Policy, error)
+	DeleteManyByName(ctx context.Context, name string) (int64, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type policyRepository struct {
+	IPolicyRepository
+	db *mongo.Database
+}
+
+func NewPolicyRepository(database *mongo.Database) IPolicyRepository {
+	return &policyRepository{
+		db: database,
+	}
+}
+
+func (r *policyRepository) InsertOne(ctx context.Context, policy *entities.Policy) (*entities.Policy, error) {
+	policy.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("policies").InsertOne(ctx, policy)
+	if err != nil {
+		return nil, err
+	} else {
+		return policy, nil
+	}
+}
+
+func (r *policyRepository) FindLatestByName(ctx context.Context, name string) (*entities.Policy, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	result := entities.Policy{}
+	err := r.db.Collection("policies").FindOne(ctx, filter, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *policyRepository) FindVersionsByName(ctx context.Context, name string) ([]entities.Policy, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := r.db.Collection("policies").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Policy{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// FindLatest returns the latest version of every policy.
+func (r *policyRepository) FindLatest(ctx context.Context) ([]entities.Policy, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}, {Key: "version", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$name"}, {Key: "policy", Value: bson.M{"$first": "$$ROOT"}}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$policy"}}},
+	}
+	cursor, err := r.db.Collection("policies").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Policy{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *policyRepository) DeleteManyByName(ctx context.Context, name string) (int64, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	result, err := r.db.Collection("policies").DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	} else {
+		return result.DeletedCount, nil
+	}
+}
+
+// EnsureIndexes creates the unique index that keeps concurrent saves of a policy from
+// writing the same version twice.
+func (r *policyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("policies").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
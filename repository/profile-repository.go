@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// profileSchemaId is the id of the instance profile schema document. The schemas of
+// organizations are stored under the id of the organization.
+const profileSchemaId = "default"
+
+type IProfileRepository interface {
+	FindSchema(ctx context.Context, organizationId primitive.ObjectID) (*entities.ProfileSchema, error)
+	UpsertSchema(ctx context.Context, schema *entities.ProfileSchema) (*entities.ProfileSchema, error)
+}
+
+type profileRepository struct {
+	IProfileRepository
+	db *mongo.Database
+}
+
+func NewProfileRepository(database *mongo.Database) IProfileRepository {
+	return &profileRepository{
+		db: database,
+	}
+}
+
+func (r *profileRepository) FindSchema(ctx context.Context, organizationId primitive.ObjectID) (*entities.ProfileSchema, error) {
+	filter := bson.D{{Key: "_id", Value: profileSchemaKey(organizationId)}}
+	result := entities.ProfileSchema{}
+	err := r.db.Collection("profile-schemas").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		result.OrganizationId = organizationId
+		return &result, nil
+	}
+}
+
+func (r *profileRepository) UpsertSchema(ctx context.Context, schema *entities.ProfileSchema) (*entities.ProfileSchema, error) {
+	filter := bson.D{{Key: "_id", Value: profileSchemaKey(schema.OrganizationId)}}
+	_, err := r.db.Collection("profile-schemas").ReplaceOne(ctx, filter, schema, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	} else {
+		return schema, nil
+	}
+}
+
+func profileSchemaKey(organizationId primitive.ObjectID) interface{} {
+	if organizationId.IsZero() {
+		return profileSchemaId
+	}
+	return organizationId
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IRoleRepository interface {
+	InsertOne(ctx context.Context, role *entities.Role) (*entities.Role, error)
+	UpsertSystemRole(ctx context.Context, role *entities.Role) error
+	UpdateOne(ctx context.Context, role *entities.Role) (*entities.Role, error)
+	FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Role, error)
+	FindOneByName(ctx context.Context, name string) (*entities.Role, error)
+	FindMany(ctx context.Context) ([]entities.Role, error)
+	FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]entities.Role, error)
+	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Role, error)
+	InsertAssignment(ctx context.Context, assignment *entities.RoleAssignment) (*entities.RoleAssignment, error)
+	FindAssignmentsByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.RoleAssignment, error)
+	DeleteAssignment(ctx context.Context, userId primitive.ObjectID, roleId primitive.ObjectID) (*entities.RoleAssignment, error)
+	DeleteAssignmentsByRoleId(ctx context.Context, roleId primitive.ObjectID) error
+	DeleteAssignmentsByUserId(ctx context.Context, userId primitive.ObjectID) error
+}
+
+type roleRepository struct {
+	IRoleRepository
+	db *mongo.Database
+}
+
+func NewRoleRepository(database *mongo.Database) IRoleRepository {
+	return &roleRepository{
+		db: database,
+	}
+}
+
+func (r *roleRepository) InsertOne(ctx context.Context, role *entities.Role) (*entities.Role, error) {
+	result, _ := r.FindOneByName(ctx, role.Name)
+	if result != nil {
+		return nil, fmt.Errorf("record exists")
+	} else {
+		role.ID = primitive.NewObjectID()
+		_, err := r.db.Collection("roles").InsertOne(ctx, role)
+		if err != nil {
+			return nil, err
+		} else {
+			return role, nil
+		}
+	}
+}
+
+// UpsertSystemRole creates a system role once, leaving later permission edits by admins intact.
+func (r *roleRepository) UpsertSystemRole(ctx context.Context, role *entities.Role) error {
+	filter := bson.M{"name": role.Name}
+	update := bson.M{"$setOnInsert": role}
+	_, err := r.db.Collection("roles").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *roleRepository) UpdateOne(ctx context.Context, role *entities.Role) (*entities.Role, error) {
+	filter := bson.M{"_id": role.ID}
+	update := bson.M{"$set": bson.M{
+		"description": role.Description,
+		"permissions": role.Permissions,
+		"updatedat":   time.Now(),
+	}}
+	result := entities.Role{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("roles").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *roleRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*entities.Role, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Role{}
+	err := r.db.Collection("roles").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *roleRepository) FindOneByName(ctx context.Context, name string) (*entities.Role, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	result := entities.Role{}
+	err := r.db.Collection("roles").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *roleRepository) FindMany(ctx context.Context) ([]entities.Role, error) {
+	return r.find(ctx, bson.D{})
+}
+
+func (r *roleRepository) FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]entities.Role, error) {
+	return r.find(ctx, bson.D{{Key: "_id", Value: bson.M{"$in": ids}}})
+}
+
+func (r *roleRepository) find(ctx context.Context, filter bson.D) ([]entities.Role, error) {
+	cursor, err := r.db.Collection("roles").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.Role{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *roleRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*entities.Role, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.Role{}
+	err := r.db.Collection("roles").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *roleRepository) InsertAssignment(ctx context.Context, assignment *entities.RoleAssignment) (*entities.RoleAssignment, error) {
+	filter := bson.D{{Key: "userid", Value: assignment.UserId}, {Key: "roleid", Value: assignment.RoleId}}
+	count, err := r.db.Collection("role-assignments").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	} else if count > 0 {
+		return nil, fmt.Errorf("record exists")
+	}
+	assignment.ID = primitive.NewObjectID()
+	_, err = r.db.Collection("role-assignments").InsertOne(ctx, assignment)
+	if err != nil {
+		return nil, err
+	} else {
+		return assignment, nil
+	}
+}
+
+func (r *roleRepository) FindAssignmentsByUserId(ctx context.Context, userId primitive.ObjectID) ([]entities.RoleAssignment, error) {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	cursor, err := r.db.Collection("role-assignments").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.RoleAssignment{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *roleRepository) DeleteAssignment(ctx context.Context, userId primitive.ObjectID, roleId primitive.ObjectID) (*entities.RoleAssignment, error) {
+	filter := bson.D{{Key: "userid", Value: userId}, {Key: "roleid", Value: roleId}}
+	result := entities.RoleAssignment{}
+	err := r.db.Collection("role-assignments").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *roleRepository) DeleteAssignmentsByRoleId(ctx context.Context, roleId primitive.ObjectID) error {
+	filter := bson.D{{Key: "roleid", Value: roleId}}
+	_, err := r.db.Collection("role-assignments").DeleteMany(ctx, filter)
+	return err
+}
+
+func (r *roleRepository) DeleteAssignmentsByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("role-assignments").DeleteMany(ctx, filter)
+	return err
+}
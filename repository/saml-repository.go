@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ISamlRepository interface {
+	InsertConnection(ctx context.Context, connection *entities.SamlConnection) (*entities.SamlConnection, error)
+	FindConnectionByName(ctx context.Context, name string) (*entities.SamlConnection, error)
+	FindConnections(ctx context.Context) ([]entities.SamlConnection, error)
+	DeleteConnectionByName(ctx context.Context, name string) (*entities.SamlConnection, error)
+	InsertState(ctx context.Context, state *entities.SamlLoginState) (primitive.ObjectID, error)
+	ConsumeState(ctx context.Context, state string) (*entities.SamlLoginState, error)
+	InsertAssertion(ctx context.Context, assertion *entities.SamlAssertion) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type samlRepository struct {
+	ISamlRepository
+	db *mongo.Database
+}
+
+func NewSamlRepository(database *mongo.Database) ISamlRepository {
+	return &samlRepository{
+		db: database,
+	}
+}
+
+func (r *samlRepository) InsertConnection(ctx context.Context, connection *entities.SamlConnection) (*entities.SamlConnection, error) {
+	result, _ := r.FindConnectionByName(ctx, connection.Name)
+	if result != nil {
+		return nil, fmt.Errorf("record exists")
+	} else {
+		connection.ID = primitive.NewObjectID()
+		_, err := r.db.Collection("saml-connections").InsertOne(ctx, connection)
+		if err != nil {
+			return nil, err
+		} else {
+			return connection, nil
+		}
+	}
+}
+
+func (r *samlRepository) FindConnectionByName(ctx context.Context, name string) (*entities.SamlConnection, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	result := entities.SamlConnection{}
+	err := r.db.Collection("saml-connections").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *samlRepository) FindConnections(ctx context.Context) ([]entities.SamlConnection, error) {
+	cursor, err := r.db.Collection("saml-connections").Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.SamlConnection{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *samlRepository) DeleteConnectionByName(ctx context.Context, name string) (*entities.SamlConnection, error) {
+	filter := bson.D{{Key: "name", Value: name}}
+	result := entities.SamlConnection{}
+	err := r.db.Collection("saml-connections").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *samlRepository) InsertState(ctx context.Context, state *entities.SamlLoginState) (primitive.ObjectID, error) {
+	state.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("saml-login-states").InsertOne(ctx, state)
+	if err != nil {
+		return primitive.NilObjectID, err
+	} else {
+		return state.ID, nil
+	}
+}
+
+// ConsumeState deletes and returns an unexpired login state, so each state can only be used once.
+func (r *samlRepository) ConsumeState(ctx context.Context, state string) (*entities.SamlLoginState, error) {
+	filter := bson.D{{Key: "state", Value: state}, {Key: "expiresat", Value: bson.M{"$gt": time.Now()}}}
+	result := entities.SamlLoginState{}
+	err := r.db.Collection("saml-login-states").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// InsertAssertion fails with a duplicate key error when the assertion id was seen before.
+func (r *samlRepository) InsertAssertion(ctx context.Context, assertion *entities.SamlAssertion) error {
+	_, err := r.db.Collection("saml-assertions").InsertOne(ctx, assertion)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("assertion replayed")
+	}
+	return err
+}
+
+// EnsureIndexes creates the index login states are consumed with and the TTL indexes
+// dropping login states and consumed assertions once they expire. Assertions are keyed
+// by their id, whose unique index makes replay detection atomic.
+func (r *samlRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("saml-login-states").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "state", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresat", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Collection("saml-assertions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresat", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
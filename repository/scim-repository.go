@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IScimRepository interface {
+	InsertToken(ctx context.Context, token *entities.ScimToken) (*entities.ScimToken, error)
+	FindTokenByHash(ctx context.Context, hash string) (*entities.ScimToken, error)
+	FindTokensByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ScimToken, error)
+	UpdateTokenLastUsed(ctx context.Context, id primitive.ObjectID, lastUsedAt time.Time) error
+	DeleteToken(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.ScimToken, error)
+	UpsertUserLink(ctx context.Context, link *entities.ScimUserLink) (*entities.ScimUserLink, error)
+	FindUserLink(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.ScimUserLink, error)
+	FindUserLinksByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ScimUserLink, error)
+	DeleteUserLink(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) error
+	DeleteUserLinksByUserId(ctx context.Context, userId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type scimRepository struct {
+	IScimRepository
+	db *mongo.Database
+}
+
+func NewScimRepository(database *mongo.Database) IScimRepository {
+	return &scimRepository{
+		db: database,
+	}
+}
+
+func (r *scimRepository) InsertToken(ctx context.Context, token *entities.ScimToken) (*entities.ScimToken, error) {
+	token.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("scim-tokens").InsertOne(ctx, token)
+	if err != nil {
+		return nil, err
+	} else {
+		return token, nil
+	}
+}
+
+func (r *scimRepository) FindTokenByHash(ctx context.Context, hash string) (*entities.ScimToken, error) {
+	filter := bson.D{{Key: "tokenhash", Value: hash}}
+	result := entities.ScimToken{}
+	err := r.db.Collection("scim-tokens").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *scimRepository) FindTokensByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ScimToken, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}}
+	cursor, err := r.db.Collection("scim-tokens").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.ScimToken{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *scimRepository) UpdateTokenLastUsed(ctx context.Context, id primitive.ObjectID, lastUsedAt time.Time) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"lastusedat": lastUsedAt,
+	}}
+	_, err := r.db.Collection("scim-tokens").UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *scimRepository) DeleteToken(ctx context.Context, organizationId primitive.ObjectID, id primitive.ObjectID) (*entities.ScimToken, error) {
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "organizationid", Value: organizationId}}
+	result := entities.ScimToken{}
+	err := r.db.Collection("scim-tokens").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *scimRepository) UpsertUserLink(ctx context.Context, link *entities.ScimUserLink) (*entities.ScimUserLink, error) {
+	filter := bson.D{{Key: "organizationid", Value: link.OrganizationId}, {Key: "userid", Value: link.UserId}}
+	if link.ID.IsZero() {
+		link.ID = primitive.NewObjectID()
+	}
+	opts := options.Replace().SetUpsert(true)
+	_, err := r.db.Collection("scim-users").ReplaceOne(ctx, filter, link, opts)
+	if err != nil {
+		return nil, err
+	} else {
+		return link, nil
+	}
+}
+
+func (r *scimRepository) FindUserLink(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) (*entities.ScimUserLink, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}}
+	result := entities.ScimUserLink{}
+	err := r.db.Collection("scim-users").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *scimRepository) FindUserLinksByOrganizationId(ctx context.Context, organizationId primitive.ObjectID) ([]entities.ScimUserLink, error) {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}}
+	cursor, err := r.db.Collection("scim-users").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.ScimUserLink{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *scimRepository) DeleteUserLink(ctx context.Context, organizationId primitive.ObjectID, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "organizationid", Value: organizationId}, {Key: "userid", Value: userId}}
+	_, err := r.db.Collection("scim-users").DeleteOne(ctx, filter)
+	return err
+}
+
+func (r *scimRepository) DeleteUserLinksByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	filter := bson.D{{Key: "userid", Value: userId}}
+	_, err := r.db.Collection("scim-users").DeleteMany(ctx, filter)
+	return err
+}
+
+// EnsureIndexes creates the unique index scim tokens are authenticated with.
+func (r *scimRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("scim-tokens").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenhash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
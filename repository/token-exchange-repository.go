@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ITokenExchangeRepository interface {
+	InsertClient(ctx context.Context, client *entities.TokenExchangeClient) (*entities.TokenExchangeClient, error)
+	FindClientByClientId(ctx context.Context, clientId string) (*entities.TokenExchangeClient, error)
+	DeleteClientByClientId(ctx context.Context, clientId string) (*entities.TokenExchangeClient, error)
+	InsertExchange(ctx context.Context, exchange *entities.TokenExchange) (primitive.ObjectID, error)
+}
+
+type tokenExchangeRepository struct {
+	ITokenExchangeRepository
+	db *mongo.Database
+}
+
+func NewTokenExchangeRepository(database *mongo.Database) ITokenExchangeRepository {
+	return &tokenExchangeRepository{
+		db: database,
+	}
+}
+
+func (r *tokenExchangeRepository) InsertClient(ctx context.Context, client *entities.TokenExchangeClient) (*entities.TokenExchangeClient, error) {
+	result, _ := r.FindClientByClientId(ctx, client.ClientId)
+	if result != nil {
+		return nil, fmt.Errorf("record exists")
+	} else {
+		client.ID = primitive.NewObjectID()
+		_, err := r.db.Collection("token-exchange-clients").InsertOne(ctx, client)
+		if err != nil {
+			return nil, err
+		} else {
+			return client, nil
+		}
+	}
+}
+
+func (r *tokenExchangeRepository) FindClientByClientId(ctx context.Context, clientId string) (*entities.TokenExchangeClient, error) {
+	filter := bson.D{{Key: "clientid", Value: clientId}}
+	result := entities.TokenExchangeClient{}
+	err := r.db.Collection("token-exchange-clients").FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *tokenExchangeRepository) DeleteClientByClientId(ctx context.Context, clientId string) (*entities.TokenExchangeClient, error) {
+	filter := bson.D{{Key: "clientid", Value: clientId}}
+	result := entities.TokenExchangeClient{}
+	err := r.db.Collection("token-exchange-clients").FindOneAndDelete(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *tokenExchangeRepository) InsertExchange(ctx context.Context, exchange *entities.TokenExchange) (primitive.ObjectID, error) {
+	exchange.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("token-exchanges").InsertOne(ctx, exchange)
+	if err != nil {
+		return primitive.NilObjectID, err
+	} else {
+		return exchange.ID, nil
+	}
+}
@@ -4,12 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"shield/entities"
+
+	"github.com/draco121/horizon/constants"
 
 	"github.com/draco121/horizon/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type IUserRepository interface {
@@ -17,6 +25,19 @@ type IUserRepository interface {
 	UpdateOne(ctx context.Context, user *models.User) (*models.User, error)
 	FindOneById(ctx context.Context, id primitive.ObjectID) (*models.User, error)
 	FindOneByEmail(ctx context.Context, email string) (*models.User, error)
+	FindOneByEmailFold(ctx context.Context, email string) (*models.User, error)
+	FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error)
+	UpdateProfile(ctx context.Context, user *models.User) (*models.User, error)
+	UpdateRole(ctx context.Context, id primitive.ObjectID, role constants.Role) (*entities.UserRecord, error)
+	FindRecordById(ctx context.Context, id primitive.ObjectID) (*entities.UserRecord, error)
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, status string, reason string, changedBy *primitive.ObjectID) (*entities.UserRecord, error)
+	FindManyByQuery(ctx context.Context, query *entities.UserQuery) ([]entities.UserRecord, error)
+	ScheduleDeletion(ctx context.Context, id primitive.ObjectID, purgeAt time.Time, changedBy *primitive.ObjectID) (*entities.UserRecord, error)
+	CancelDeletion(ctx context.Context, id primitive.ObjectID, changedBy *primitive.ObjectID) (*entities.UserRecord, error)
+	FindManyToPurge(ctx context.Context, before time.Time, limit int) ([]entities.UserRecord, error)
+	UpdateDocument(ctx context.Context, id primitive.ObjectID, document *entities.ProfileDocument) (*entities.UserRecord, error)
+	UpdateEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt time.Time) (*entities.UserRecord, error)
+	EnsureIndexes(ctx context.Context) error
 	DeleteOneById(ctx context.Context, id primitive.ObjectID) (*models.User, error)
 }
 
@@ -31,15 +52,45 @@ func NewUserRepository(database *mongo.Database) IUserRepository {
 	}
 }
 
+// userDocument is a user as stored, along with the lower cased copies of the fields the
+// admin user list searches. Queries decode it into models.User and drop the copies.
+type userDocument struct {
+	models.User `bson:",inline"`
+	Search      bson.M `bson:"search"`
+}
+
+// withSearchFields adds to the $set of an update the lower cased copies of the
+// searchable fields it changes.
+func withSearchFields(set bson.M) bson.M {
+	for _, field := range []string{"email", "firstname", "lastname"} {
+		if value, ok := set[field].(string); ok {
+			set["search."+field] = strings.ToLower(value)
+		}
+	}
+	return set
+}
+
 func (ur *userRepository) InsertOne(ctx context.Context, user *models.User) (*models.User, error) {
 	result, _ := ur.FindOneByEmail(ctx, user.Email)
 	if result != nil {
 		return nil, fmt.Errorf("record exists")
 	} else {
 		user.ID = primitive.NewObjectID()
-		_, err := ur.db.Collection("users").InsertOne(ctx, user)
+		err := ur.reserveEmail(ctx, user.Email, user.ID)
 		if err != nil {
 			return nil, err
+		}
+		_, err = ur.db.Collection("users").InsertOne(ctx, userDocument{
+			User: *user,
+			Search: bson.M{
+				"email":     strings.ToLower(user.Email),
+				"firstname": strings.ToLower(user.FirstName),
+				"lastname":  strings.ToLower(user.LastName),
+			},
+		})
+		if err != nil {
+			_ = ur.releaseEmail(ctx, user.Email, user.ID)
+			return nil, err
 		} else {
 			return user, nil
 		}
@@ -60,6 +111,114 @@ func (ur *userRepository) UpdateOne(ctx context.Context, user *models.User) (*mo
 	}
 }
 
+// UpdateProfile updates the email and name of a user, leaving password and role alone.
+func (ur *userRepository) UpdateProfile(ctx context.Context, user *models.User) (*models.User, error) {
+	current, err := ur.FindOneById(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	emailChanged := !strings.EqualFold(current.Email, user.Email)
+	if emailChanged {
+		err = ur.reserveEmail(ctx, user.Email, user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	filter := bson.M{"_id": user.ID}
+	update := bson.M{"$set": withSearchFields(bson.M{
+		"email":     user.Email,
+		"firstname": user.FirstName,
+		"lastname":  user.LastName,
+	})}
+	result := models.User{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = ur.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		if emailChanged {
+			_ = ur.releaseEmail(ctx, user.Email, user.ID)
+		}
+		return nil, err
+	}
+	if emailChanged {
+		_ = ur.releaseEmail(ctx, current.Email, user.ID)
+	}
+	return &result, nil
+}
+
+// UpdateEmail moves a user to a new email, marking it verified at verifiedAt. It fails
+// with "record exists" when another user holds the email.
+func (ur *userRepository) UpdateEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt time.Time) (*entities.UserRecord, error) {
+	current, err := ur.FindRecordById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = ur.reserveEmail(ctx, email, id)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": withSearchFields(bson.M{
+		"email":           email,
+		"emailverifiedat": verifiedAt,
+	})}
+	result := entities.UserRecord{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = ur.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	emailChanged := !strings.EqualFold(current.Email, email)
+	if err != nil {
+		if emailChanged {
+			_ = ur.releaseEmail(ctx, email, id)
+		}
+		return nil, err
+	}
+	if emailChanged {
+		err = ur.releaseEmail(ctx, current.Email, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &result, nil
+}
+
+// reserveEmail claims an email for a user in the email-reservations collection, whose
+// ids are lower cased emails. The unique id makes two users claiming the same email at
+// once fail, which a lookup followed by a write cannot. Users stored before
+// reservations existed hold none, so they are looked up as well.
+func (ur *userRepository) reserveEmail(ctx context.Context, email string, userId primitive.ObjectID) error {
+	holder, err := ur.FindOneByEmailFold(ctx, email)
+	if err == nil && holder.ID != userId {
+		return fmt.Errorf("record exists")
+	}
+	_, err = ur.db.Collection("email-reservations").UpdateOne(ctx,
+		bson.M{"_id": strings.ToLower(email), "userid": userId},
+		bson.M{"$setOnInsert": bson.M{"createdat": time.Now()}},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("record exists")
+	}
+	return err
+}
+
+func (ur *userRepository) releaseEmail(ctx context.Context, email string, userId primitive.ObjectID) error {
+	_, err := ur.db.Collection("email-reservations").DeleteOne(ctx, bson.M{"_id": strings.ToLower(email), "userid": userId})
+	return err
+}
+
+func (ur *userRepository) FindManyByIds(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	filter := bson.D{{Key: "_id", Value: bson.M{"$in": ids}}}
+	cursor, err := ur.db.Collection("users").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []models.User{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
 func (ur *userRepository) FindOneById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
 
 	filter := bson.D{{Key: "_id", Value: id}}
@@ -84,14 +243,247 @@ func (ur *userRepository) FindOneByEmail(ctx context.Context, email string) (*mo
 	}
 }
 
+// FindOneByEmailFold finds a user by email ignoring case.
+func (ur *userRepository) FindOneByEmailFold(ctx context.Context, email string) (*models.User, error) {
+	filter := bson.D{{Key: "email", Value: email}}
+	opts := options.FindOne().SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	result := models.User{}
+	err := ur.db.Collection("users").FindOne(ctx, filter, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
 func (ur *userRepository) DeleteOneById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
 	filter := bson.D{{Key: "_id", Value: id}}
 	result := models.User{}
 	err := ur.db.Collection("users").FindOneAndDelete(ctx, filter).Decode(&result)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, err
+	}
+	_, err = ur.db.Collection("email-reservations").DeleteMany(ctx, bson.M{"userid": id})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (ur *userRepository) UpdateRole(ctx context.Context, id primitive.ObjectID, role constants.Role) (*entities.UserRecord, error) {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"role": role,
+	}}
+	result := entities.UserRecord{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := ur.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
 	} else {
 		return &result, nil
 	}
+}
+
+func (ur *userRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string, reason string, changedBy *primitive.ObjectID) (*entities.UserRecord, error) {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"status":          status,
+		"statusreason":    reason,
+		"statuschangedat": time.Now(),
+		"statuschangedby": changedBy,
+	}}
+	result := entities.UserRecord{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := ur.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// ScheduleDeletion marks a user as pending deletion until purgeAt, remembering its
+// current status. It fails when the user is already pending deletion.
+func (ur *userRepository) ScheduleDeletion(ctx context.Context, id primitive.ObjectID, purgeAt time.Time, changedBy *primitive.ObjectID) (*entities.UserRecord, error) {
+	filter := bson.M{"_id": id, "status": bson.M{"$ne": entities.UserPendingDeletion}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"statusbeforedeletion": "$status",
+		"status":               entities.UserPendingDeletion,
+		"statusreason":         "deletion requested",
+		"statuschangedat":      time.Now(),
+		"statuschangedby":      changedBy,
+		"purgeat":              purgeAt,
+	}}}}
+	result := entities.UserRecord{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := ur.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
 
+// CancelDeletion restores a user pending deletion to the status it had before.
+func (ur *userRepository) CancelDeletion(ctx context.Context, id primitive.ObjectID, changedBy *primitive.ObjectID) (*entities.UserRecord, error) {
+	filter := bson.M{"_id": id, "status": entities.UserPendingDeletion}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"status":          bson.M{"$ifNull": bson.A{"$statusbeforedeletion", entities.UserActive}},
+			"statusreason":    "deletion cancelled",
+			"statuschangedat": time.Now(),
+			"statuschangedby": changedBy,
+		}}},
+		{{Key: "$unset", Value: bson.A{"statusbeforedeletion", "purgeat"}}},
+	}
+	result := entities.UserRecord{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := ur.db.Collection("users").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// FindManyToPurge returns up to limit users pending deletion whose grace period ended
+// before the given time.
+func (ur *userRepository) FindManyToPurge(ctx context.Context, before time.Time, limit int) ([]entities.UserRecord, error) {
+	filter := bson.D{
+		{Key: "status", Value: entities.UserPendingDeletion},
+		{Key: "purgeat", Value: bson.M{"$lte": before}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "purgeat", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := ur.db.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.UserRecord{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// UpdateDocument replaces the names, profile and custom attributes of a user. The
+// password is only replaced when the document carries a new hash.
+func (ur *userRepository) UpdateDocument(ctx context.Context, id primitive.ObjectID, document *entities.ProfileDocument) (*entities.UserRecord, error) {
+	filter := bson.M{"_id": id}
+	set := bson.M{
+		"firstname":  document.FirstName,
+		"lastname":   document.LastName,
+		"profile":    document.Profile,
+		"attributes": document.Attributes,
+	}
+	if document.Password != "" {
+		set["password"] = document.Password
+	}
+	result := entities.UserRecord{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := ur.db.Collection("users").FindOneAndUpdate(ctx, filter, bson.M{"$set": withSearchFields(set)}, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (ur *userRepository) FindRecordById(ctx context.Context, id primitive.ObjectID) (*entities.UserRecord, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.UserRecord{}
+	err := ur.db.Collection("users").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// FindManyByQuery returns up to query.Limit users matching the query, newest first.
+// Pages are keyed on _id, which also encodes the creation time of the user.
+func (ur *userRepository) FindManyByQuery(ctx context.Context, query *entities.UserQuery) ([]entities.UserRecord, error) {
+	filter := bson.D{}
+	id := bson.M{}
+	if query.Cursor != "" {
+		cursor, err := primitive.ObjectIDFromHex(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		id["$lt"] = cursor
+	}
+	if query.CreatedBefore != nil {
+		before := primitive.NewObjectIDFromTimestamp(*query.CreatedBefore)
+		if lt, ok := id["$lt"].(primitive.ObjectID); !ok || before.Hex() < lt.Hex() {
+			id["$lt"] = before
+		}
+	}
+	if query.CreatedAfter != nil {
+		id["$gte"] = primitive.NewObjectIDFromTimestamp(*query.CreatedAfter)
+	}
+	if query.UserIds != nil {
+		id["$in"] = query.UserIds
+	}
+	if len(id) > 0 {
+		filter = append(filter, bson.E{Key: "_id", Value: id})
+	}
+	if query.Role != "" {
+		filter = append(filter, bson.E{Key: "role", Value: query.Role})
+	}
+	if query.Status == entities.UserActive {
+		filter = append(filter, bson.E{Key: "status", Value: bson.M{"$in": bson.A{nil, entities.UserActive}}})
+	} else if query.Status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: query.Status})
+	}
+	if query.Q != "" {
+		// a case sensitive prefix on the lower cased copies can use their indexes
+		prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(query.Q))}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.M{"search.email": prefix},
+			bson.M{"search.firstname": prefix},
+			bson.M{"search.lastname": prefix},
+		}})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(query.Limit))
+	cursor, err := ur.db.Collection("users").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.UserRecord{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// EnsureIndexes creates the indexes backing the lookups, the admin user list and the
+// purger. Users stored before the list searched lower cased copies get theirs first.
+func (ur *userRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := ur.db.Collection("users").UpdateMany(ctx, bson.M{"search": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"search": bson.M{
+			"email":     bson.M{"$toLower": "$email"},
+			"firstname": bson.M{"$toLower": "$firstname"},
+			"lastname":  bson.M{"$toLower": "$lastname"},
+		}}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = ur.db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_ci").SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+		},
+		{Keys: bson.D{{Key: "search.email", Value: 1}}},
+		{Keys: bson.D{{Key: "search.firstname", Value: 1}}},
+		{Keys: bson.D{{Key: "search.lastname", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "purgeat", Value: 1}}},
+	})
+	return err
 }
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"shield/entities"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IWebhookRepository interface {
+	InsertEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) (*entities.WebhookEndpoint, error)
+	FindEndpoints(ctx context.Context) ([]entities.WebhookEndpoint, error)
+	FindEndpointById(ctx context.Context, id primitive.ObjectID) (*entities.WebhookEndpoint, error)
+	FindEndpointsByEvent(ctx context.Context, eventType string) ([]entities.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id primitive.ObjectID) (*entities.WebhookEndpoint, error)
+	InsertDeliveries(ctx context.Context, deliveries []entities.WebhookDelivery) error
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*entities.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error
+	FindDeliveries(ctx context.Context, endpointId primitive.ObjectID, status string) ([]entities.WebhookDelivery, error)
+	Redeliver(ctx context.Context, id primitive.ObjectID) (*entities.WebhookDelivery, error)
+	DeleteDeliveriesByEndpointId(ctx context.Context, endpointId primitive.ObjectID) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type webhookRepository struct {
+	IWebhookRepository
+	db *mongo.Database
+}
+
+func NewWebhookRepository(database *mongo.Database) IWebhookRepository {
+	return &webhookRepository{
+		db: database,
+	}
+}
+
+func (r *webhookRepository) InsertEndpoint(ctx context.Context, endpoint *entities.WebhookEndpoint) (*entities.WebhookEndpoint, error) {
+	endpoint.ID = primitive.NewObjectID()
+	_, err := r.db.Collection("webhook-endpoints").InsertOne(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	} else {
+		return endpoint, nil
+	}
+}
+
+func (r *webhookRepository) FindEndpoints(ctx context.Context) ([]entities.WebhookEndpoint, error) {
+	return r.findEndpoints(ctx, bson.D{})
+}
+
+func (r *webhookRepository) FindEndpointById(ctx context.Context, id primitive.ObjectID) (*entities.WebhookEndpoint, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.WebhookEndpoint{}
+	err := r.db.Collection("webhook-endpoints").FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// FindEndpointsByEvent returns the endpoints subscribed to the event type or to "*".
+func (r *webhookRepository) FindEndpointsByEvent(ctx context.Context, eventType string) ([]entities.WebhookEndpoint, error) {
+	return r.findEndpoints(ctx, bson.D{{Key: "events", Value: bson.M{"$in": bson.A{eventType, "*"}}}})
+}
+
+func (r *webhookRepository) findEndpoints(ctx context.Context, filter bson.D) ([]entities.WebhookEndpoint, error) {
+	cursor, err := r.db.Collection("webhook-endpoints").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.WebhookEndpoint{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+func (r *webhookRepository) DeleteEndpoint(ctx context.Context, id primitive.ObjectID) (*entities.WebhookEndpoint, error) {
+	filter := bson.D{{Key: "_id", Value: id}}
+	result := entities.WebhookEndpoint{}
+	err := r.db.Collection("webhook-endpoints").FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+// InsertDeliveries stores new deliveries. Deliveries already stored for the same
+// endpoint and event are skipped, so dispatching an event twice is harmless.
+func (r *webhookRepository) InsertDeliveries(ctx context.Context, deliveries []entities.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(deliveries))
+	for i := range deliveries {
+		deliveries[i].ID = primitive.NewObjectID()
+		documents = append(documents, deliveries[i])
+	}
+	_, err := r.db.Collection("webhook-deliveries").InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	return nil
+}
+
+// ClaimDue takes the pending delivery that has been due the longest and pushes its
+// next attempt lease into the future, so no other worker picks it up meanwhile.
+func (r *webhookRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*entities.WebhookDelivery, error) {
+	filter := bson.D{
+		{Key: "status", Value: entities.WebhookDeliveryPending},
+		{Key: "nextattemptat", Value: bson.M{"$lte": now}},
+	}
+	update := bson.M{"$set": bson.M{
+		"nextattemptat": now.Add(lease),
+	}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "nextattemptat", Value: 1}})
+	result := entities.WebhookDelivery{}
+	err := r.db.Collection("webhook-deliveries").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *entities.WebhookDelivery) error {
+	filter := bson.M{"_id": delivery.ID}
+	update := bson.M{"$set": bson.M{
+		"status":         delivery.Status,
+		"attempts":       delivery.Attempts,
+		"nextattemptat":  delivery.NextAttemptAt,
+		"lasterror":      delivery.LastError,
+		"laststatuscode": delivery.LastStatusCode,
+		"deliveredat":    delivery.DeliveredAt,
+	}}
+	_, err := r.db.Collection("webhook-deliveries").UpdateOne(ctx, filter, update)
+	return err
+}
+
+// FindDeliveries returns the latest deliveries of an endpoint, optionally only those
+// with the given status.
+func (r *webhookRepository) FindDeliveries(ctx context.Context, endpointId primitive.ObjectID, status string) ([]entities.WebhookDelivery, error) {
+	filter := bson.D{{Key: "endpointid", Value: endpointId}}
+	if status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: status})
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(100)
+	cursor, err := r.db.Collection("webhook-deliveries").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := []entities.WebhookDelivery{}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return nil, err
+	} else {
+		return result, nil
+	}
+}
+
+// Redeliver schedules a delivery for an immediate new round of attempts.
+func (r *webhookRepository) Redeliver(ctx context.Context, id primitive.ObjectID) (*entities.WebhookDelivery, error) {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{
+		"status":        entities.WebhookDeliveryPending,
+		"attempts":      0,
+		"nextattemptat": time.Now(),
+	}}
+	result := entities.WebhookDelivery{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.db.Collection("webhook-deliveries").FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	} else {
+		return &result, nil
+	}
+}
+
+func (r *webhookRepository) DeleteDeliveriesByEndpointId(ctx context.Context, endpointId primitive.ObjectID) error {
+	filter := bson.D{{Key: "endpointid", Value: endpointId}}
+	_, err := r.db.Collection("webhook-deliveries").DeleteMany(ctx, filter)
+	return err
+}
+
+// EnsureIndexes creates the index workers claim deliveries with and the unique index
+// that makes dispatching idempotent.
+func (r *webhookRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Collection("webhook-deliveries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextattemptat", Value: 1}}},
+		{Keys: bson.D{{Key: "endpointid", Value: 1}, {Key: "eventid", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "endpointid", Value: 1}, {Key: "status", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	return err
+}
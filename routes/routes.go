@@ -1,23 +1,126 @@
 package routes
 
 import (
-	"github.com/draco121/horizon/constants"
-	"github.com/draco121/horizon/middlewares"
 	"github.com/draco121/horizon/utils"
 	"shield/controllers"
+	"shield/entities"
+	"shield/middlewares"
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(controllers controllers.Controllers, router *gin.Engine) {
+func RegisterRoutes(controllers controllers.Controllers, authorizer middlewares.Authorizer, router *gin.Engine) {
 	utils.Logger.Info("Registering routes...")
+	router.Use(middlewares.ClientInfo())
 	v1 := router.Group("/v1")
 	v1.POST("/login", controllers.Login)
 	v1.POST("/refresh", controllers.RefreshLogin)
 	v1.POST("/logout", controllers.Logout)
 	v1.POST("/user", controllers.CreateUser)
-	v1.GET("/user", middlewares.AuthMiddleware(constants.Write), controllers.GetUserProfile)
-	v1.PATCH("/user", middlewares.AuthMiddleware(constants.Write), controllers.UpdateUser)
-	v1.DELETE("/user", middlewares.AuthMiddleware(constants.All), controllers.DeleteUser)
+	v1.GET("/user", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetUserProfile)
+	v1.PATCH("/user", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.UpdateUser)
+	v1.DELETE("/user", authorizer.RequirePermission(entities.PermissionUsersDelete), controllers.DeleteUser)
+	v1.GET("/user/schema", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetProfileSchema)
+	v1.POST("/user/email", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.RequestEmailChange)
+	v1.POST("/user/email/confirm", controllers.ConfirmEmailChange)
+	v1.POST("/user/email/revert", controllers.RevertEmailChange)
+	v1.POST("/token", controllers.Token)
+	v1.POST("/token-exchange/clients", authorizer.RequirePermission(entities.PermissionTokenExchangeManage), controllers.CreateTokenExchangeClient)
+	v1.DELETE("/token-exchange/clients/:clientId", authorizer.RequirePermission(entities.PermissionTokenExchangeManage), controllers.DeleteTokenExchangeClient)
+	v1.GET("/oidc/providers", controllers.GetIdentityProviders)
+	v1.POST("/oidc/providers", authorizer.RequirePermission(entities.PermissionIdentityProviderManage), controllers.CreateIdentityProvider)
+	v1.DELETE("/oidc/providers/:provider", authorizer.RequirePermission(entities.PermissionIdentityProviderManage), controllers.DeleteIdentityProvider)
+	v1.GET("/oidc/:provider/authorize", controllers.OidcAuthorize)
+	v1.GET("/oidc/:provider/callback", controllers.OidcCallback)
+	v1.GET("/saml/connections", authorizer.RequirePermission(entities.PermissionIdentityProviderManage), controllers.GetSamlConnections)
+	v1.POST("/saml/connections", authorizer.RequirePermission(entities.PermissionIdentityProviderManage), controllers.CreateSamlConnection)
+	v1.DELETE("/saml/connections/:connection", authorizer.RequirePermission(entities.PermissionIdentityProviderManage), controllers.DeleteSamlConnection)
+	v1.GET("/saml/:connection/metadata", controllers.SamlMetadata)
+	v1.GET("/saml/:connection/login", controllers.SamlLogin)
+	v1.POST("/saml/:connection/acs", controllers.SamlAcs)
+	v1.GET("/user/identities", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetIdentities)
+	v1.POST("/user/identities/oidc/:provider", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.LinkOidcIdentity)
+	v1.POST("/user/identities/saml/:connection", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.LinkSamlIdentity)
+	v1.DELETE("/user/identities/:id", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.UnlinkIdentity)
+	v1.GET("/user/tokens", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetPersonalAccessTokens)
+	v1.POST("/user/tokens", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.CreatePersonalAccessToken)
+	v1.DELETE("/user/tokens/:id", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.RevokePersonalAccessToken)
+	v1.GET("/user/logins", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetLogins)
+	v1.POST("/user/exports", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.RequestDataExport)
+	v1.GET("/user/exports/:id", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetDataExport)
+	v1.GET("/user/exports/:id/download", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.DownloadDataExport)
+	v1.POST("/authorize", controllers.Authorize)
+	v1.GET("/organizations", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetOrganizations)
+	v1.POST("/organizations", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.CreateOrganization)
+	v1.POST("/organizations/:organizationId/switch", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.SwitchOrganization)
+	v1.GET("/organizations/:organizationId/members", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetOrganizationMembers)
+	v1.PUT("/organizations/:organizationId/members/:userId", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.UpdateOrganizationMember)
+	v1.DELETE("/organizations/:organizationId/members/:userId", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.RemoveOrganizationMember)
+	v1.GET("/organizations/:organizationId/invitations", authorizer.RequirePermission(entities.PermissionProfileRead), controllers.GetInvitations)
+	v1.POST("/organizations/:organizationId/invitations", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.CreateInvitation)
+	v1.DELETE("/organizations/:organizationId/invitations/:invitationId", authorizer.RequirePermission(entities.PermissionProfileWrite), controllers.RevokeInvitation)
+	v1.POST("/invitations/accept", controllers.AcceptInvitation)
+	admin := v1.Group("/admin")
+	admin.GET("/organizations/:organizationId/api-keys", authorizer.RequirePermission(entities.PermissionApiKeysManage), controllers.GetApiKeys)
+	admin.POST("/organizations/:organizationId/api-keys", authorizer.RequirePermission(entities.PermissionApiKeysManage), controllers.CreateApiKey)
+	admin.POST("/organizations/:organizationId/api-keys/:keyId/rotate", authorizer.RequirePermission(entities.PermissionApiKeysManage), controllers.RotateApiKey)
+	admin.DELETE("/organizations/:organizationId/api-keys/:keyId", authorizer.RequirePermission(entities.PermissionApiKeysManage), controllers.DeleteApiKey)
+	admin.GET("/organizations/:organizationId/api-keys/:keyId/usages", authorizer.RequirePermission(entities.PermissionApiKeysManage), controllers.GetApiKeyUsages)
+	admin.GET("/organizations/:organizationId/groups", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.GetGroups)
+	admin.POST("/organizations/:organizationId/groups", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.CreateGroup)
+	admin.PUT("/organizations/:organizationId/groups/:groupId", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.UpdateGroup)
+	admin.DELETE("/organizations/:organizationId/groups/:groupId", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.DeleteGroup)
+	admin.GET("/organizations/:organizationId/groups/:groupId/members", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.GetGroupMembers)
+	admin.POST("/organizations/:organizationId/groups/:groupId/members", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.AddGroupMember)
+	admin.DELETE("/organizations/:organizationId/groups/:groupId/members/:memberId", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.RemoveGroupMember)
+	admin.GET("/organizations/:organizationId/users/:userId/groups", authorizer.RequirePermission(entities.PermissionGroupsManage), controllers.GetEffectiveGroups)
+	admin.GET("/organizations/:organizationId/scim-tokens", authorizer.RequirePermission(entities.PermissionScimManage), controllers.GetScimTokens)
+	admin.POST("/organizations/:organizationId/scim-tokens", authorizer.RequirePermission(entities.PermissionScimManage), controllers.CreateScimToken)
+	admin.DELETE("/organizations/:organizationId/scim-tokens/:tokenId", authorizer.RequirePermission(entities.PermissionScimManage), controllers.DeleteScimToken)
+	admin.GET("/roles", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.GetRoles)
+	admin.POST("/roles", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.CreateRole)
+	admin.PUT("/roles/:id", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.UpdateRole)
+	admin.DELETE("/roles/:id", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.DeleteRole)
+	admin.GET("/claim-templates", authorizer.RequirePermission(entities.PermissionClaimsManage), controllers.GetClaimTemplates)
+	admin.POST("/claim-templates", authorizer.RequirePermission(entities.PermissionClaimsManage), controllers.CreateClaimTemplate)
+	admin.PUT("/claim-templates/:id", authorizer.RequirePermission(entities.PermissionClaimsManage), controllers.UpdateClaimTemplate)
+	admin.DELETE("/claim-templates/:id", authorizer.RequirePermission(entities.PermissionClaimsManage), controllers.DeleteClaimTemplate)
+	admin.GET("/webhooks", authorizer.RequirePermission(entities.PermissionWebhooksManage), controllers.GetWebhookEndpoints)
+	admin.POST("/webhooks", authorizer.RequirePermission(entities.PermissionWebhooksManage), controllers.CreateWebhookEndpoint)
+	admin.DELETE("/webhooks/:id", authorizer.RequirePermission(entities.PermissionWebhooksManage), controllers.DeleteWebhookEndpoint)
+	admin.GET("/webhooks/:id/deliveries", authorizer.RequirePermission(entities.PermissionWebhooksManage), controllers.GetWebhookDeliveries)
+	admin.POST("/webhooks/deliveries/:deliveryId/redeliver", authorizer.RequirePermission(entities.PermissionWebhooksManage), controllers.RedeliverWebhook)
+	admin.GET("/audit/events", authorizer.RequirePermission(entities.PermissionAuditRead), controllers.GetAuditEvents)
+	admin.GET("/audit/events/export", authorizer.RequirePermission(entities.PermissionAuditRead), controllers.ExportAuditEvents)
+	admin.GET("/audit/verify", authorizer.RequirePermission(entities.PermissionAuditRead), controllers.VerifyAuditLog)
+	admin.GET("/users", authorizer.RequirePermission(entities.PermissionUsersRead), controllers.GetUsers)
+	admin.GET("/users/:id", authorizer.RequirePermission(entities.PermissionUsersRead), controllers.GetUser)
+	admin.PATCH("/users/:id", authorizer.RequirePermission(entities.PermissionUsersManage), controllers.UpdateUserProfile)
+	admin.GET("/profile-schema", authorizer.RequirePermission(entities.PermissionUsersManage), controllers.GetProfileSchema)
+	admin.PUT("/profile-schema", authorizer.RequirePermission(entities.PermissionUsersManage), controllers.UpdateProfileSchema)
+	admin.PUT("/users/:id/role", authorizer.RequirePermission(entities.PermissionUsersManage), controllers.ChangeUserRole)
+	admin.PUT("/users/:id/status", authorizer.RequirePermission(entities.PermissionUsersManage), controllers.ChangeUserStatus)
+	admin.POST("/users/:id/restore", authorizer.RequirePermission(entities.PermissionUsersManage), controllers.RestoreUser)
+	admin.GET("/users/:id/roles", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.GetUserRoles)
+	admin.POST("/users/:id/roles", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.AssignRole)
+	admin.DELETE("/users/:id/roles/:roleId", authorizer.RequirePermission(entities.PermissionRolesManage), controllers.UnassignRole)
+	admin.GET("/policies", authorizer.RequirePermission(entities.PermissionPoliciesManage), controllers.GetPolicies)
+	admin.POST("/policies", authorizer.RequirePermission(entities.PermissionPoliciesManage), controllers.SavePolicy)
+	admin.POST("/policies/dry-run", authorizer.RequirePermission(entities.PermissionPoliciesManage), controllers.DryRunPolicy)
+	admin.GET("/policies/:name/versions", authorizer.RequirePermission(entities.PermissionPoliciesManage), controllers.GetPolicyVersions)
+	admin.DELETE("/policies/:name", authorizer.RequirePermission(entities.PermissionPoliciesManage), controllers.DeletePolicy)
+	scim := router.Group("/scim/v2", authorizer.RequireScimToken())
+	scim.GET("/Users", controllers.GetScimUsers)
+	scim.POST("/Users", controllers.CreateScimUser)
+	scim.GET("/Users/:id", controllers.GetScimUser)
+	scim.PUT("/Users/:id", controllers.ReplaceScimUser)
+	scim.PATCH("/Users/:id", controllers.PatchScimUser)
+	scim.DELETE("/Users/:id", controllers.DeleteScimUser)
+	scim.GET("/Groups", controllers.GetScimGroups)
+	scim.POST("/Groups", controllers.CreateScimGroup)
+	scim.GET("/Groups/:id", controllers.GetScimGroup)
+	scim.PUT("/Groups/:id", controllers.ReplaceScimGroup)
+	scim.PATCH("/Groups/:id", controllers.PatchScimGroup)
+	scim.DELETE("/Groups/:id", controllers.DeleteScimGroup)
 	utils.Logger.Info("Registered routes...")
 }
@@ -0,0 +1,29 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// GenerateSecret returns a random, hex encoded secret of n bytes prefixed with prefix.
+func GenerateSecret(prefix string, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// HashSecret returns the hex encoded sha256 digest of a secret. High entropy secrets
+// do not need a slow hash, which keeps per-request verification cheap.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckSecretHash reports whether secret matches hash in constant time.
+func CheckSecretHash(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hash)) == 1
+}